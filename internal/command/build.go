@@ -2,10 +2,13 @@ package command
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/native"
 	"github.com/jathu/starfig/internal/starverse"
 	"github.com/jathu/starfig/internal/target"
 	"github.com/jathu/starfig/internal/util"
@@ -13,51 +16,314 @@ import (
 	"go.starlark.net/starlark"
 )
 
-func Build(args []string, keepGoing bool) error {
-	starverseDir, err := starverse.FindStarverseDirectory()
+type BuildOptions struct {
+	KeepGoing bool
+	// Build settings in the form of key=value, i.e. env=prod.
+	Defines []string
+	// Build matrix axes in the form of key=value,value, i.e. env=dev,prod.
+	Matrix []string
+	// A file listing the changed files, one per line, or - for stdin. Only the targets
+	// depending on a changed file are built.
+	ChangedFiles string
+	// The directory of golden files to compare the output with, instead of writing it.
+	CheckGolden string
+	// Write the output to the golden files instead of comparing it.
+	UpdateGolden bool
+	// Wrap each target in an envelope with the metadata of how it was built.
+	WithMetadata bool
+}
+
+func Build(args []string, options BuildOptions) error {
+	if options.UpdateGolden && options.CheckGolden == "" {
+		return fmt.Errorf("--update-golden requires the golden directory. i.e. --check-golden=golden --update-golden.")
+	}
+
+	starverseDir, config, err := loadStarverse()
 	if err != nil {
 		return err
 	}
 
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return err
+	}
+	axes, err := parseMatrix(options.Matrix)
+	if err != nil {
+		return err
+	}
+	settings, axes := mergeSettings(config, defines, axes)
+	combinations, err := evaluator.ExpandMatrix(settings, axes)
+	if err != nil {
+		return err
+	}
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+	keepGoing := options.KeepGoing
+	// Every target is built once per combination of a matrix build, so the settings a target
+	// used are part of its name. Otherwise the name is its label.
+	matrix := len(combinations) > 1
+
 	evaluatedOutput := new(starlark.Dict)
-	summary := buildResult{results: map[string]*[]error{}}
+	summary := buildResult{results: map[string]*[]error{}, settings: map[string]map[string]string{}}
+	// The packages evaluated in full, whose golden files must all be built. Only the affected
+	// targets are built with changed files, so no package is built in full.
+	builtPackages := map[string]bool{}
+	failedPackages := map[string]bool{}
+
+	ignore, err := target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return err
+	}
 
-	for _, arg := range args {
-		buildTargets, err := target.ParseBuildTarget(starverseDir, arg)
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	var changed *evaluator.ChangedFiles
+	staticGraph := evaluator.NewStaticGraph(starverseDir, universes)
+	if options.ChangedFiles != "" {
+		changedFiles, err := readChangedFiles(starverseDir, workingDir, universes, options.ChangedFiles)
 		if err != nil {
-			if keepGoing {
-				summary.note(arg, err)
-			} else {
-				return err
-			}
+			return err
+		}
+		changed = &changedFiles
+		if len(args) == 0 {
+			args = []string{"//..."}
+		}
+	}
+
+	patterns, patternErrs := target.ParseBuildTargets(starverseDir, workingDir, args, ignore)
+	for _, patternErr := range patternErrs {
+		if keepGoing {
+			summary.note(patternErr.Pattern, patternErr)
+		} else {
+			return patternErr
 		}
-		for _, buildTarget := range buildTargets {
-			evaluateResults, err := evaluator.EvaluateBuildTarget(starverseDir, buildTarget)
+	}
+
+	// Constraints span every instance of a schema in the universe, including the packages that
+	// aren't built.
+	universePackages, err := target.ParseBuildTarget(starverseDir, "//...", ignore)
+	if err != nil {
+		return err
+	}
+
+	// Every target is evaluated once per combination of the matrix. Constraints are checked
+	// within a combination, since instances of different variants are expected to overlap.
+	for _, combination := range combinations {
+		// The dependencies can differ between combinations, i.e. a reference picked by select,
+		// so the affected targets and the metadata hashes use the graph of their combination.
+		graph := native.NewDependencyGraph()
+		evaluatorOptions := evaluator.Options{Settings: combination, Universes: universes, Graph: graph}
+		builtResults := []evaluator.EvaluateResult{}
+		builtKeys := map[string]bool{}
+
+		for i, buildTarget := range patterns.Targets {
+			// The packages that can't depend on a changed file aren't evaluated. The ones
+			// that can are evaluated to find which of their targets are affected.
+			if changed != nil && !changed.MayAffect(staticGraph, buildTarget.StarfigFile()) {
+				continue
+			}
+			evaluateResults, err := evaluator.EvaluateBuildTarget(starverseDir, buildTarget, evaluatorOptions)
+			// The dependencies loaded before an error are recorded, so an unaffected package
+			// that fails to evaluate is skipped.
+			if err != nil && changed != nil && !changed.Affects(graph, buildTarget.StarfigFile().Target()) {
+				continue
+			}
 			if err != nil {
 				if keepGoing {
-					summary.note(buildTarget.Target(), err)
+					summary.note(variantName(buildTarget.Target(), combination, matrix), err)
+					failedPackages[buildTarget.Package] = true
 				} else {
 					return err
 				}
+			} else if buildTarget.TargetName == "..." && len(patterns.Excluded[i]) == 0 && changed == nil {
+				builtPackages[buildTarget.Package] = true
 			}
 
 			for _, evaluateResult := range evaluateResults {
-				summary.note(evaluateResult.Target.Target(), nil)
-				evaluatedOutput.SetKey(
-					starlark.String(evaluateResult.Target.Target()),
-					evaluateResult.Result.Evaluated,
-				)
+				if patterns.IsExcluded(i, evaluateResult.Target) {
+					continue
+				} else if changed != nil && !changed.Affects(graph, evaluateResult.Target.Target()) {
+					continue
+				}
+				name := variantName(evaluateResult.Key(), evaluateResult.Settings, matrix)
+				summary.note(name, nil)
+				if !matrix {
+					summary.noteSettings(name, evaluateResult.Settings)
+				}
+				if !builtKeys[evaluateResult.Key()] {
+					builtResults = append(builtResults, evaluateResult)
+					builtKeys[evaluateResult.Key()] = true
+				}
+				key := starlark.String(name)
+				if options.WithMetadata {
+					metadata, err := evaluator.NewMetadata(
+						starverseDir, universes, graph, evaluateResult, StarfigVersion)
+					if err != nil {
+						if keepGoing {
+							summary.note(name, err)
+							continue
+						}
+						return err
+					}
+					evaluatedOutput.SetKey(key, metadata.Envelope(evaluateResult.Result.Evaluated))
+				} else {
+					evaluatedOutput.SetKey(key, evaluateResult.Result.Evaluated)
+				}
+			}
+		}
+
+		instances, instanceErrs := evaluator.CompleteInstances(
+			starverseDir, universePackages, builtResults, evaluator.Options{Settings: combination, Universes: universes})
+		for _, instanceErr := range instanceErrs {
+			// A built package that failed has already been reported.
+			if failedPackages[instanceErr.Package.Package] {
+				continue
+			} else if keepGoing {
+				summary.note(variantName(instanceErr.Package.Target(), combination, matrix), instanceErr)
+			} else {
+				return instanceErr
+			}
+		}
+		for _, constraintErr := range evaluator.CheckConstraints(starverseDir, instances) {
+			if keepGoing {
+				summary.note(variantName(constraintErr.SchemaTarget, combination, matrix), constraintErr)
+			} else {
+				return constraintErr
 			}
 		}
 	}
 
-	fmt.Println(value2json(evaluatedOutput))
+	for pkg := range failedPackages {
+		delete(builtPackages, pkg)
+	}
+	if options.UpdateGolden {
+		err = updateGolden(starverseDir, config, evaluatedOutput, options.CheckGolden, builtPackages)
+	} else if options.CheckGolden != "" {
+		err = checkGolden(starverseDir, config, evaluatedOutput, options.CheckGolden, builtPackages)
+	} else {
+		err = writeOutput(starverseDir, config, evaluatedOutput)
+	}
+	if err != nil {
+		return err
+	}
 	if keepGoing {
 		printSummary(summary)
+	} else {
+		printSettings(summary)
 	}
 	return nil
 }
 
+// Reads the changed files from the file, or stdin when it is -.
+func readChangedFiles(
+	starverseDir string,
+	workingDir string,
+	universes map[string]string,
+	changedFilesPath string,
+) (evaluator.ChangedFiles, error) {
+	var data []byte
+	var err error
+	if changedFilesPath == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(changedFilesPath)
+	}
+	if err != nil {
+		return evaluator.ChangedFiles{}, fmt.Errorf(
+			"Unable to read changed files from %s: %s", changedFilesPath, err)
+	}
+	paths := strings.Split(string(data), "\n")
+	return evaluator.ParseChangedFiles(starverseDir, workingDir, universes, paths), nil
+}
+
+func parseDefines(defines []string) (map[string]string, error) {
+	settings := map[string]string{}
+	for _, define := range defines {
+		key, value, found := strings.Cut(define, "=")
+		if !found || key == "" {
+			return settings, fmt.Errorf("Invalid define %s. i.e. --define env=prod.", define)
+		}
+		settings[key] = value
+	}
+	return settings, nil
+}
+
+func parseMatrix(matrix []string) ([]starverse.MatrixAxis, error) {
+	axes := []starverse.MatrixAxis{}
+	for _, axis := range matrix {
+		key, values, found := strings.Cut(axis, "=")
+		if !found || key == "" || values == "" {
+			return axes, fmt.Errorf("Invalid matrix %s. i.e. --matrix env=dev,prod.", axis)
+		}
+		axes = append(axes, starverse.MatrixAxis{Key: key, Values: strings.Split(values, ",")})
+	}
+	return axes, nil
+}
+
+// The STARVERSE settings and matrix are defaults. A define overrides a setting or matrix axis
+// of the same key, and a matrix flag overrides a setting or matrix axis of the same key.
+func mergeSettings(
+	config starverse.Config,
+	defines map[string]string,
+	matrix []starverse.MatrixAxis,
+) (map[string]string, []starverse.MatrixAxis) {
+	flagAxes := map[string]bool{}
+	for _, axis := range matrix {
+		flagAxes[axis.Key] = true
+	}
+
+	settings := map[string]string{}
+	for key, value := range config.Settings {
+		if !flagAxes[key] {
+			settings[key] = value
+		}
+	}
+	for key, value := range defines {
+		settings[key] = value
+	}
+
+	axes := []starverse.MatrixAxis{}
+	for _, axis := range config.Matrix {
+		_, defined := defines[axis.Key]
+		if !defined && !flagAxes[axis.Key] {
+			delete(settings, axis.Key)
+			axes = append(axes, axis)
+		}
+	}
+	return settings, append(axes, matrix...)
+}
+
+// The name of a target or schema built with the settings in a matrix build.
+// i.e. //pkg:t[env=prod]
+// Outside of a matrix build the name is the label, and the settings are noted in the summary.
+func variantName(name string, settings map[string]string, matrix bool) string {
+	if !matrix || len(settings) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s[%s]", name, native.FormatSettings(settings))
+}
+
+// Prints the settings each target read, so the output of a build with --define can be traced
+// back to the settings it used without renaming the targets.
+func printSettings(summary buildResult) {
+	if len(summary.settings) == 0 {
+		return
+	}
+	names := []string{}
+	for name := range summary.settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Settings:")
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("  %s%s", name, summary.settingsSuffix(name)))
+	}
+}
+
 func printSummary(summary buildResult) {
 	count := summary.count()
 	countComponents := []string{"\nSummary:"}
@@ -69,9 +335,15 @@ func printSummary(summary buildResult) {
 	}
 	countComponents = append(countComponents, fmt.Sprintf("%d TOTAL", count.total))
 	fmt.Fprintln(os.Stderr, strings.Join(countComponents, " "))
-	for name, errs := range summary.results {
+	names := []string{}
+	for name := range summary.results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		errs := summary.results[name]
 		if len(*errs) == 0 {
-			fmt.Fprintln(os.Stderr, fmt.Sprintf("  %s %s", aurora.Green("  OK"), name))
+			fmt.Fprintln(os.Stderr, fmt.Sprintf("  %s %s%s", aurora.Green("  OK"), name, summary.settingsSuffix(name)))
 		} else {
 			errorMessages := util.JoinErrors(*errs, fmt.Sprintf("%s", aurora.Red("     * ")), "\n")
 			fmt.Fprintln(os.Stderr, fmt.Sprintf("  %s %s\n%s", aurora.Red("FAIL"), name, errorMessages))
@@ -81,6 +353,8 @@ func printSummary(summary buildResult) {
 
 func value2json(rawValue starlark.Value) string {
 	switch value := rawValue.(type) {
+	case starlark.NoneType:
+		return "null"
 	case starlark.Bool:
 		if value == starlark.True {
 			return "true"
@@ -108,6 +382,23 @@ func value2json(rawValue starlark.Value) string {
 
 type buildResult struct {
 	results map[string]*[]error
+	// The settings each target read outside of a matrix build, where they aren't in its name.
+	settings map[string]map[string]string
+}
+
+func (br buildResult) noteSettings(name string, settings map[string]string) {
+	if len(settings) > 0 {
+		br.settings[name] = settings
+	}
+}
+
+// The settings a target read, i.e. " [env=prod]".
+func (br buildResult) settingsSuffix(name string) string {
+	settings, found := br.settings[name]
+	if !found {
+		return ""
+	}
+	return fmt.Sprintf(" [%s]", native.FormatSettings(settings))
 }
 
 func (br buildResult) note(name string, err error) {
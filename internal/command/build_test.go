@@ -0,0 +1,89 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var releaseStarverse = map[string]string{
+	"deploy/defs.star": `
+Job = Schema(fields = {"name": String(), "env": String(), "replicas": Int()})
+`,
+	"deploy/STARFIG": `
+load("//deploy/defs.star", "Job")
+
+_env = select({"env:prod": "prod", "default": "dev"})
+
+web = Job(name = "web", env = _env)
+worker = Job(name = "worker", replicas = select({"region:eu": 2, "default": 1}))
+cron = Job(name = "cron")
+`,
+}
+
+func TestBuildDefineSettings(t *testing.T) {
+	makeStarverse(t, releaseStarverse)
+
+	var printed string
+	stderr := captureStderr(t, func() {
+		printed = captureStdout(t, func() {
+			err := Build([]string{"//deploy:..."}, BuildOptions{Defines: []string{"env=prod", "region=eu"}})
+			assert.Nil(t, err)
+		})
+	})
+	// The targets keep their labels, and the settings each one read are printed separately.
+	assert.Contains(t, printed, `"//deploy:web": {"name": "web", "env": "prod", "replicas": 0}`)
+	assert.Contains(t, printed, `"//deploy:worker": {"name": "worker", "env": "", "replicas": 2}`)
+	assert.Equal(t, "Settings:\n  //deploy:web [env=prod]\n  //deploy:worker [region=eu]\n", stderr)
+}
+
+func TestBuildDefineSettingsSummary(t *testing.T) {
+	makeStarverse(t, releaseStarverse)
+
+	stderr := captureStderr(t, func() {
+		captureStdout(t, func() {
+			err := Build([]string{"//deploy:..."}, BuildOptions{Defines: []string{"env=prod"}, KeepGoing: true})
+			assert.Nil(t, err)
+		})
+	})
+	assert.Contains(t, stderr, "m //deploy:cron\n")
+	assert.Contains(t, stderr, "m //deploy:web [env=prod]\n")
+	// A setting that isn't defined isn't noted.
+	assert.Contains(t, stderr, "m //deploy:worker\n")
+}
+
+var serviceStarverse = map[string]string{
+	"service/defs.star": `
+Service = Schema(fields = {"name": String(), "port": Int(unique = True)})
+`,
+	"service/STARFIG": `
+load("//service/defs.star", "Service")
+
+web = Service(name = "web", port = 8080)
+`,
+	"broken/STARFIG": `
+load("//service/defs.star", "Service")
+
+api = Service(name = "api", port = "8081")
+`,
+}
+
+func TestBuildConstraintsUnbuiltPackageFails(t *testing.T) {
+	makeStarverse(t, serviceStarverse)
+
+	// The broken package isn't built, but its instances might break the unique port.
+	var err error
+	captureStdout(t, func() {
+		err = Build([]string{"//service:..."}, BuildOptions{})
+	})
+	assert.ErrorContains(t, err, "Unable to check constraints since //broken:... failed to evaluate:")
+
+	stderr := captureStderr(t, func() {
+		captureStdout(t, func() {
+			err = Build([]string{"//service:..."}, BuildOptions{KeepGoing: true})
+		})
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, stderr, "m //broken:...\n")
+	assert.Contains(t, stderr, "Unable to check constraints since //broken:... failed to evaluate:")
+}
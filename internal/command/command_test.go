@@ -0,0 +1,61 @@
+package command
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a starverse with the files by their path, and runs the test from its root.
+func makeStarverse(t *testing.T, files map[string]string) string {
+	starverseDir, err := filepath.EvalSymlinks(t.TempDir())
+	assert.Nil(t, err)
+	if _, found := files["STARVERSE"]; !found {
+		files["STARVERSE"] = ""
+	}
+	for path, content := range files {
+		writeStarverseFile(t, starverseDir, path, content)
+	}
+
+	workingDir, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(starverseDir))
+	t.Cleanup(func() { os.Chdir(workingDir) })
+	return starverseDir
+}
+
+func writeStarverseFile(t *testing.T, starverseDir string, path string, content string) {
+	path = filepath.Join(starverseDir, path)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+// Returns what the function printed to stdout.
+func captureStdout(t *testing.T, run func()) string {
+	return captureOutput(t, &os.Stdout, run)
+}
+
+// Returns what the function printed to stderr.
+func captureStderr(t *testing.T, run func()) string {
+	return captureOutput(t, &os.Stderr, run)
+}
+
+func captureOutput(t *testing.T, output **os.File, run func()) string {
+	original := *output
+	reader, writer, err := os.Pipe()
+	assert.Nil(t, err)
+	*output = writer
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		printed <- string(data)
+	}()
+
+	defer func() { *output = original }()
+	run()
+	writer.Close()
+	return <-printed
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jathu/starfig/internal/starverse"
+)
+
+// DepsUpdate resolves the external universes and writes their hashes to the lock file.
+func DepsUpdate() error {
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+
+	resolved, err := starverse.ResolveUniverses(starverseDir, config.Universes)
+	if err != nil {
+		return err
+	}
+	lock, err := starverse.NewLock(config.Universes, resolved)
+	if err != nil {
+		return err
+	}
+	err = lock.Write(starverseDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, fmt.Sprintf("Updated %s with %d universes.", starverse.LockFilename, len(lock.Universes)))
+	return nil
+}
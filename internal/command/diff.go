@@ -0,0 +1,263 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/util"
+	"github.com/logrusorgru/aurora"
+	"go.starlark.net/starlark"
+)
+
+type DiffOptions struct {
+	// The git revision to compare the working tree with, i.e. main or HEAD~1.
+	Base string
+	// Build settings in the form of key=value, i.e. env=prod.
+	Defines []string
+}
+
+// Diff evaluates the targets in the working tree and at the base revision, which is checked out
+// to a temporary git worktree, and prints the fields that were added, removed or changed.
+func Diff(args []string, options DiffOptions) error {
+	if options.Base == "" {
+		return fmt.Errorf("Missing the revision to compare with. i.e. --base=main.")
+	}
+	if len(args) == 0 {
+		args = []string{"//..."}
+	}
+
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return err
+	}
+
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+	base, err := checkoutRevision(starverseDir, options.Base)
+	if err != nil {
+		return err
+	}
+	defer base.cleanup()
+	relativeWorkingDir, err := filepath.Rel(starverseDir, workingDir)
+	if err != nil {
+		return err
+	}
+
+	head, headPatternErrs, err := evaluateForDiff(starverseDir, config, universes, workingDir, args, defines)
+	if err != nil {
+		return err
+	}
+	baseEvaluated, basePatternErrs, err := evaluateForDiff(
+		base.starverseDir, base.config, base.universes,
+		filepath.Join(base.starverseDir, relativeWorkingDir), args, defines)
+	if err != nil {
+		return fmt.Errorf("Unable to evaluate %s: %s", options.Base, err)
+	}
+	err = checkDiffPatterns(headPatternErrs, basePatternErrs)
+	if err != nil {
+		return err
+	}
+
+	return printDiff(baseEvaluated, head)
+}
+
+// A pattern that doesn't resolve on one side matches a package that was added or deleted, but
+// a pattern that doesn't resolve on either side is invalid.
+func checkDiffPatterns(head []target.PatternError, base []target.PatternError) error {
+	failedInBase := map[string]bool{}
+	for _, patternErr := range base {
+		failedInBase[patternErr.Pattern] = true
+	}
+	for _, patternErr := range head {
+		if failedInBase[patternErr.Pattern] {
+			return patternErr
+		}
+	}
+	return nil
+}
+
+// A revision checked out to a temporary worktree.
+type checkout struct {
+	starverseDir string
+	config       starverse.Config
+	universes    map[string]string
+	// Removes the worktree.
+	cleanup func()
+}
+
+// Checks out the revision to a temporary worktree and loads the universe within it. External
+// universes outside of the repository, i.e. a sibling checkout at ../platform, are the ones
+// next to the working tree, since the worktree only has the files of the repository.
+func checkoutRevision(starverseDir string, revision string) (checkout, error) {
+	gitRoot, err := git(starverseDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return checkout{}, fmt.Errorf("The universe must be in a git repository: %s", err)
+	}
+	relativeStarverseDir, err := filepath.Rel(gitRoot, starverseDir)
+	if err != nil {
+		return checkout{}, err
+	}
+
+	worktreeDir, err := os.MkdirTemp("", "starfig-diff-")
+	if err != nil {
+		return checkout{}, err
+	}
+	cleanup := func() {
+		git(gitRoot, "worktree", "remove", "--force", worktreeDir)
+		os.RemoveAll(worktreeDir)
+	}
+	_, err = git(gitRoot, "worktree", "add", "--detach", worktreeDir, revision)
+	if err != nil {
+		cleanup()
+		return checkout{}, fmt.Errorf("Unable to check out %s: %s", revision, err)
+	}
+
+	baseStarverseDir := filepath.Join(worktreeDir, relativeStarverseDir)
+	if !util.PathExists(filepath.Join(baseStarverseDir, starverse.StarverseFilename)) {
+		cleanup()
+		return checkout{}, fmt.Errorf(
+			"%s does not have a %s at %s.", revision, starverse.StarverseFilename, relativeStarverseDir)
+	}
+	config, err := starverse.LoadConfig(baseStarverseDir)
+	if err != nil {
+		cleanup()
+		return checkout{}, fmt.Errorf("Unable to load STARVERSE at %s: %s", revision, err)
+	}
+	universes, err := starverse.ResolveCheckoutUniverses(
+		config.Universes, worktreeDir, baseStarverseDir, starverseDir)
+	if err == nil {
+		err = starverse.CheckLock(baseStarverseDir, config.Universes, universes)
+	}
+	if err != nil {
+		cleanup()
+		return checkout{}, fmt.Errorf("Unable to resolve the universes at %s: %s", revision, err)
+	}
+	return checkout{baseStarverseDir, config, universes, cleanup}, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("%s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Evaluates the targets of the universe by key. The errors of the patterns that don't resolve
+// are returned separately, since the package might only exist on one side of the diff.
+func evaluateForDiff(
+	starverseDir string,
+	config starverse.Config,
+	universes map[string]string,
+	workingDir string,
+	args []string,
+	defines map[string]string,
+) (map[string]starlark.Value, []target.PatternError, error) {
+	evaluated := map[string]starlark.Value{}
+
+	settings, axes := mergeSettings(config, defines, nil)
+	combinations, err := evaluator.ExpandMatrix(settings, axes)
+	if err != nil {
+		return evaluated, nil, err
+	}
+	ignore, err := target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return evaluated, nil, err
+	}
+	patterns, patternErrs := target.ParseBuildTargets(starverseDir, workingDir, args, ignore)
+
+	for _, combination := range combinations {
+		options := evaluator.Options{Settings: combination, Universes: universes}
+		for i, buildTarget := range patterns.Targets {
+			results, err := evaluator.EvaluateBuildTarget(starverseDir, buildTarget, options)
+			if err != nil {
+				return evaluated, patternErrs, err
+			}
+			for _, result := range results {
+				if !patterns.IsExcluded(i, result.Target) {
+					key := variantName(result.Key(), result.Settings, len(combinations) > 1)
+					evaluated[key] = result.Result.Evaluated
+				}
+			}
+		}
+	}
+	return evaluated, patternErrs, nil
+}
+
+func printDiff(base map[string]starlark.Value, head map[string]starlark.Value) error {
+	keys := []string{}
+	for key := range head {
+		keys = append(keys, key)
+	}
+	for key := range base {
+		if _, found := head[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changed := 0
+	for _, key := range keys {
+		baseValue, inBase := base[key]
+		headValue, inHead := head[key]
+		if !inBase {
+			changed += 1
+			fmt.Println(aurora.Green(fmt.Sprintf("+ %s", key)))
+			continue
+		} else if !inHead {
+			changed += 1
+			fmt.Println(aurora.Red(fmt.Sprintf("- %s", key)))
+			continue
+		}
+
+		changes, err := evaluator.DiffValues(baseValue, headValue)
+		if err != nil {
+			return err
+		} else if len(changes) == 0 {
+			continue
+		}
+		changed += 1
+		fmt.Println(aurora.Yellow(fmt.Sprintf("~ %s", key)))
+		for _, change := range changes {
+			fmt.Println(formatChange(change))
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, fmt.Sprintf("%d of %d targets changed.", changed, len(keys)))
+	return nil
+}
+
+func formatChange(change evaluator.FieldChange) string {
+	switch change.Kind {
+	case evaluator.Added:
+		return aurora.Green(fmt.Sprintf("    + %s: %s", change.Path, value2json(change.Head))).String()
+	case evaluator.Removed:
+		return aurora.Red(fmt.Sprintf("    - %s: %s", change.Path, value2json(change.Base))).String()
+	default:
+		return aurora.Yellow(fmt.Sprintf("    ~ %s: %s -> %s",
+			change.Path, value2json(change.Base), value2json(change.Head))).String()
+	}
+}
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a starverse with the files committed to a new git repository.
+func makeGitStarverse(t *testing.T, files map[string]string) string {
+	starverseDir := makeStarverse(t, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=starfig", "-c", "user.email=starfig@example.com", "commit", "-qm", "base"},
+	} {
+		_, err := git(starverseDir, args...)
+		assert.Nil(t, err)
+	}
+	return starverseDir
+}
+
+func TestDiffPatternOnOneSide(t *testing.T) {
+	starverseDir := makeGitStarverse(t, map[string]string{
+		"jobs/defs.star": `
+Job = Schema(fields = {"name": String()})
+`,
+		"jobs/STARFIG": `
+load("//jobs/defs.star", "Job")
+
+backfill = Job(name = "backfill")
+`,
+		"legacy/STARFIG": `
+load("//jobs/defs.star", "Job")
+
+reaper = Job(name = "reaper")
+`,
+	})
+	assert.Nil(t, os.RemoveAll(filepath.Join(starverseDir, "legacy")))
+
+	// The package only exists at the base revision, so it was deleted.
+	var err error
+	printed := captureStdout(t, func() {
+		err = Diff([]string{"//jobs:...", "//legacy:..."}, DiffOptions{Base: "HEAD"})
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, printed, "- //legacy:reaper")
+}
+
+func TestDiffPatternOnNeitherSide(t *testing.T) {
+	makeGitStarverse(t, map[string]string{
+		"jobs/defs.star": `
+Job = Schema(fields = {"name": String()})
+`,
+		"jobs/STARFIG": `
+load("//jobs/defs.star", "Job")
+
+backfill = Job(name = "backfill")
+`,
+	})
+
+	var err error
+	captureStdout(t, func() {
+		err = Diff([]string{"//jobs:...", "//missing:..."}, DiffOptions{Base: "HEAD"})
+	})
+	assert.EqualError(t, err, "STARFIG file for //missing:... does not exist.")
+}
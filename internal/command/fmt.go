@@ -0,0 +1,85 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jathu/starfig/internal/format"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+type FmtOptions struct {
+	// Print a diff of every file that isn't formatted and fail, instead of formatting them.
+	Check bool
+}
+
+// Fmt formats the .star and STARFIG files matching the patterns in place. Without patterns,
+// every file in the universe is formatted.
+func Fmt(args []string, options FmtOptions) error {
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+	ignore, err := target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return err
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"//..."}
+	}
+
+	files, patternErrs := target.ParseSourceTargets(starverseDir, workingDir, args, ignore)
+	if len(patternErrs) > 0 {
+		return patternErrs[0]
+	}
+
+	unformatted := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file.Path())
+		if err != nil {
+			return err
+		}
+		formatted, err := format.Source(file.Path(), src)
+		if err != nil {
+			return err
+		} else if string(formatted) == string(src) {
+			continue
+		}
+
+		unformatted += 1
+		if !options.Check {
+			err = os.WriteFile(file.Path(), formatted, 0644)
+			if err != nil {
+				return err
+			}
+			fmt.Println(file.Target())
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(string(src)),
+			B:        splitLines(string(formatted)),
+			FromFile: file.Target(),
+			ToFile:   fmt.Sprintf("%s (formatted)", file.Target()),
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+	}
+
+	if options.Check && unformatted > 0 {
+		return fmt.Errorf("%d of %d files are not formatted. Run starfig fmt to format them.", unformatted, len(files))
+	} else if options.Check {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("%d files are formatted.", len(files)))
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, fmt.Sprintf("%d of %d files formatted.", unformatted, len(files)))
+	return nil
+}
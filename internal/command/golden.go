@@ -0,0 +1,148 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.starlark.net/starlark"
+)
+
+// Compares each built target with its snapshot in the golden directory and prints a unified
+// diff of every mismatch. The snapshots use the same layout as the output directory. The
+// packages are the ones built in full, whose snapshots that weren't built are stale.
+func checkGolden(
+	starverseDir string, config starverse.Config, evaluatedOutput *starlark.Dict, goldenDir string,
+	packages map[string]bool) error {
+	rendered, err := renderTargets(starverseDir, config, evaluatedOutput)
+	if err != nil {
+		return err
+	}
+
+	mismatches := 0
+	for _, target := range rendered {
+		goldenPath := filepath.Join(goldenDir, target.filename)
+		golden, err := os.ReadFile(goldenPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		} else if string(golden) == target.content {
+			continue
+		}
+
+		mismatches += 1
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(string(golden)),
+			B:        splitLines(target.content),
+			FromFile: goldenPath,
+			ToFile:   target.key,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+	}
+
+	stale, err := staleGolden(starverseDir, goldenDir, rendered, packages)
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		fmt.Printf("Stale golden file %s has no built target.\n", path)
+	}
+
+	if mismatches > 0 || len(stale) > 0 {
+		return fmt.Errorf(
+			"%d of %d targets don't match the golden files in %s, and %d golden files are stale. Run with --update-golden if this is expected.",
+			mismatches, len(rendered), goldenDir, len(stale))
+	}
+	fmt.Fprintln(os.Stderr, fmt.Sprintf("%d targets match the golden files in %s.", len(rendered), goldenDir))
+	return nil
+}
+
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Writes each built target as its snapshot in the golden directory, and deletes the stale
+// snapshots.
+func updateGolden(
+	starverseDir string, config starverse.Config, evaluatedOutput *starlark.Dict, goldenDir string,
+	packages map[string]bool) error {
+	rendered, err := renderTargets(starverseDir, config, evaluatedOutput)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range rendered {
+		err = writeFile(filepath.Join(goldenDir, target.filename), target.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	stale, err := staleGolden(starverseDir, goldenDir, rendered, packages)
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+		// The directories of a deleted package are removed once they are empty.
+		for dir := filepath.Dir(path); dir != filepath.Clean(goldenDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, fmt.Sprintf(
+		"Updated %d golden files and deleted %d stale golden files in %s.", len(rendered), len(stale), goldenDir))
+	return nil
+}
+
+// The snapshots in the golden directory without a built target, which are in a package that
+// was built in full or in a package that no longer exists. Snapshots of other packages are
+// kept, since building a subset of the universe doesn't make them stale.
+func staleGolden(
+	starverseDir string, goldenDir string, rendered []renderedTarget, packages map[string]bool) ([]string, error) {
+	built := map[string]bool{}
+	for _, output := range rendered {
+		built[filepath.Clean(output.filename)] = true
+	}
+
+	stale := []string{}
+	err := filepath.WalkDir(goldenDir, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == goldenDir {
+			return filepath.SkipDir
+		} else if err != nil || entry.IsDir() {
+			return err
+		}
+		filename, err := filepath.Rel(goldenDir, path)
+		if err != nil || built[filename] {
+			return err
+		}
+		pkg := filepath.ToSlash(filepath.Dir(filename))
+		if pkg == "." {
+			pkg = ""
+		}
+		_, statErr := os.Stat(filepath.Join(starverseDir, pkg, target.StarfigFilename))
+		if packages[pkg] || os.IsNotExist(statErr) {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	sort.Strings(stale)
+	return stale, err
+}
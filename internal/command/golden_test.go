@@ -0,0 +1,136 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jobStarverse = map[string]string{
+	"jobs/defs.star": `
+Job = Schema(fields = {"name": String(), "retries": Int()})
+`,
+	"jobs/STARFIG": `
+load("//jobs/defs.star", "Job")
+
+backfill = Job(name = "backfill", retries = 3)
+cleanup = Job(name = "cleanup")
+`,
+}
+
+const backfillGolden = `{
+  "name": "backfill",
+  "retries": 3
+}
+`
+
+func makeJobStarverse(t *testing.T) string {
+	files := map[string]string{}
+	for path, content := range jobStarverse {
+		files[path] = content
+	}
+	return makeStarverse(t, files)
+}
+
+func readGolden(t *testing.T, starverseDir string, path string) string {
+	data, err := os.ReadFile(filepath.Join(starverseDir, "golden", path))
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	starverseDir := makeJobStarverse(t)
+
+	err := Build([]string{"//..."}, BuildOptions{CheckGolden: "golden", UpdateGolden: true})
+	assert.Nil(t, err)
+	assert.Equal(t, backfillGolden, readGolden(t, starverseDir, "jobs/backfill.json"))
+	assert.Equal(t, "{\n  \"name\": \"cleanup\",\n  \"retries\": 0\n}\n",
+		readGolden(t, starverseDir, "jobs/cleanup.json"))
+}
+
+func TestGoldenMatch(t *testing.T) {
+	makeJobStarverse(t)
+	assert.Nil(t, Build([]string{"//..."}, BuildOptions{CheckGolden: "golden", UpdateGolden: true}))
+
+	printed := captureStdout(t, func() {
+		assert.Nil(t, Build([]string{"//..."}, BuildOptions{CheckGolden: "golden"}))
+	})
+	assert.Equal(t, "", printed)
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	starverseDir := makeJobStarverse(t)
+	assert.Nil(t, Build([]string{"//..."}, BuildOptions{CheckGolden: "golden", UpdateGolden: true}))
+	writeStarverseFile(t, starverseDir, "jobs/STARFIG", `
+load("//jobs/defs.star", "Job")
+
+backfill = Job(name = "backfill", retries = 5)
+cleanup = Job(name = "cleanup")
+`)
+
+	var err error
+	printed := captureStdout(t, func() {
+		err = Build([]string{"//..."}, BuildOptions{CheckGolden: "golden"})
+	})
+	assert.EqualError(t, err,
+		"1 of 2 targets don't match the golden files in golden, and 0 golden files are stale. Run with --update-golden if this is expected.")
+	assert.Equal(t, `--- golden/jobs/backfill.json
++++ //jobs:backfill
+@@ -1,4 +1,4 @@
+ {
+   "name": "backfill",
+-  "retries": 3
++  "retries": 5
+ }
+`, printed)
+}
+
+func TestGoldenMissing(t *testing.T) {
+	makeJobStarverse(t)
+	assert.Nil(t, Build([]string{"//jobs:backfill"}, BuildOptions{CheckGolden: "golden", UpdateGolden: true}))
+
+	var err error
+	printed := captureStdout(t, func() {
+		err = Build([]string{"//..."}, BuildOptions{CheckGolden: "golden"})
+	})
+	assert.EqualError(t, err,
+		"1 of 2 targets don't match the golden files in golden, and 0 golden files are stale. Run with --update-golden if this is expected.")
+	assert.Contains(t, printed, "--- golden/jobs/cleanup.json\n+++ //jobs:cleanup\n")
+	assert.Contains(t, printed, "+  \"name\": \"cleanup\",\n")
+}
+
+func TestGoldenStale(t *testing.T) {
+	starverseDir := makeJobStarverse(t)
+	writeStarverseFile(t, starverseDir, "legacy/STARFIG", `
+load("//jobs/defs.star", "Job")
+
+reaper = Job(name = "reaper")
+`)
+	assert.Nil(t, Build([]string{"//..."}, BuildOptions{CheckGolden: "golden", UpdateGolden: true}))
+	writeStarverseFile(t, starverseDir, "jobs/STARFIG", `
+load("//jobs/defs.star", "Job")
+
+backfill = Job(name = "backfill", retries = 3)
+`)
+
+	// Building a single target doesn't make the other golden files of its package stale.
+	assert.Nil(t, Build([]string{"//jobs:backfill"}, BuildOptions{CheckGolden: "golden"}))
+	assert.Nil(t, os.Remove(filepath.Join(starverseDir, "legacy", "STARFIG")))
+
+	var err error
+	printed := captureStdout(t, func() {
+		err = Build([]string{"//..."}, BuildOptions{CheckGolden: "golden"})
+	})
+	assert.EqualError(t, err,
+		"0 of 1 targets don't match the golden files in golden, and 2 golden files are stale. Run with --update-golden if this is expected.")
+	assert.Equal(t, "Stale golden file golden/jobs/cleanup.json has no built target.\n"+
+		"Stale golden file golden/legacy/reaper.json has no built target.\n", printed)
+
+	assert.Nil(t, Build([]string{"//..."}, BuildOptions{CheckGolden: "golden", UpdateGolden: true}))
+	assert.NoFileExists(t, filepath.Join(starverseDir, "golden", "jobs", "cleanup.json"))
+	assert.NoDirExists(t, filepath.Join(starverseDir, "golden", "legacy"))
+	assert.Equal(t, backfillGolden, readGolden(t, starverseDir, "jobs/backfill.json"))
+	assert.Nil(t, Build([]string{"//..."}, BuildOptions{CheckGolden: "golden"}))
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jathu/starfig/internal/lint"
+	"github.com/jathu/starfig/internal/target"
+)
+
+type LintOptions struct {
+	// The output format: text or json.
+	Output string
+}
+
+// Lint checks the .star and STARFIG files matching the patterns for mistakes the evaluator
+// doesn't catch. Without patterns, every file in the universe is checked.
+func Lint(args []string, options LintOptions) error {
+	err := lint.ValidateOutput(options.Output)
+	if err != nil {
+		return err
+	}
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+	ignore, err := target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return err
+	}
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"//..."}
+	}
+
+	// Every file is parsed, since some checks depend on the rest of the universe.
+	allFiles, patternErrs := target.ParseSourceTargets(starverseDir, workingDir, []string{"//..."}, ignore)
+	if len(patternErrs) > 0 {
+		return patternErrs[0]
+	}
+	files, patternErrs := target.ParseSourceTargets(starverseDir, workingDir, args, ignore)
+	if len(patternErrs) > 0 {
+		return patternErrs[0]
+	}
+
+	linter := lint.NewLinter(starverseDir, universes, allFiles)
+	findings := []lint.Finding{}
+	filesWithFindings := 0
+	for _, file := range files {
+		fileFindings := linter.Lint(file)
+		if len(fileFindings) > 0 {
+			filesWithFindings += 1
+		}
+		findings = append(findings, fileFindings...)
+	}
+
+	if len(findings) > 0 || options.Output == lint.JSONOutput {
+		output, err := lint.FormatFindings(findings, options.Output)
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+	}
+	if filesWithFindings > 0 {
+		return fmt.Errorf("%d of %d files have lint findings.", filesWithFindings, len(files))
+	}
+	fmt.Fprintln(os.Stderr, fmt.Sprintf("%d files are lint free.", len(files)))
+	return nil
+}
@@ -0,0 +1,34 @@
+package command
+
+import (
+	"os"
+
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/lsp"
+)
+
+type LspOptions struct {
+	// Build settings in the form of key=value, i.e. env=prod.
+	Defines []string
+}
+
+// Lsp runs a language server over stdin and stdout until the editor exits.
+func Lsp(options LspOptions) error {
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return err
+	}
+	settings, _ := mergeSettings(config, defines, nil)
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+
+	server := lsp.NewServer(starverseDir, evaluator.Options{Settings: settings, Universes: universes}, os.Stdin, os.Stdout)
+	return server.Serve()
+}
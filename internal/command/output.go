@@ -0,0 +1,126 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jathu/starfig/internal/starverse"
+	"go.starlark.net/starlark"
+)
+
+var outputKeyPattern = regexp.MustCompile(`^//(.*):([^\[]+)(\[(.*)\])?$`)
+
+// Prints the build output, or writes each built target to the output directory if one is
+// set in the STARVERSE config. The output is rendered by the generator of the output format.
+func writeOutput(starverseDir string, config starverse.Config, evaluatedOutput *starlark.Dict) error {
+	if config.OutputDir == "" {
+		output, err := generate(starverseDir, config, value2json(evaluatedOutput))
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+		return nil
+	}
+
+	outputDir := config.OutputDir
+	if !filepath.IsAbs(outputDir) {
+		outputDir = filepath.Join(starverseDir, outputDir)
+	}
+
+	rendered, err := renderTargets(starverseDir, config, evaluatedOutput)
+	if err != nil {
+		return err
+	}
+	for _, target := range rendered {
+		err = writeFile(filepath.Join(outputDir, target.filename), target.content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type renderedTarget struct {
+	key string
+	// The path of the target relative to the output directory.
+	filename string
+	content  string
+}
+
+// Renders each built target on its own, as it is written to the output directory. JSON is
+// indented so a change to a single field is a single line of a diff.
+func renderTargets(
+	starverseDir string, config starverse.Config, evaluatedOutput *starlark.Dict) ([]renderedTarget, error) {
+	rendered := []renderedTarget{}
+	for _, tuple := range evaluatedOutput.Items() {
+		key := tuple.Index(0).(starlark.String).GoString()
+		output, err := generate(starverseDir, config, value2json(tuple.Index(1)))
+		if err != nil {
+			return rendered, fmt.Errorf("Unable to generate %s: %s", key, err)
+		}
+		if config.OutputFormat == starverse.DefaultOutputFormat {
+			var indented bytes.Buffer
+			err = json.Indent(&indented, []byte(output), "", "  ")
+			if err != nil {
+				return rendered, fmt.Errorf("Unable to generate %s: %s", key, err)
+			}
+			output = indented.String()
+		}
+		rendered = append(rendered, renderedTarget{
+			key:      key,
+			filename: outputFilename(key, config.OutputFormat),
+			content:  output + "\n",
+		})
+	}
+	return rendered, nil
+}
+
+func writeFile(path string, content string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// The path of a built target within the output directory, where build settings are a
+// suffix of the name. i.e. //jobs:backfill[env=prod] -> jobs/backfill.env=prod.json
+func outputFilename(key string, format string) string {
+	match := outputKeyPattern.FindStringSubmatch(key)
+	if match == nil {
+		return fmt.Sprintf("%s.%s", key, format)
+	}
+	name := match[2]
+	if match[4] != "" {
+		name = fmt.Sprintf("%s.%s", name, strings.ReplaceAll(match[4], ",", "."))
+	}
+	return filepath.Join(match[1], fmt.Sprintf("%s.%s", name, format))
+}
+
+// Generators receive the json on stdin and print the generated output. They run from the
+// universe root.
+func generate(starverseDir string, config starverse.Config, json string) (string, error) {
+	if config.OutputFormat == starverse.DefaultOutputFormat {
+		return json, nil
+	}
+
+	command := config.Generators[config.OutputFormat]
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = starverseDir
+	cmd.Stdin = strings.NewReader(json)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("Generator %s failed: %s %s",
+			config.OutputFormat, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
@@ -0,0 +1,225 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/query"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/sirupsen/logrus"
+)
+
+type QueryOptions struct {
+	// The output format: label, json or graph.
+	Output string
+	// Build settings in the form of key=value, i.e. env=prod.
+	Defines []string
+	// Skip targets that fail to evaluate instead of failing the query.
+	KeepGoing bool
+}
+
+// The universe, evaluated with its dependency graph recorded.
+type queryContext struct {
+	starverseDir string
+	workingDir   string
+	ignore       target.Ignore
+	combinations []map[string]string
+	universes    map[string]string
+	graph        *native.DependencyGraph
+	keepGoing    bool
+}
+
+func Query(rawExpression string, options QueryOptions) error {
+	err := query.ValidateOutput(options.Output)
+	if err != nil {
+		return err
+	}
+	expression, err := query.ParseExpression(rawExpression)
+	if err != nil {
+		return err
+	}
+
+	context, err := newQueryContext(options)
+	if err != nil {
+		return err
+	}
+
+	var output string
+	switch expression.Function {
+	case "deps":
+		output, err = context.deps(expression.Args[0], options.Output)
+	case "rdeps":
+		output, err = context.rdeps(expression.Args[0], expression.Args[1], options.Output)
+	case "schema":
+		output, err = context.schema(expression.Args[0], options.Output)
+	default:
+		output, err = context.targets(expression.Args[0], options.Output)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(output)
+	return nil
+}
+
+func newQueryContext(options QueryOptions) (queryContext, error) {
+	context := queryContext{graph: native.NewDependencyGraph(), keepGoing: options.KeepGoing}
+
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return context, err
+	}
+	context.starverseDir = starverseDir
+
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return context, err
+	}
+	// Every combination of the matrix is evaluated, since each can load different files.
+	settings, axes := mergeSettings(config, defines, nil)
+	context.combinations, err = evaluator.ExpandMatrix(settings, axes)
+	if err != nil {
+		return context, err
+	}
+	context.universes, err = resolveUniverses(starverseDir, config)
+	if err != nil {
+		return context, err
+	}
+	context.ignore, err = target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return context, err
+	}
+	context.workingDir, err = os.Getwd()
+	return context, err
+}
+
+// Evaluates the targets matching the pattern, which records their dependencies in the graph.
+func (context queryContext) evaluate(pattern string) ([]evaluator.EvaluateResult, error) {
+	patterns, patternErrs := target.ParseBuildTargets(
+		context.starverseDir, context.workingDir, []string{pattern}, context.ignore)
+	if len(patternErrs) > 0 {
+		return []evaluator.EvaluateResult{}, patternErrs[0]
+	}
+
+	results := []evaluator.EvaluateResult{}
+	seen := map[string]bool{}
+	for _, combination := range context.combinations {
+		options := evaluator.Options{
+			Settings:  combination,
+			Universes: context.universes,
+			Graph:     context.graph,
+		}
+		for i, buildTarget := range patterns.Targets {
+			evaluateResults, err := evaluator.EvaluateBuildTarget(context.starverseDir, buildTarget, options)
+			if err != nil {
+				if !context.keepGoing {
+					return results, err
+				}
+				logrus.Warn(err)
+			}
+			for _, result := range evaluateResults {
+				label := result.Target.Target()
+				if !patterns.IsExcluded(i, result.Target) && !seen[label] {
+					results = append(results, result)
+					seen[label] = true
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+func resultLabels(results []evaluator.EvaluateResult) []string {
+	labels := []string{}
+	for _, result := range results {
+		labels = append(labels, result.Target.Target())
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func (context queryContext) targets(pattern string, output string) (string, error) {
+	results, err := context.evaluate(pattern)
+	if err != nil {
+		return "", err
+	}
+	return query.FormatLabels(resultLabels(results), output, context.graph)
+}
+
+func (context queryContext) deps(pattern string, output string) (string, error) {
+	results, err := context.evaluate(pattern)
+	if err != nil {
+		return "", err
+	}
+	return query.FormatLabels(context.graph.Deps(resultLabels(results)), output, context.graph)
+}
+
+// The targets in the universe pattern that depend on the file or build target. The graph
+// output also includes the dependencies in between, which explains why a target is affected.
+func (context queryContext) rdeps(pattern string, rawLabel string, output string) (string, error) {
+	label, err := context.normalizeLabel(rawLabel)
+	if err != nil {
+		return "", err
+	}
+	results, err := context.evaluate(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	affected := []string{}
+	for _, resultLabel := range resultLabels(results) {
+		if context.graph.DependsOn(resultLabel, label) {
+			affected = append(affected, resultLabel)
+		}
+	}
+	if output != query.GraphOutput {
+		return query.FormatLabels(affected, output, context.graph)
+	}
+
+	path := []string{}
+	for _, node := range context.graph.Deps(affected) {
+		if context.graph.DependsOn(node, label) {
+			path = append(path, node)
+		}
+	}
+	return query.FormatLabels(path, output, context.graph)
+}
+
+func (context queryContext) schema(rawTarget string, output string) (string, error) {
+	results, err := context.evaluate(rawTarget)
+	if err != nil {
+		return "", err
+	} else if len(results) != 1 {
+		return "", fmt.Errorf("schema expects a single build target, but %s matched %d.",
+			rawTarget, len(results))
+	}
+	result := results[0]
+	info := query.NewSchemaInfo(result.Target.Target(), result.SchemaTarget, result.Result.SchemaDescriptor)
+	return query.FormatSchema(info, output)
+}
+
+// File labels and build targets are normalized to the labels used in the graph.
+func (context queryContext) normalizeLabel(rawLabel string) (string, error) {
+	if strings.Contains(rawLabel, ":") {
+		buildTargets, err := target.ParseBuildTarget(context.starverseDir, rawLabel, target.Ignore{})
+		if err != nil {
+			return "", err
+		} else if len(buildTargets) != 1 || buildTargets[0].TargetName == "..." {
+			return "", fmt.Errorf("%s must be a single file or build target.", rawLabel)
+		}
+		return buildTargets[0].Target(), nil
+	}
+
+	fileTarget, err := target.ParseFileTarget(context.starverseDir, "", rawLabel)
+	if err != nil {
+		return "", err
+	} else if fileTarget.Repository == "" && !fileTarget.Exists() {
+		return "", fmt.Errorf("%s does not exist.", rawLabel)
+	}
+	return fileTarget.Target(), nil
+}
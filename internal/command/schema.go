@@ -0,0 +1,149 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jathu/starfig/internal/compat"
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/util"
+	"github.com/logrusorgru/aurora"
+)
+
+type SchemaOptions struct {
+	// A schema snapshot file or a git revision to compare the schemas with. Only used by check.
+	Against string
+	// Build settings in the form of key=value, i.e. env=prod.
+	Defines []string
+}
+
+// SchemaSnapshot prints the shape of every schema in the universe as JSON, which can be
+// checked in and used with schema check --against.
+func SchemaSnapshot(options SchemaOptions) error {
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return err
+	}
+
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+	shapes, err := extractSchemas(starverseDir, config, universes, defines)
+	if err != nil {
+		return err
+	}
+	data, err := compat.NewSnapshot(shapes).Marshal()
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// SchemaCheck compares the schemas in the working tree with a snapshot or a git revision, and
+// fails when any of the changes are breaking according to the schema_policy of STARVERSE.
+func SchemaCheck(options SchemaOptions) error {
+	if options.Against == "" {
+		return fmt.Errorf(
+			"Missing the snapshot or revision to compare with. i.e. --against=schemas.json or --against=main.")
+	}
+
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+	policy, err := compat.NewPolicy(config.SchemaPolicy)
+	if err != nil {
+		return fmt.Errorf("Invalid schema_policy: %s", err)
+	}
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return err
+	}
+
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+	head, err := extractSchemas(starverseDir, config, universes, defines)
+	if err != nil {
+		return err
+	}
+	base, err := againstSchemas(starverseDir, options.Against, defines)
+	if err != nil {
+		return err
+	}
+
+	changes := compat.CompareSchemas(base, head)
+	breaking := 0
+	for _, change := range changes {
+		if policy.IsBreaking(change) {
+			breaking += 1
+			fmt.Println(aurora.Red(fmt.Sprintf("%s %s", compat.Breaking, change)))
+		} else {
+			fmt.Println(aurora.Green(fmt.Sprintf("%s %s", compat.Compatible, change)))
+		}
+	}
+
+	if breaking > 0 {
+		return fmt.Errorf("%d of %d schema changes are breaking.", breaking, len(changes))
+	}
+	fmt.Fprintln(os.Stderr, fmt.Sprintf("%d schema changes, none are breaking.", len(changes)))
+	return nil
+}
+
+// The schemas to compare with are read from the snapshot file if it exists, otherwise the
+// revision is checked out and its schemas are extracted.
+func againstSchemas(
+	starverseDir string, against string, defines map[string]string) (map[string]native.SchemaShape, error) {
+	if util.PathExists(against) {
+		data, err := os.ReadFile(against)
+		if err != nil {
+			return map[string]native.SchemaShape{}, err
+		}
+		snapshot, err := compat.ParseSnapshot(data)
+		if err != nil {
+			return map[string]native.SchemaShape{}, fmt.Errorf("%s: %s", against, err)
+		}
+		return snapshot.Shapes(), nil
+	}
+
+	base, err := checkoutRevision(starverseDir, against)
+	if err != nil {
+		return map[string]native.SchemaShape{}, err
+	}
+	defer base.cleanup()
+	shapes, err := extractSchemas(base.starverseDir, base.config, base.universes, defines)
+	if err != nil {
+		return shapes, fmt.Errorf("Unable to extract the schemas at %s: %s", against, err)
+	}
+	return shapes, nil
+}
+
+func extractSchemas(
+	starverseDir string,
+	config starverse.Config,
+	universes map[string]string,
+	defines map[string]string,
+) (map[string]native.SchemaShape, error) {
+	settings, _ := mergeSettings(config, defines, nil)
+	ignore, err := target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return map[string]native.SchemaShape{}, err
+	}
+	files, err := target.FindStarFiles(starverseDir, ignore)
+	if err != nil {
+		return map[string]native.SchemaShape{}, err
+	}
+
+	options := evaluator.Options{Settings: settings, Universes: universes}
+	return evaluator.ExtractSchemas(starverseDir, files, options)
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"github.com/jathu/starfig/internal/starverse"
+)
+
+// Populated by main with the version of the running starfig.
+var StarfigVersion string = "dev"
+
+// Finds the universe of the working directory and loads its STARVERSE config.
+func loadStarverse() (string, starverse.Config, error) {
+	starverseDir, err := starverse.FindStarverseDirectory()
+	if err != nil {
+		return "", starverse.Config{}, err
+	}
+
+	config, err := starverse.LoadConfig(starverseDir)
+	if err != nil {
+		return starverseDir, config, err
+	}
+
+	return starverseDir, config, config.CheckVersion(StarfigVersion)
+}
+
+// Resolves the external universes of the STARVERSE config, which must match the lock file.
+func resolveUniverses(starverseDir string, config starverse.Config) (map[string]string, error) {
+	resolved, err := starverse.ResolveUniverses(starverseDir, config.Universes)
+	if err != nil {
+		return resolved, err
+	}
+	return resolved, starverse.CheckLock(starverseDir, config.Universes, resolved)
+}
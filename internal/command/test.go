@@ -0,0 +1,63 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/target"
+)
+
+type TestOptions struct {
+	// Build settings in the form of key=value, i.e. env=prod.
+	Defines []string
+}
+
+// Test runs the test functions of the *_test.star files matching the patterns, and prints a
+// summary of every test.
+func Test(args []string, options TestOptions) error {
+	starverseDir, config, err := loadStarverse()
+	if err != nil {
+		return err
+	}
+
+	defines, err := parseDefines(options.Defines)
+	if err != nil {
+		return err
+	}
+	settings, _ := mergeSettings(config, defines, nil)
+	universes, err := resolveUniverses(starverseDir, config)
+	if err != nil {
+		return err
+	}
+	ignore, err := target.LoadIgnore(starverseDir, config.Ignore)
+	if err != nil {
+		return err
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	summary := buildResult{results: map[string]*[]error{}}
+	testFiles, patternErrs := target.ParseTestTargets(starverseDir, workingDir, args, ignore)
+	for _, patternErr := range patternErrs {
+		summary.note(patternErr.Pattern, patternErr)
+	}
+
+	evaluatorOptions := evaluator.Options{Settings: settings, Universes: universes}
+	for _, testFile := range testFiles {
+		for _, result := range evaluator.RunTests(starverseDir, testFile, evaluatorOptions) {
+			summary.note(result.Name, result.Err)
+		}
+	}
+
+	printSummary(summary)
+	count := summary.count()
+	if count.total == 0 {
+		return fmt.Errorf("No tests found. Test files end with %s.", target.TestFileSuffix)
+	} else if count.failed > 0 {
+		return fmt.Errorf("%d of %d tests failed.", count.failed, count.total)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+)
+
+type ChangeKind string
+
+const (
+	SchemaAdded        ChangeKind = "schema-added"
+	SchemaRemoved      ChangeKind = "schema-removed"
+	FieldAdded         ChangeKind = "field-added"
+	RequiredFieldAdded ChangeKind = "required-field-added"
+	FieldRemoved       ChangeKind = "field-removed"
+	TypeChanged        ChangeKind = "type-changed"
+	SchemaChanged      ChangeKind = "schema-changed"
+	InlineChanged      ChangeKind = "inline-changed"
+	MadeRequired       ChangeKind = "made-required"
+	MadeOptional       ChangeKind = "made-optional"
+)
+
+const (
+	Breaking   string = "breaking"
+	Compatible string = "compatible"
+)
+
+// Policy classifies each kind of change as breaking or compatible.
+type Policy map[ChangeKind]string
+
+// DefaultPolicy treats a change as breaking when an existing config might no longer evaluate,
+// or when a consumer of the output might no longer be able to read it.
+func DefaultPolicy() Policy {
+	return Policy{
+		SchemaAdded:        Compatible,
+		SchemaRemoved:      Breaking,
+		FieldAdded:         Compatible,
+		RequiredFieldAdded: Breaking,
+		FieldRemoved:       Breaking,
+		TypeChanged:        Breaking,
+		SchemaChanged:      Breaking,
+		InlineChanged:      Breaking,
+		MadeRequired:       Breaking,
+		MadeOptional:       Compatible,
+	}
+}
+
+// NewPolicy overrides the default policy, i.e. {"made-optional": "breaking"}.
+func NewPolicy(overrides map[string]string) (Policy, error) {
+	policy := DefaultPolicy()
+	for kind, classification := range overrides {
+		if _, found := policy[ChangeKind(kind)]; !found {
+			kinds := []string{}
+			for known := range policy {
+				kinds = append(kinds, string(known))
+			}
+			sort.Strings(kinds)
+			return policy, fmt.Errorf(
+				"Unknown schema change %s. Expected one of %s.", kind, strings.Join(kinds, ", "))
+		}
+		if classification != Breaking && classification != Compatible {
+			return policy, fmt.Errorf(
+				"Expected %s to be %s or %s, but got %s.", kind, Breaking, Compatible, classification)
+		}
+		policy[ChangeKind(kind)] = classification
+	}
+	return policy, nil
+}
+
+func (policy Policy) IsBreaking(change Change) bool {
+	return policy[change.Kind] == Breaking
+}
+
+// Change is a difference between two versions of a schema. The field is empty for changes to
+// the whole schema, list items are suffixed with [], i.e. colors[], and the fields of a nested
+// schema are joined by dots, i.e. owner.name.
+type Change struct {
+	Schema string
+	Field  string
+	Kind   ChangeKind
+	Detail string
+}
+
+func (change Change) String() string {
+	name := change.Schema
+	if change.Field != "" {
+		name = fmt.Sprintf("%s.%s", change.Schema, change.Field)
+	}
+	if change.Detail == "" {
+		return fmt.Sprintf("%s: %s", name, change.Kind)
+	}
+	return fmt.Sprintf("%s: %s (%s)", name, change.Kind, change.Detail)
+}
+
+// CompareSchemas compares the schemas by target, and returns the changes sorted by schema.
+func CompareSchemas(base map[string]native.SchemaShape, head map[string]native.SchemaShape) []Change {
+	names := []string{}
+	for name := range base {
+		names = append(names, name)
+	}
+	for name := range head {
+		if _, found := base[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []Change{}
+	for _, name := range names {
+		baseShape, inBase := base[name]
+		headShape, inHead := head[name]
+		if !inBase {
+			changes = append(changes, Change{Schema: name, Kind: SchemaAdded})
+		} else if !inHead {
+			changes = append(changes, Change{Schema: name, Kind: SchemaRemoved})
+		} else {
+			changes = append(changes, compareFields(name, "", baseShape.Fields, headShape.Fields)...)
+		}
+	}
+	return changes
+}
+
+// Compares the fields of a schema, or of a schema nested in the field at the path.
+func compareFields(schema string, path string, base []native.FieldShape, head []native.FieldShape) []Change {
+	headFields := map[string]native.FieldShape{}
+	for _, field := range head {
+		headFields[field.Name] = field
+	}
+	baseFields := map[string]native.FieldShape{}
+	for _, field := range base {
+		baseFields[field.Name] = field
+	}
+
+	changes := []Change{}
+	for _, field := range base {
+		fieldPath := path + field.Name
+		headField, found := headFields[field.Name]
+		if !found {
+			changes = append(changes, Change{Schema: schema, Field: fieldPath, Kind: FieldRemoved})
+		} else {
+			changes = append(changes, compareField(schema, fieldPath, field, headField)...)
+		}
+	}
+	for _, field := range head {
+		if _, found := baseFields[field.Name]; found {
+			continue
+		}
+		kind := FieldAdded
+		if field.Required {
+			kind = RequiredFieldAdded
+		}
+		changes = append(changes, Change{Schema: schema, Field: path + field.Name, Kind: kind})
+	}
+	return changes
+}
+
+func compareField(schema string, path string, base native.FieldShape, head native.FieldShape) []Change {
+	if base.Type != head.Type {
+		return []Change{{
+			Schema: schema,
+			Field:  path,
+			Kind:   TypeChanged,
+			Detail: fmt.Sprintf("%s -> %s", base.Type, head.Type),
+		}}
+	}
+
+	// A nested schema is named by the field it is in, so it is the same schema as long as it is
+	// still nested, and only its fields are compared.
+	nested := base.Nested && head.Nested
+	changes := []Change{}
+	if base.Schema != head.Schema && !nested {
+		changes = append(changes, Change{
+			Schema: schema,
+			Field:  path,
+			Kind:   SchemaChanged,
+			Detail: fmt.Sprintf("%s -> %s", base.Schema, head.Schema),
+		})
+	}
+	if base.Inline != head.Inline {
+		changes = append(changes, Change{
+			Schema: schema,
+			Field:  path,
+			Kind:   InlineChanged,
+			Detail: fmt.Sprintf("inline %t -> %t", base.Inline, head.Inline),
+		})
+	}
+	if !base.Required && head.Required {
+		changes = append(changes, Change{Schema: schema, Field: path, Kind: MadeRequired})
+	} else if base.Required && !head.Required {
+		changes = append(changes, Change{Schema: schema, Field: path, Kind: MadeOptional})
+	}
+	if base.Item != nil && head.Item != nil {
+		changes = append(changes, compareField(schema, fmt.Sprintf("%s[]", path), *base.Item, *head.Item)...)
+	}
+	if nested {
+		changes = append(changes, compareFields(schema, fmt.Sprintf("%s.", path), base.Fields, head.Fields)...)
+	}
+	return changes
+}
+
+// MARK: - Snapshot
+
+// Snapshot is the shape of every schema of a universe, which can be checked in and compared
+// against instead of a git revision.
+type Snapshot struct {
+	Schemas []native.SchemaShape `json:"schemas"`
+}
+
+func NewSnapshot(shapes map[string]native.SchemaShape) Snapshot {
+	snapshot := Snapshot{Schemas: []native.SchemaShape{}}
+	for _, shape := range shapes {
+		snapshot.Schemas = append(snapshot.Schemas, shape)
+	}
+	sort.Slice(snapshot.Schemas, func(i, j int) bool {
+		return snapshot.Schemas[i].Name < snapshot.Schemas[j].Name
+	})
+	return snapshot
+}
+
+func (snapshot Snapshot) Shapes() map[string]native.SchemaShape {
+	shapes := map[string]native.SchemaShape{}
+	for _, shape := range snapshot.Schemas {
+		shapes[shape.Name] = shape
+	}
+	return shapes
+}
+
+func (snapshot Snapshot) Marshal() ([]byte, error) {
+	return json.MarshalIndent(snapshot, "", "  ")
+}
+
+func ParseSnapshot(data []byte) (Snapshot, error) {
+	snapshot := Snapshot{}
+	err := json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return snapshot, fmt.Errorf("Invalid schema snapshot: %s", err)
+	}
+	return snapshot, nil
+}
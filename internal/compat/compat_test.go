@@ -0,0 +1,167 @@
+package compat
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeShapes(shapes ...native.SchemaShape) map[string]native.SchemaShape {
+	result := map[string]native.SchemaShape{}
+	for _, shape := range shapes {
+		result[shape.Name] = shape
+	}
+	return result
+}
+
+var plant = native.SchemaShape{
+	Name: "//garden/plant.star:Plant",
+	Fields: []native.FieldShape{
+		{Name: "name", Type: "String", Required: true},
+		{Name: "color", Type: "Object", Schema: "//trait/color.star:Color"},
+		{Name: "height", Type: "Int"},
+		{Name: "tags", Type: "List", Item: &native.FieldShape{Type: "String"}},
+	},
+}
+
+func TestCompareSchemasUnchanged(t *testing.T) {
+	assert.Empty(t, CompareSchemas(makeShapes(plant), makeShapes(plant)))
+}
+
+func TestCompareSchemas(t *testing.T) {
+	head := native.SchemaShape{
+		Name: "//garden/plant.star:Plant",
+		Fields: []native.FieldShape{
+			{Name: "name", Type: "String"},
+			{Name: "color", Type: "Ref", Schema: "//trait/color.star:Color", Inline: true},
+			{Name: "height", Type: "Int", Required: true},
+			{Name: "tags", Type: "List", Item: &native.FieldShape{Type: "Int"}},
+			{Name: "owner", Type: "String", Required: true},
+			{Name: "notes", Type: "String"},
+		},
+	}
+	added := native.SchemaShape{Name: "//garden/soil.star:Soil", Fields: []native.FieldShape{}}
+
+	changes := CompareSchemas(makeShapes(plant), makeShapes(head, added))
+	assert.Equal(t, []Change{
+		{Schema: "//garden/plant.star:Plant", Field: "name", Kind: MadeOptional},
+		{Schema: "//garden/plant.star:Plant", Field: "color", Kind: TypeChanged, Detail: "Object -> Ref"},
+		{Schema: "//garden/plant.star:Plant", Field: "height", Kind: MadeRequired},
+		{Schema: "//garden/plant.star:Plant", Field: "tags[]", Kind: TypeChanged, Detail: "String -> Int"},
+		{Schema: "//garden/plant.star:Plant", Field: "owner", Kind: RequiredFieldAdded},
+		{Schema: "//garden/plant.star:Plant", Field: "notes", Kind: FieldAdded},
+		{Schema: "//garden/soil.star:Soil", Kind: SchemaAdded},
+	}, changes)
+
+	changes = CompareSchemas(makeShapes(plant, added), makeShapes())
+	assert.Equal(t, []Change{
+		{Schema: "//garden/plant.star:Plant", Kind: SchemaRemoved},
+		{Schema: "//garden/soil.star:Soil", Kind: SchemaRemoved},
+	}, changes)
+}
+
+func TestCompareSchemasNestedSchema(t *testing.T) {
+	head := native.SchemaShape{
+		Name: "//garden/plant.star:Plant",
+		Fields: []native.FieldShape{
+			{Name: "name", Type: "String", Required: true},
+			{Name: "color", Type: "Object", Schema: "//trait/paint.star:Paint"},
+			{Name: "tags", Type: "List", Item: &native.FieldShape{Type: "String"}},
+		},
+	}
+
+	changes := CompareSchemas(makeShapes(plant), makeShapes(head))
+	assert.Equal(t, []Change{
+		{
+			Schema: "//garden/plant.star:Plant",
+			Field:  "color",
+			Kind:   SchemaChanged,
+			Detail: "//trait/color.star:Color -> //trait/paint.star:Paint",
+		},
+		{Schema: "//garden/plant.star:Plant", Field: "height", Kind: FieldRemoved},
+	}, changes)
+	assert.Equal(t,
+		"//garden/plant.star:Plant.color: schema-changed (//trait/color.star:Color -> //trait/paint.star:Paint)",
+		changes[0].String())
+	assert.Equal(t, "//garden/plant.star:Plant.height: field-removed", changes[1].String())
+}
+
+func TestCompareSchemasNestedFields(t *testing.T) {
+	team := func(lead []native.FieldShape, member []native.FieldShape) native.SchemaShape {
+		return native.SchemaShape{
+			Name: "//owner/team.star:Team",
+			Fields: []native.FieldShape{
+				{Name: "lead", Type: "Object", Schema: "//owner/team.star:Team.lead", Nested: true, Fields: lead},
+				{Name: "members", Type: "List", Item: &native.FieldShape{
+					Type: "Object", Schema: "//owner/team.star:Team.members", Nested: true, Fields: member,
+				}},
+			},
+		}
+	}
+	base := team(
+		[]native.FieldShape{{Name: "name", Type: "String"}, {Name: "email", Type: "String"}},
+		[]native.FieldShape{{Name: "age", Type: "Int"}},
+	)
+	head := team(
+		[]native.FieldShape{{Name: "name", Type: "String"}},
+		[]native.FieldShape{{Name: "age", Type: "String"}, {Name: "role", Type: "String", Required: true}},
+	)
+
+	changes := CompareSchemas(makeShapes(base), makeShapes(head))
+	assert.Equal(t, []Change{
+		{Schema: "//owner/team.star:Team", Field: "lead.email", Kind: FieldRemoved},
+		{Schema: "//owner/team.star:Team", Field: "members[].age", Kind: TypeChanged, Detail: "Int -> String"},
+		{Schema: "//owner/team.star:Team", Field: "members[].role", Kind: RequiredFieldAdded},
+	}, changes)
+	assert.Equal(t, "//owner/team.star:Team.lead.email: field-removed", changes[0].String())
+
+	// A nested schema is the same schema wherever it moves, but not a global schema.
+	moved := team(base.Fields[0].Fields, base.Fields[1].Item.Fields)
+	moved.Fields[0].Schema = "//owner/team.star:Team.leader"
+	assert.Empty(t, CompareSchemas(makeShapes(base), makeShapes(moved)))
+	moved.Fields[0].Nested = false
+	assert.Equal(t, []Change{{
+		Schema: "//owner/team.star:Team",
+		Field:  "lead",
+		Kind:   SchemaChanged,
+		Detail: "//owner/team.star:Team.lead -> //owner/team.star:Team.leader",
+	}}, CompareSchemas(makeShapes(base), makeShapes(moved)))
+}
+
+func TestPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	assert.True(t, policy.IsBreaking(Change{Kind: FieldRemoved}))
+	assert.False(t, policy.IsBreaking(Change{Kind: MadeOptional}))
+
+	policy, err := NewPolicy(map[string]string{"made-optional": "breaking", "field-removed": "compatible"})
+	assert.Nil(t, err)
+	assert.True(t, policy.IsBreaking(Change{Kind: MadeOptional}))
+	assert.False(t, policy.IsBreaking(Change{Kind: FieldRemoved}))
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	_, err := NewPolicy(map[string]string{"field-renamed": "breaking"})
+	assert.ErrorContains(t, err, "Unknown schema change field-renamed. Expected one of field-added,")
+
+	_, err = NewPolicy(map[string]string{"field-added": "fine"})
+	assert.ErrorContains(t, err, "Expected field-added to be breaking or compatible, but got fine.")
+}
+
+func TestSnapshot(t *testing.T) {
+	color := native.SchemaShape{
+		Name:   "//trait/color.star:Color",
+		Fields: []native.FieldShape{{Name: "red", Type: "Int"}},
+	}
+	snapshot := NewSnapshot(makeShapes(color, plant))
+	assert.Equal(t, []native.SchemaShape{plant, color}, snapshot.Schemas)
+
+	data, err := snapshot.Marshal()
+	assert.Nil(t, err)
+	parsed, err := ParseSnapshot(data)
+	assert.Nil(t, err)
+	assert.Equal(t, makeShapes(color, plant), parsed.Shapes())
+
+	_, err = ParseSnapshot([]byte("[]"))
+	assert.ErrorContains(t, err, "Invalid schema snapshot:")
+}
@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+)
+
+// Changing these files at the root of the universe can change every target.
+var universeFiles = []string{starverse.StarverseFilename, starverse.LockFilename, ".starfigignore"}
+
+// ChangedFiles are the files changed in the universe, i.e. in a commit, as the labels used in
+// the dependency graph.
+type ChangedFiles struct {
+	labels map[string]bool
+	// Every target is affected, i.e. when STARVERSE changed.
+	all bool
+}
+
+// ParseChangedFiles converts paths to labels. A path is either a label, absolute or relative
+// to the working directory. Paths outside the universe and its external universes are ignored.
+func ParseChangedFiles(
+	starverseDir string, workingDir string, universes map[string]string, paths []string) ChangedFiles {
+	changed := ChangedFiles{labels: map[string]bool{}}
+	for _, rawPath := range paths {
+		rawPath = strings.TrimSpace(rawPath)
+		if rawPath == "" {
+			continue
+		} else if strings.HasPrefix(rawPath, "//") || strings.HasPrefix(rawPath, "@") {
+			changed.labels[rawPath] = true
+			continue
+		}
+
+		path := rawPath
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+
+		label, found := fileLabel(starverseDir, universes, path)
+		if !found {
+			continue
+		}
+		changed.labels[label] = true
+
+		for _, universeFile := range universeFiles {
+			if label == fmt.Sprintf("//%s", universeFile) {
+				changed.all = true
+			}
+		}
+	}
+	return changed
+}
+
+// The label of an absolute path within the universe or one of its external universes.
+func fileLabel(starverseDir string, universes map[string]string, path string) (string, bool) {
+	label, found := changedLabel(starverseDir, "", path)
+	for name, dir := range universes {
+		if universeLabel, ok := changedLabel(dir, name, path); ok {
+			label, found = universeLabel, true
+		}
+	}
+	return label, found
+}
+
+func changedLabel(dir string, repository string, path string) (string, bool) {
+	relativePath, err := filepath.Rel(dir, path)
+	relativePath = filepath.ToSlash(relativePath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, "../") {
+		return "", false
+	}
+	if repository != "" {
+		return fmt.Sprintf("@%s//%s", repository, relativePath), true
+	}
+	return fmt.Sprintf("//%s", relativePath), true
+}
+
+// Affects reports if the node, i.e. a build target, depends on any of the changed files.
+func (changed ChangedFiles) Affects(graph *native.DependencyGraph, node string) bool {
+	if changed.all {
+		return true
+	}
+	for _, dependency := range graph.Deps([]string{node}) {
+		if changed.labels[dependency] {
+			return true
+		}
+	}
+	return false
+}
+
+// MayAffect reports if the STARFIG file can depend on any of the changed files before it is
+// evaluated, so the packages it can't affect are skipped. Affects is exact once evaluated.
+func (changed ChangedFiles) MayAffect(graph *StaticGraph, file target.FileTarget) bool {
+	return changed.all || graph.DependsOn(file, changed.labels)
+}
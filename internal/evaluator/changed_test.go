@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChangedFiles(t *testing.T) {
+	universes := map[string]string{"platform": "/repo/third_party/platform"}
+	changed := ParseChangedFiles("/repo", "/repo/team", universes, []string{
+		"defs.star",
+		"/repo/fruit/STARFIG",
+		"//trait/color.star",
+		"/repo/third_party/platform/schemas/team.star",
+		"/elsewhere/STARFIG",
+		"",
+	})
+
+	assert.Equal(t, map[string]bool{
+		"//team/defs.star":             true,
+		"//fruit/STARFIG":              true,
+		"//trait/color.star":           true,
+		"@platform//schemas/team.star": true,
+	}, changed.labels)
+	assert.False(t, changed.all)
+}
+
+func TestChangedFilesAffects(t *testing.T) {
+	graph := native.NewDependencyGraph()
+	graph.AddEdge("//fruit:apple", "//fruit/STARFIG")
+	graph.AddEdge("//fruit/STARFIG", "//trait/color.star")
+	graph.AddEdge("//orchard:farm", "//orchard/STARFIG")
+
+	changed := ParseChangedFiles("/repo", "/repo", map[string]string{}, []string{"trait/color.star"})
+	assert.True(t, changed.Affects(graph, "//fruit:apple"))
+	assert.False(t, changed.Affects(graph, "//orchard:farm"))
+
+	changed = ParseChangedFiles("/repo", "/repo", map[string]string{}, []string{"STARVERSE"})
+	assert.True(t, changed.Affects(graph, "//orchard:farm"))
+}
+
+func TestChangedFilesMayAffect(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	universes := map[string]string{"platform": filepath.Join(filepath.Dir(testStarverseDir), "platform")}
+	graph := NewStaticGraph(testStarverseDir, universes)
+	starfig := func(pkg string) target.FileTarget {
+		return target.BuildTarget{StarverseDir: testStarverseDir, Package: pkg}.StarfigFile()
+	}
+
+	// The orchard references //fruit:apple, which loads //trait/color.star through //trait/STARFIG.
+	changed := ParseChangedFiles(testStarverseDir, testStarverseDir, universes, []string{"trait/color.star"})
+	assert.True(t, changed.MayAffect(graph, starfig("fruit")))
+	assert.True(t, changed.MayAffect(graph, starfig("orchard")))
+	assert.False(t, changed.MayAffect(graph, starfig("service")))
+	assert.False(t, changed.MayAffect(graph, starfig("team")))
+
+	changed = ParseChangedFiles(
+		testStarverseDir, testStarverseDir, universes, []string{"@platform//schemas/team.star"})
+	assert.True(t, changed.MayAffect(graph, starfig("team")))
+	assert.False(t, changed.MayAffect(graph, starfig("fruit")))
+}
@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/starlark"
+)
+
+type ConstraintError struct {
+	SchemaTarget string
+	Err          error
+}
+
+func (err ConstraintError) Error() string {
+	return fmt.Sprintf("Constraint failed for %s: %s", err.SchemaTarget, err.Err)
+}
+
+// InstanceError is a package that failed to evaluate while completing the instances of the
+// schemas with constraints, so the constraints can't be checked.
+type InstanceError struct {
+	Package target.BuildTarget
+	Err     error
+}
+
+func (err InstanceError) Error() string {
+	return fmt.Sprintf(
+		"Unable to check constraints since %s failed to evaluate: %s", err.Package.Target(), err.Err)
+}
+
+// Constraints run once all the targets have been evaluated since they span every instance of a
+// schema. i.e. a unique field or a constraint function.
+func CheckConstraints(starverseDir string, evaluateResults []EvaluateResult) []ConstraintError {
+	thread := newThread("CheckConstraints", starverseDir)
+
+	schemaTargets := []string{}
+	groups := map[string][]EvaluateResult{}
+	for _, evaluateResult := range evaluateResults {
+		schemaTarget := evaluateResult.SchemaTarget
+		_, found := groups[schemaTarget]
+		if !found {
+			schemaTargets = append(schemaTargets, schemaTarget)
+		}
+		groups[schemaTarget] = append(groups[schemaTarget], evaluateResult)
+	}
+
+	errs := []ConstraintError{}
+	for _, schemaTarget := range schemaTargets {
+		group := groups[schemaTarget]
+		instances := new(starlark.Dict)
+		for _, evaluateResult := range group {
+			instances.SetKey(
+				starlark.String(evaluateResult.Key()),
+				evaluateResult.Result,
+			)
+		}
+		instances.Freeze()
+
+		// Starlark functions can only be called within a frame, so the constraints are
+		// evaluated within a builtin.
+		descriptor := group[0].Result.SchemaDescriptor
+		evaluateConstraints := starlark.NewBuiltin(schemaTarget, func(
+			thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return starlark.None, descriptor.EvaluateConstraints(thread, instances)
+		})
+		_, err := starlark.Call(thread, evaluateConstraints, starlark.Tuple{}, []starlark.Tuple{})
+		if err != nil {
+			errs = append(errs, ConstraintError{SchemaTarget: schemaTarget, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// CompleteInstances adds the instances of the schemas with constraints from every package in
+// the universe, since constraints span every instance of a schema and not only the built ones.
+// Only the packages loading the file of such a schema, directly or transitively, can
+// instantiate it, so the others aren't evaluated. The errors of the packages that fail to
+// evaluate are returned, since the constraints can't be checked without them.
+func CompleteInstances(
+	starverseDir string,
+	packages []target.BuildTarget,
+	evaluateResults []EvaluateResult,
+	options Options,
+) ([]EvaluateResult, []InstanceError) {
+	schemaFiles := map[string]bool{}
+	constrained := map[string]bool{}
+	seen := map[string]bool{}
+	for _, evaluateResult := range evaluateResults {
+		descriptor := evaluateResult.Result.SchemaDescriptor
+		if descriptor.HasConstraints() {
+			schemaFiles[descriptor.Identity.File.Target()] = true
+			constrained[evaluateResult.SchemaTarget] = true
+		}
+		seen[evaluateResult.Key()] = true
+	}
+	if len(constrained) == 0 {
+		return evaluateResults, []InstanceError{}
+	}
+
+	completed := evaluateResults[:len(evaluateResults):len(evaluateResults)]
+	errs := []InstanceError{}
+	graph := NewStaticGraph(starverseDir, options.Universes)
+	for _, buildTarget := range packages {
+		if !graph.DependsOn(buildTarget.StarfigFile(), schemaFiles) {
+			continue
+		}
+		results, err := EvaluateBuildTarget(starverseDir, buildTarget, options)
+		if err != nil {
+			errs = append(errs, InstanceError{Package: buildTarget, Err: err})
+			continue
+		}
+		for _, result := range results {
+			if constrained[result.SchemaTarget] && !seen[result.Key()] {
+				completed = append(completed, result)
+				seen[result.Key()] = true
+			}
+		}
+	}
+	return completed, errs
+}
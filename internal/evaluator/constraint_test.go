@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestCheckConstraints(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	evaluateResults := []EvaluateResult{}
+	for _, targetName := range []string{"web", "api"} {
+		buildTarget := target.BuildTarget{
+			StarverseDir: testStarverseDir,
+			Package:      "service",
+			TargetName:   targetName,
+		}
+		results, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+		assert.Nil(t, err)
+		evaluateResults = append(evaluateResults, results...)
+	}
+
+	assert.Equal(t, "//service/service.star:Service", evaluateResults[0].SchemaTarget)
+	assert.Equal(t, "//service/service.star:Service", evaluateResults[1].SchemaTarget)
+	assert.Empty(t, CheckConstraints(testStarverseDir, evaluateResults))
+}
+
+func TestCheckConstraintsDuplicateUniqueField(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "service",
+		TargetName:   "...",
+	}
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.Nil(t, err)
+
+	errs := CheckConstraints(testStarverseDir, evaluateResults)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "//service/service.star:Service", errs[0].SchemaTarget)
+	assert.ErrorContains(t, errs[0],
+		"Constraint failed for //service/service.star:Service: Duplicate value 8080 for unique field port in //service:admin and //service:web.")
+}
+
+func TestCheckConstraintsFunction(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "service",
+		TargetName:   "api",
+	}
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.Nil(t, err)
+
+	for i, targetName := range []string{"a", "b", "c"} {
+		copied := evaluateResults[0]
+		copied.Target.TargetName = targetName
+		copied.Result.Evaluated = new(starlark.Dict)
+		copied.Result.Evaluated.SetKey(starlark.String("name"), starlark.String(targetName))
+		copied.Result.Evaluated.SetKey(starlark.String("port"), starlark.MakeInt(9000+i))
+		evaluateResults = append(evaluateResults, copied)
+	}
+
+	errs := CheckConstraints(testStarverseDir, evaluateResults)
+	assert.Equal(t, 1, len(errs))
+	assert.ErrorContains(t, errs[0],
+		"Constraint failed for //service/service.star:Service: \"There can only be three services.\"")
+}
+
+func TestCompleteInstances(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	evaluateResults := []EvaluateResult{}
+	for _, targetName := range []string{"web", "api"} {
+		buildTarget := target.BuildTarget{
+			StarverseDir: testStarverseDir,
+			Package:      "service",
+			TargetName:   targetName,
+		}
+		results, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+		assert.Nil(t, err)
+		evaluateResults = append(evaluateResults, results...)
+	}
+
+	// The cycle package fails to evaluate, but it isn't evaluated since it can't instantiate
+	// the schema.
+	packages := []target.BuildTarget{
+		{StarverseDir: testStarverseDir, Package: "cycle", TargetName: "..."},
+		{StarverseDir: testStarverseDir, Package: "service", TargetName: "..."},
+	}
+	instances, errs := CompleteInstances(testStarverseDir, packages, evaluateResults, Options{})
+	assert.Empty(t, errs)
+	labels := []string{}
+	for _, instance := range instances {
+		labels = append(labels, instance.Key())
+	}
+	assert.Equal(t, []string{"//service:web", "//service:api", "//service:admin"}, labels)
+
+	constraintErrs := CheckConstraints(testStarverseDir, instances)
+	assert.Equal(t, 1, len(constraintErrs))
+	assert.ErrorContains(t, constraintErrs[0],
+		"Duplicate value 8080 for unique field port in //service:web and //service:admin.")
+}
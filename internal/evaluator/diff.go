@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"go.starlark.net/starlark"
+)
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "+"
+	Removed ChangeKind = "-"
+	Changed ChangeKind = "~"
+)
+
+// FieldChange is a difference between two evaluated values at a path, i.e. labels.team or
+// regions[1]. Base is nil when added, and Head is nil when removed.
+type FieldChange struct {
+	Path string
+	Kind ChangeKind
+	Base starlark.Value
+	Head starlark.Value
+}
+
+// DiffValues compares the evaluated values field by field. Dicts are compared by key, lists by
+// index and everything else by equality.
+func DiffValues(base starlark.Value, head starlark.Value) ([]FieldChange, error) {
+	return diffValues("", base, head)
+}
+
+func diffValues(path string, base starlark.Value, head starlark.Value) ([]FieldChange, error) {
+	baseDict, baseIsDict := base.(*starlark.Dict)
+	headDict, headIsDict := head.(*starlark.Dict)
+	if baseIsDict && headIsDict {
+		return diffDicts(path, baseDict, headDict)
+	}
+
+	baseList, baseIsList := base.(*starlark.List)
+	headList, headIsList := head.(*starlark.List)
+	if baseIsList && headIsList {
+		return diffLists(path, baseList, headList)
+	}
+
+	equal, err := starlark.Equal(base, head)
+	if err != nil || equal {
+		return []FieldChange{}, err
+	}
+	return []FieldChange{{Path: path, Kind: Changed, Base: base, Head: head}}, nil
+}
+
+func diffDicts(path string, base *starlark.Dict, head *starlark.Dict) ([]FieldChange, error) {
+	// Fields are compared in the order of the base, followed by the fields added in the head.
+	keys := []starlark.Value{}
+	seen := map[string]bool{}
+	for _, key := range append(base.Keys(), head.Keys()...) {
+		if !seen[key.String()] {
+			keys = append(keys, key)
+			seen[key.String()] = true
+		}
+	}
+
+	changes := []FieldChange{}
+	for _, key := range keys {
+		keyPath := fieldPath(path, key)
+		baseValue, baseFound, err := base.Get(key)
+		if err != nil {
+			return changes, err
+		}
+		headValue, headFound, err := head.Get(key)
+		if err != nil {
+			return changes, err
+		}
+
+		if !baseFound {
+			changes = append(changes, FieldChange{Path: keyPath, Kind: Added, Head: headValue})
+		} else if !headFound {
+			changes = append(changes, FieldChange{Path: keyPath, Kind: Removed, Base: baseValue})
+		} else {
+			valueChanges, err := diffValues(keyPath, baseValue, headValue)
+			if err != nil {
+				return changes, err
+			}
+			changes = append(changes, valueChanges...)
+		}
+	}
+	return changes, nil
+}
+
+func diffLists(path string, base *starlark.List, head *starlark.List) ([]FieldChange, error) {
+	changes := []FieldChange{}
+	for i := 0; i < base.Len() || i < head.Len(); i++ {
+		indexPath := fmt.Sprintf("%s[%d]", path, i)
+		if i >= base.Len() {
+			changes = append(changes, FieldChange{Path: indexPath, Kind: Added, Head: head.Index(i)})
+		} else if i >= head.Len() {
+			changes = append(changes, FieldChange{Path: indexPath, Kind: Removed, Base: base.Index(i)})
+		} else {
+			valueChanges, err := diffValues(indexPath, base.Index(i), head.Index(i))
+			if err != nil {
+				return changes, err
+			}
+			changes = append(changes, valueChanges...)
+		}
+	}
+	return changes, nil
+}
+
+func fieldPath(path string, key starlark.Value) string {
+	name, ok := starlark.AsString(key)
+	if !ok {
+		name = key.String()
+	}
+	if path == "" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", path, name)
+}
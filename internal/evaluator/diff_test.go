@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestDiffValues(t *testing.T) {
+	base := makeDict(map[string]starlark.Value{
+		"name":    starlark.String("api"),
+		"retries": starlark.MakeInt(3),
+		"owner":   starlark.String("web"),
+		"labels":  makeDict(map[string]starlark.Value{"team": starlark.String("infra")}),
+		"regions": starlark.NewList([]starlark.Value{starlark.String("us"), starlark.String("eu")}),
+	})
+	head := makeDict(map[string]starlark.Value{
+		"name":    starlark.String("api"),
+		"retries": starlark.MakeInt(5),
+		"labels":  makeDict(map[string]starlark.Value{"team": starlark.String("payments")}),
+		"regions": starlark.NewList([]starlark.Value{starlark.String("us"), starlark.String("ap"), starlark.String("eu")}),
+		"tier":    starlark.MakeInt(1),
+	})
+
+	changes, err := DiffValues(base, head)
+	assert.Nil(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "labels.team", Kind: Changed, Base: starlark.String("infra"), Head: starlark.String("payments")},
+		{Path: "owner", Kind: Removed, Base: starlark.String("web")},
+		{Path: "regions[1]", Kind: Changed, Base: starlark.String("eu"), Head: starlark.String("ap")},
+		{Path: "regions[2]", Kind: Added, Head: starlark.String("eu")},
+		{Path: "retries", Kind: Changed, Base: starlark.MakeInt(3), Head: starlark.MakeInt(5)},
+		{Path: "tier", Kind: Added, Head: starlark.MakeInt(1)},
+	}, changes)
+}
+
+func TestDiffValuesEqual(t *testing.T) {
+	value := makeDict(map[string]starlark.Value{"name": starlark.String("api")})
+
+	changes, err := DiffValues(value, value)
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffValuesDifferentTypes(t *testing.T) {
+	changes, err := DiffValues(starlark.MakeInt(1), starlark.String("1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "", Kind: Changed, Base: starlark.MakeInt(1), Head: starlark.String("1")},
+	}, changes)
+}
+
+// MARK: - Helpers
+
+// Keys are inserted in sorted order, so the order of the fields is deterministic.
+func makeDict(fields map[string]starlark.Value) *starlark.Dict {
+	dict := new(starlark.Dict)
+	for _, key := range sortedKeys(fields) {
+		dict.SetKey(starlark.String(key), fields[key])
+	}
+	return dict
+}
+
+func sortedKeys(fields map[string]starlark.Value) []string {
+	keys := []string{}
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// DocumentError is an error of a file being edited, at the position it occurred.
+type DocumentError struct {
+	Position syntax.Position
+	Message  string
+}
+
+type Document struct {
+	// The schemas the file can instantiate by the name they are bound to in the file, whether
+	// they are loaded or defined in it.
+	Schemas map[string]native.SchemaDescriptor
+	Errors  []DocumentError
+}
+
+// EvaluateDocument evaluates the unsaved source of a file as if it were saved, for editors.
+// The schemas loaded by the file are returned even if the rest of the file fails.
+func EvaluateDocument(starverseDir string, file target.FileTarget, src []byte, options Options) Document {
+	document := Document{Schemas: map[string]native.SchemaDescriptor{}, Errors: []DocumentError{}}
+
+	thread := newThread("EvaluateDocument", starverseDir)
+	thread.SetLocal(native.BuildSettingsThreadKey, native.NewBuildSettings(options.Settings))
+	thread.SetLocal(starverse.UniversesThreadKey, options.Universes)
+	eval := newEvaluation(starverseDir, Options{Settings: options.Settings, Universes: options.Universes})
+	thread.SetLocal(native.RefResolverThreadKey, newRefResolver(eval, target.BuildTarget{
+		StarverseDir: starverseDir,
+		Package:      file.Package,
+	}, []string{}))
+
+	contextManager := thread.Local(native.SchemaContextManagerThreadKey).(native.SchemaContextManager)
+	fileSyntax, parseErr := syntax.Parse(file.Path(), src, 0)
+	if parseErr == nil {
+		contextManager.RegisterFile(file.Path(), fileSyntax)
+	}
+
+	loaded := map[string]starlark.StringDict{}
+	thread.Load = func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+		globals, err := native.LoadProvider(thread, module)
+		loaded[module] = globals
+		return globals, err
+	}
+
+	predeclared := native.Predeclared
+	if target.IsTestFile(file.Filename) {
+		predeclared = native.TestPredeclared
+	}
+	globals, err := starlark.ExecFile(thread, file.Path(), src, predeclared)
+	if err == nil {
+		err = native.ResolveSchemas(thread, file.Path(), globals)
+	}
+	if err != nil {
+		document.Errors = documentErrors(err)
+	}
+
+	// Loaded symbols are local to the file, so they are found through its load statements.
+	bound := starlark.StringDict{}
+	for name, value := range globals {
+		bound[name] = value
+	}
+	if parseErr == nil {
+		for _, stmt := range fileSyntax.Stmts {
+			load, ok := stmt.(*syntax.LoadStmt)
+			if !ok {
+				continue
+			}
+			for i, from := range load.From {
+				if value, found := loaded[load.ModuleName()][from.Name]; found {
+					bound[load.To[i].Name] = value
+				}
+			}
+		}
+	}
+
+	for name, value := range bound {
+		if builder, ok := value.(*native.SchemaBuilder); ok {
+			document.Schemas[name] = builder.Descriptor
+		}
+	}
+	return document
+}
+
+func documentErrors(err error) []DocumentError {
+	switch typedErr := err.(type) {
+	case syntax.Error:
+		return []DocumentError{{Position: typedErr.Pos, Message: typedErr.Msg}}
+	case resolve.ErrorList:
+		errs := []DocumentError{}
+		for _, resolveErr := range typedErr {
+			errs = append(errs, DocumentError{Position: resolveErr.Pos, Message: resolveErr.Msg})
+		}
+		return errs
+	case *starlark.EvalError:
+		return []DocumentError{{Position: evalErrorPosition(typedErr), Message: typedErr.Msg}}
+	}
+	return []DocumentError{{Position: syntax.MakePosition(nil, 1, 1), Message: err.Error()}}
+}
@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+)
+
+func evaluateDocument(t *testing.T, pkg string, filename string, src string) Document {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	file := target.FileTarget{StarverseDir: testStarverseDir, Package: pkg, Filename: filename}
+	return EvaluateDocument(testStarverseDir, file, []byte(src), Options{})
+}
+
+func TestEvaluateDocument(t *testing.T) {
+	document := evaluateDocument(t, "fruit", "STARFIG", `load("//fruit/fruit.star", "Fruit")
+load("//trait/color.star", Shade = "Color")
+
+banana = Fruit(name = "Banana")
+`)
+
+	assert.Equal(t, []DocumentError{}, document.Errors)
+	assert.Equal(t, 2, len(document.Schemas))
+	assert.Equal(t, "//fruit/fruit.star:Fruit", document.Schemas["Fruit"].SKU())
+	assert.Equal(t, "//trait/color.star:Color", document.Schemas["Shade"].SKU())
+}
+
+func TestEvaluateDocumentDefinedSchemas(t *testing.T) {
+	document := evaluateDocument(t, "fruit", "seed.star", `Seed = Schema(fields = {"size": Int(doc = "The size.")})
+`)
+
+	assert.Equal(t, []DocumentError{}, document.Errors)
+	assert.Equal(t, "//fruit/seed.star:Seed", document.Schemas["Seed"].SKU())
+}
+
+func TestEvaluateDocumentEvalError(t *testing.T) {
+	document := evaluateDocument(t, "fruit", "STARFIG", `load("//fruit/fruit.star", "Fruit")
+
+banana = Fruit(
+    name = "Banana",
+    colors = 416,
+)
+`)
+
+	assert.Equal(t, 1, len(document.Errors))
+	assert.Equal(t, int32(3), document.Errors[0].Position.Line)
+	assert.Contains(t, document.Errors[0].Message, "colors")
+	// The schemas are known even though the file failed.
+	assert.Equal(t, "//fruit/fruit.star:Fruit", document.Schemas["Fruit"].SKU())
+}
+
+func TestEvaluateDocumentResolveErrors(t *testing.T) {
+	document := evaluateDocument(t, "fruit", "STARFIG", "banana = Fruit()\ncherry = Fruit()\n")
+
+	assert.Equal(t, []DocumentError{
+		{Position: document.Errors[0].Position, Message: "undefined: Fruit"},
+		{Position: document.Errors[1].Position, Message: "undefined: Fruit"},
+	}, document.Errors)
+	assert.Equal(t, int32(2), document.Errors[1].Position.Line)
+}
+
+func TestEvaluateDocumentSyntaxError(t *testing.T) {
+	document := evaluateDocument(t, "fruit", "STARFIG", "banana = Fruit(\n")
+
+	assert.Equal(t, 1, len(document.Errors))
+	assert.Equal(t, int32(2), document.Errors[0].Position.Line)
+}
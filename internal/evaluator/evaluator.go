@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jathu/starfig/internal/native"
@@ -9,18 +10,58 @@ import (
 	"github.com/jathu/starfig/internal/target"
 	"github.com/sirupsen/logrus"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 var emptySrc interface{}
 
 type EvaluateResult struct {
-	Target target.BuildTarget
-	Result native.SchemaResult
+	Target       target.BuildTarget
+	SchemaTarget string
+	Result       native.SchemaResult
+	// The build settings read while evaluating the statements the target needs, including the
+	// targets it references.
+	Settings map[string]string
 }
 
-func EvaluateBuildTarget(starverseDir string, buildTarget target.BuildTarget) ([]EvaluateResult, error) {
+// Key is the label of the build target. i.e. //pkg:t
+func (result EvaluateResult) Key() string {
+	return result.Target.Target()
+}
+
+type Options struct {
+	// The build settings, i.e. --define env=prod.
+	Settings map[string]string
+	// The root directory of each external universe by name.
+	Universes map[string]string
+	// When set, the files loaded and the targets referenced are recorded in the graph.
+	Graph *native.DependencyGraph
+}
+
+// The state shared by an evaluation and the evaluations of the targets it references.
+type evaluation struct {
+	starverseDir string
+	universes    map[string]string
+	settings     native.BuildSettings
+	graph        *native.DependencyGraph
+	// The referenced targets evaluated so far by label, so a target referenced many times is
+	// evaluated once. The settings are the same for the whole evaluation.
+	references map[string]EvaluateResult
+}
+
+func newEvaluation(starverseDir string, options Options) evaluation {
+	return evaluation{
+		starverseDir: starverseDir,
+		universes:    options.Universes,
+		settings:     native.NewBuildSettings(options.Settings),
+		graph:        options.Graph,
+		references:   map[string]EvaluateResult{},
+	}
+}
+
+func newThread(name string, starverseDir string) *starlark.Thread {
 	thread := &starlark.Thread{
-		Name: fmt.Sprintf("EvaluateBuildTarget:%s", uuid.New()),
+		Name: fmt.Sprintf("%s:%s", name, uuid.New()),
 		Load: native.LoadProvider,
 		Print: func(thread *starlark.Thread, msg string) {
 			logrus.Info(msg)
@@ -28,25 +69,40 @@ func EvaluateBuildTarget(starverseDir string, buildTarget target.BuildTarget) ([
 	}
 	thread.SetLocal(starverse.StarverseDirThreadKey, starverseDir)
 	thread.SetLocal(native.SchemaContextManagerThreadKey, native.NewSchemaContextManager())
+	return thread
+}
+
+func EvaluateBuildTarget(
+	starverseDir string, buildTarget target.BuildTarget, options Options) ([]EvaluateResult, error) {
+	return evaluateBuildTarget(newEvaluation(starverseDir, options), buildTarget, []string{})
+}
 
-	globals, err := starlark.ExecFile(thread, buildTarget.Path(), emptySrc, native.Predeclared)
+// Referrers are the build targets currently being evaluated because they reference the build
+// target, which is used to catch circular references. A referenced target is evaluated with only
+// the statements it needs, so targets can reference other targets in their package. The settings
+// of a target are the ones read by the statements it needs, since the whole package shares the
+// settings.
+func evaluateBuildTarget(
+	eval evaluation, buildTarget target.BuildTarget, referrers []string) ([]EvaluateResult, error) {
+	settings := eval.settings
+	thread := newThread("EvaluateBuildTarget", eval.starverseDir)
+	thread.SetLocal(native.RefResolverThreadKey, newRefResolver(eval, buildTarget, referrers))
+	thread.SetLocal(native.BuildSettingsThreadKey, settings)
+	thread.SetLocal(starverse.UniversesThreadKey, eval.universes)
+	if eval.graph != nil {
+		thread.SetLocal(native.DependencyGraphThreadKey, eval.graph)
+	}
+	file, err := syntax.Parse(buildTarget.Path(), emptySrc, 0)
 	if err != nil {
-		evalErr, ok := err.(*starlark.EvalError)
-		if ok {
-			var lastFrame starlark.CallFrame
-			for _, callstack := range evalErr.CallStack {
-				// https://github.com/google/starlark-go/blob/d1966c6b9fcd6631f48f5155f47afcd7adcc78c2/starlark/eval.go#L197
-				if callstack.Pos.Filename() != "<builtin>" {
-					lastFrame = callstack
-					break
-				}
-			}
-			pos := lastFrame.Pos
-			return []EvaluateResult{}, fmt.Errorf(
-				"%s:%d: %s", pos.Filename(), pos.Line, evalErr.Msg)
-		} else {
-			return []EvaluateResult{}, err
-		}
+		return []EvaluateResult{}, err
+	}
+	stmts := file.Stmts
+	if len(referrers) > 0 && buildTarget.TargetName != "..." {
+		file.Stmts = neededStatements(stmts, buildTarget.TargetName)
+	}
+	globals, err := execSyntaxFile(thread, file)
+	if err != nil {
+		return []EvaluateResult{}, evalErrorMessage(err)
 	}
 
 	results := []EvaluateResult{}
@@ -62,8 +118,10 @@ func EvaluateBuildTarget(starverseDir string, buildTarget target.BuildTarget) ([
 			result, ok := value.(native.SchemaResult)
 			if ok {
 				results = append(results, EvaluateResult{
-					Target: thisBuildTarget,
-					Result: result,
+					Target:       thisBuildTarget,
+					SchemaTarget: result.SchemaDescriptor.SKU(),
+					Result:       result,
+					Settings:     targetSettings(settings, stmts, targetName),
 				})
 			}
 		}
@@ -76,8 +134,177 @@ func EvaluateBuildTarget(starverseDir string, buildTarget target.BuildTarget) ([
 		if !ok {
 			return []EvaluateResult{}, fmt.Errorf("%s is not a schema result.", buildTarget.Target())
 		}
-		results = append(results, EvaluateResult{Target: buildTarget, Result: result})
+		results = append(results, EvaluateResult{
+			Target:       buildTarget,
+			SchemaTarget: result.SchemaDescriptor.SKU(),
+			Result:       result,
+			Settings:     targetSettings(settings, stmts, buildTarget.TargetName),
+		})
+	}
+
+	if eval.graph != nil {
+		for _, result := range results {
+			eval.graph.AddEdge(result.Target.Target(), result.Target.StarfigFile().Target())
+		}
 	}
 
 	return results, nil
 }
+
+// Starlark errors are reported at the outermost position outside of the builtins, which is
+// the line in the file being evaluated.
+func evalErrorMessage(err error) error {
+	evalErr, ok := err.(*starlark.EvalError)
+	if !ok {
+		return err
+	}
+	pos := evalErrorPosition(evalErr)
+	return fmt.Errorf("%s:%d: %s", pos.Filename(), pos.Line, evalErr.Msg)
+}
+
+func evalErrorPosition(evalErr *starlark.EvalError) syntax.Position {
+	var lastFrame starlark.CallFrame
+	for _, callstack := range evalErr.CallStack {
+		// https://github.com/google/starlark-go/blob/d1966c6b9fcd6631f48f5155f47afcd7adcc78c2/starlark/eval.go#L197
+		if callstack.Pos.Filename() != "<builtin>" {
+			lastFrame = callstack
+			break
+		}
+	}
+	return lastFrame.Pos
+}
+
+// The settings read by the statements the target needs.
+func targetSettings(settings native.BuildSettings, stmts []syntax.Stmt, targetName string) map[string]string {
+	if len(settings.Used()) == 0 {
+		return map[string]string{}
+	}
+	return settings.UsedBy(neededStatements(stmts, targetName))
+}
+
+func execSyntaxFile(thread *starlark.Thread, file *syntax.File) (starlark.StringDict, error) {
+	program, err := starlark.FileProgram(file, native.Predeclared.Has)
+	if err != nil {
+		return starlark.StringDict{}, err
+	}
+	globals, err := program.Init(thread, native.Predeclared)
+	if err != nil {
+		return globals, err
+	}
+	return globals, native.ResolveSchemas(thread, file.Path, globals)
+}
+
+// The top level statements of a STARFIG file needed to define the global, in their order: the
+// statement assigning it and, transitively, the statements defining the names it uses. The names
+// a statement uses are over-approximated by every identifier in it, which can only include more
+// statements.
+func neededStatements(stmts []syntax.Stmt, global string) []syntax.Stmt {
+	needed := map[string]bool{global: true}
+	included := make([]bool, len(stmts))
+	for changed := true; changed; {
+		changed = false
+		for i, stmt := range stmts {
+			if included[i] || !definesAny(stmt, needed) {
+				continue
+			}
+			included[i], changed = true, true
+			syntax.Walk(stmt, func(node syntax.Node) bool {
+				if ident, ok := node.(*syntax.Ident); ok {
+					needed[ident.Name] = true
+				}
+				return true
+			})
+		}
+	}
+
+	result := []syntax.Stmt{}
+	for i, stmt := range stmts {
+		if included[i] {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+// A statement defines the names it assigns, or any name it mentions when it isn't an
+// assignment, since it can mutate them, i.e. names.append("apple").
+func definesAny(stmt syntax.Stmt, names map[string]bool) bool {
+	var defined []*syntax.Ident
+	switch stmt := stmt.(type) {
+	case *syntax.DefStmt:
+		defined = []*syntax.Ident{stmt.Name}
+	case *syntax.LoadStmt:
+		defined = stmt.To
+	default:
+		var node syntax.Node = stmt
+		if assign, ok := stmt.(*syntax.AssignStmt); ok {
+			node = assign.LHS
+		}
+		syntax.Walk(node, func(node syntax.Node) bool {
+			if ident, ok := node.(*syntax.Ident); ok {
+				defined = append(defined, ident)
+			}
+			return true
+		})
+	}
+	for _, ident := range defined {
+		if names[ident.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+func newRefResolver(
+	eval evaluation, referrer target.BuildTarget, referrers []string) native.RefResolver {
+	starverseDir := eval.starverseDir
+	return func(thread *starlark.Thread, label string) (string, *starlark.Dict, error) {
+		if !strings.HasPrefix(label, "//") || strings.HasSuffix(label, "...") {
+			return "", nil, fmt.Errorf(
+				"Reference %s is invalid because it must be a single build target.", label)
+		}
+		buildTargets, err := target.ParseBuildTarget(starverseDir, label, target.Ignore{})
+		if err != nil {
+			return "", nil, err
+		} else if len(buildTargets) != 1 || buildTargets[0].TargetName == "..." {
+			return "", nil, fmt.Errorf(
+				"Reference %s is invalid because it must be a single build target.", label)
+		}
+		buildTarget := buildTargets[0]
+
+		// The reference is made by the target the statement being executed assigns, or by the
+		// package when it isn't assigned to a global.
+		referringTarget := fmt.Sprintf("//%s", referrer.Package)
+		if global, found := native.ToplevelGlobal(thread); found {
+			referringTarget = target.BuildTarget{Package: referrer.Package, TargetName: global}.Target()
+		}
+		chain := referrers[:len(referrers):len(referrers)]
+		if len(chain) == 0 || chain[len(chain)-1] != referringTarget {
+			chain = append(chain, referringTarget)
+		}
+		for _, referrer := range chain {
+			if referrer == buildTarget.Target() {
+				return "", nil, fmt.Errorf("Circular reference %s -> %s.",
+					strings.Join(chain, " -> "), buildTarget.Target())
+			}
+		}
+
+		result, found := eval.references[buildTarget.Target()]
+		if !found {
+			results, err := evaluateBuildTarget(eval, buildTarget, append(chain, buildTarget.Target()))
+			if err != nil {
+				return "", nil, fmt.Errorf("Unable to resolve reference %s: %s", label, err)
+			}
+			result = results[0]
+			eval.references[buildTarget.Target()] = result
+		}
+		// The settings the referenced target used are used by the referring statement too.
+		for key := range result.Settings {
+			eval.settings.Read(thread, key)
+		}
+		if eval.graph != nil {
+			eval.graph.AddEdge(referrer.StarfigFile().Target(), buildTarget.Target())
+		}
+		return result.SchemaTarget, result.Result.Evaluated, nil
+	}
+}
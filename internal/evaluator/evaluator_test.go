@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/jathu/starfig/internal/native"
 	"github.com/jathu/starfig/internal/target"
 	"github.com/jathu/starfig/internal/tester"
 	"github.com/stretchr/testify/assert"
@@ -19,7 +20,7 @@ func TestEvaluateBuildTarget(t *testing.T) {
 		Package:      "fruit",
 		TargetName:   "apple",
 	}
-	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget)
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(evaluateResults))
@@ -45,9 +46,9 @@ func TestEvaluateBuildTargetExecError(t *testing.T) {
 		Package:      "evalerror",
 		TargetName:   "apple",
 	}
-	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget)
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
 	absolutePath := filepath.Join(testStarverseDir, "evalerror", "STARFIG")
-	expected := fmt.Sprintf("%s:4: Invalid field colors in Fruit: Expected list type but got 416.", absolutePath)
+	expected := fmt.Sprintf("%s:4: Invalid field colors in //fruit/fruit.star:Fruit: Expected list type but got 416.", absolutePath)
 	assert.ErrorContains(t, err, expected)
 }
 
@@ -58,7 +59,7 @@ func TestEvaluateBuildTargetExecErrorNonEval(t *testing.T) {
 		Package:      "nonexistentPackage",
 		TargetName:   "",
 	}
-	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget)
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
 	absolutePath := filepath.Join(testStarverseDir, "nonexistentPackage", "STARFIG")
 	expected := fmt.Sprintf(`open %s: no such file or directory`, absolutePath)
 	assert.ErrorContains(t, err, expected)
@@ -71,7 +72,7 @@ func TestEvaluateBuildTargetSpread(t *testing.T) {
 		Package:      "trait",
 		TargetName:   "...",
 	}
-	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget)
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
 	assert.Nil(t, err)
 	assert.Equal(t, 3, len(evaluateResults))
 
@@ -101,7 +102,7 @@ func TestEvaluateBuildTargetNotFoundTarget(t *testing.T) {
 		Package:      "fruit",
 		TargetName:   "tangerine",
 	}
-	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget)
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
 	assert.ErrorContains(t, err, "//fruit:tangerine not found.")
 }
 
@@ -112,12 +113,310 @@ func TestEvaluateBuildTargetNotSchemaResult(t *testing.T) {
 		Package:      "badfig",
 		TargetName:   "BadFig",
 	}
-	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget)
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
 	assert.ErrorContains(t, err, "//badfig:BadFig is not a schema result.")
 }
 
+func TestEvaluateBuildTargetRef(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "orchard",
+		TargetName:   "farm",
+	}
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(evaluateResults))
+
+	expectedApple := new(starlark.Dict)
+	expectedApple.SetKey(starlark.String("name"), starlark.String("Apple"))
+	expectedApple.SetKey(starlark.String("colors"), starlark.NewList([]starlark.Value{
+		makeColor(255, 0, 0),
+		makeColor(0, 255, 0),
+		makeColor(255, 255, 0),
+	}))
+	expectedFarm := new(starlark.Dict)
+	expectedFarm.SetKey(starlark.String("fruit"), starlark.String("//fruit:apple"))
+	expectedFarm.SetKey(starlark.String("featured"), expectedApple)
+
+	same, err := expectedFarm.CompareSameType(syntax.EQL, evaluateResults[0].Result.Evaluated, 10)
+	assert.Nil(t, err)
+	assert.True(t, same)
+}
+
+func TestEvaluateBuildTargetRefIncorrectSchema(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "badref",
+		TargetName:   "farm",
+	}
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.ErrorContains(t, err,
+		"Invalid field fruit in //orchard/orchard.star:Orchard: Expected //trait:red to be //fruit/fruit.star:Fruit but got //trait/color.star:Color.")
+}
+
+func TestEvaluateBuildTargetRefCircular(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "cycle",
+		TargetName:   "ping",
+	}
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.ErrorContains(t, err,
+		"Invalid field ref in //cycle/holder.star:Holder: Circular reference //cycle:ping -> //cycle:pong -> //cycle:ping.")
+}
+
+func TestEvaluateBuildTargetRefSamePackage(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "selfref",
+		TargetName:   "...",
+	}
+	eval := newEvaluation(testStarverseDir, Options{})
+	evaluateResults, err := evaluateBuildTarget(eval, buildTarget, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(evaluateResults))
+	for _, result := range evaluateResults {
+		if result.Target.TargetName != "late" {
+			ref, _, _ := result.Result.Evaluated.Get(starlark.String("ref"))
+			assert.Equal(t, starlark.String("//selfref:late"), ref)
+		}
+	}
+	// The referenced target is evaluated once.
+	assert.Equal(t, []string{"//selfref:late"}, keys(eval.references))
+}
+
+func TestNeededStatements(t *testing.T) {
+	file, err := syntax.Parse("STARFIG", `
+load("//fruit/fruit.star", "Fruit")
+load("//trait/STARFIG", "red")
+_names = ["apple"]
+_names.append("pear")
+def _name(index):
+    return _names[index]
+apple = Fruit(name = _name(0))
+pear = Fruit(name = _name(1), colors = [red])
+`, 0)
+	assert.Nil(t, err)
+	lines := []int32{}
+	for _, stmt := range neededStatements(file.Stmts, "apple") {
+		start, _ := stmt.Span()
+		lines = append(lines, start.Line)
+	}
+	assert.Equal(t, []int32{2, 4, 5, 6, 8}, lines)
+}
+
+func TestEvaluateBuildTargetDerive(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "garden",
+		TargetName:   "tulip",
+	}
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(evaluateResults))
+
+	expectedTulip := new(starlark.Dict)
+	expectedTulip.SetKey(starlark.String("name"), starlark.String("Tulip"))
+	expectedTulip.SetKey(starlark.String("color"), makeColor(255, 128, 0))
+	expectedTulip.SetKey(starlark.String("height"), starlark.MakeInt(2))
+	expectedTulip.SetKey(starlark.String("label"), starlark.String("Tulip (255)"))
+
+	same, err := expectedTulip.CompareSameType(syntax.EQL, evaluateResults[0].Result.Evaluated, 10)
+	assert.Nil(t, err)
+	assert.True(t, same)
+}
+
+func TestEvaluateBuildTargetDeriveError(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "badgarden",
+		TargetName:   "tall",
+	}
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	overridePath := filepath.Join(testStarverseDir, "badgarden", "STARFIG")
+	basePath := filepath.Join(testStarverseDir, "garden", "STARFIG")
+	expected := fmt.Sprintf(
+		"%s:3: Invalid field height in //garden/plant.star:Plant: Expected int type but got \"tall\". (derived from %s:4:13)",
+		overridePath, basePath)
+	assert.ErrorContains(t, err, expected)
+}
+
+func TestEvaluateBuildTargetImmutable(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "immutable",
+		TargetName:   "dark_red",
+	}
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.ErrorContains(t, err,
+		"Cannot set field green because schema instances are immutable. Use derive to create a modified copy.")
+}
+
+func TestEvaluateBuildTargetSettings(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "deploy",
+		TargetName:   "backfill",
+	}
+
+	for _, testCase := range []struct {
+		settings map[string]string
+		used     map[string]string
+		replicas int
+		env      string
+	}{
+		{map[string]string{}, map[string]string{}, 1, "dev"},
+		{map[string]string{"env": "prod"}, map[string]string{"env": "prod"}, 10, "prod"},
+		{map[string]string{"env": "prod", "region": "eu"}, map[string]string{"env": "prod", "region": "eu"}, 20, "prod"},
+	} {
+		evaluateResults, err := EvaluateBuildTarget(
+			testStarverseDir, buildTarget, Options{Settings: testCase.settings})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(evaluateResults))
+
+		expected := new(starlark.Dict)
+		expected.SetKey(starlark.String("name"), starlark.String("backfill"))
+		expected.SetKey(starlark.String("replicas"), starlark.MakeInt(testCase.replicas))
+		expected.SetKey(starlark.String("env"), starlark.String(testCase.env))
+		same, err := expected.CompareSameType(syntax.EQL, evaluateResults[0].Result.Evaluated, 10)
+		assert.Nil(t, err)
+		assert.True(t, same)
+		assert.Equal(t, testCase.used, evaluateResults[0].Settings)
+		assert.Equal(t, "//deploy:backfill", evaluateResults[0].Key())
+	}
+}
+
+func TestEvaluateBuildTargetSettingsPerTarget(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "rollout",
+		TargetName:   "...",
+	}
+	evaluateResults, err := EvaluateBuildTarget(
+		testStarverseDir, buildTarget, Options{Settings: map[string]string{"env": "prod", "region": "eu"}})
+	assert.Nil(t, err)
+
+	used := map[string]map[string]string{}
+	for _, evaluateResult := range evaluateResults {
+		used[evaluateResult.Key()] = evaluateResult.Settings
+	}
+	assert.Equal(t, map[string]map[string]string{
+		"//rollout:web":    {"env": "prod"},
+		"//rollout:worker": {"region": "eu"},
+		"//rollout:cron":   {},
+		// The referenced target used both settings.
+		"//rollout:canary": {"env": "prod", "region": "eu"},
+	}, used)
+}
+
+func TestEvaluateBuildTargetUnusedSettings(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "fruit",
+		TargetName:   "apple",
+	}
+	evaluateResults, err := EvaluateBuildTarget(
+		testStarverseDir, buildTarget, Options{Settings: map[string]string{"env": "prod"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "//fruit:apple", evaluateResults[0].Key())
+}
+
+func TestEvaluateBuildTargetExternalUniverse(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "team",
+		TargetName:   "growth",
+	}
+	universes := map[string]string{"platform": filepath.Join(filepath.Dir(testStarverseDir), "platform")}
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{Universes: universes})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(evaluateResults))
+
+	expected := new(starlark.Dict)
+	expected.SetKey(starlark.String("name"), starlark.String("growth"))
+	expected.SetKey(starlark.String("size"), starlark.MakeInt(4))
+	same, err := expected.CompareSameType(syntax.EQL, evaluateResults[0].Result.Evaluated, 10)
+	assert.Nil(t, err)
+	assert.True(t, same)
+	assert.Equal(t, "@platform//schemas/team.star:Team", evaluateResults[0].SchemaTarget)
+}
+
+func TestEvaluateBuildTargetUnknownUniverse(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "team",
+		TargetName:   "growth",
+	}
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.ErrorContains(t, err,
+		"Unknown universe @platform in @platform//schemas/team.star. External universes must be declared in STARVERSE.")
+}
+
+func TestEvaluateBuildTargetVisibility(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "menu",
+		TargetName:   "brunch",
+	}
+	evaluateResults, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(evaluateResults))
+	assert.Equal(t, "{\"name\": \"Pancakes\", \"servings\": 8}", evaluateResults[0].Result.Evaluated.String())
+}
+
+func TestEvaluateBuildTargetNotVisible(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "badmenu",
+		TargetName:   "waffles",
+	}
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{})
+	assert.ErrorContains(t, err,
+		"Package //badmenu cannot load //recipe/recipe.star because package //recipe is only visible to [//menu/...].")
+}
+
+func TestEvaluateBuildTargetDependencyGraph(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "orchard",
+		TargetName:   "farm",
+	}
+	graph := native.NewDependencyGraph()
+	_, err := EvaluateBuildTarget(testStarverseDir, buildTarget, Options{Graph: graph})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"//orchard/STARFIG"}, graph.Edges("//orchard:farm"))
+	assert.Equal(t, []string{"//fruit:apple", "//orchard/orchard.star"}, graph.Edges("//orchard/STARFIG"))
+	assert.Equal(t, []string{"//fruit/STARFIG"}, graph.Edges("//fruit:apple"))
+	assert.True(t, graph.DependsOn("//orchard:farm", "//trait/color.star"))
+}
+
 // MARK: - Helpers
 
+func keys(references map[string]EvaluateResult) []string {
+	labels := []string{}
+	for label := range references {
+		labels = append(labels, label)
+	}
+	return labels
+}
+
 func makeColor(red int, green int, blue int) *starlark.Dict {
 	result := new(starlark.Dict)
 	result.SetKey(starlark.String("red"), starlark.MakeInt(red))
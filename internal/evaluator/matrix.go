@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/jathu/starfig/internal/starverse"
+)
+
+// ExpandMatrix returns the settings for every combination of the matrix axes, on top of
+// the given settings. The first axis varies the slowest.
+func ExpandMatrix(settings map[string]string, axes []starverse.MatrixAxis) ([]map[string]string, error) {
+	combinations := []map[string]string{copySettings(settings)}
+	seen := map[string]bool{}
+
+	for _, axis := range axes {
+		if _, found := settings[axis.Key]; found {
+			return nil, fmt.Errorf(
+				"Build setting %s cannot be both defined and in the matrix.", axis.Key)
+		} else if seen[axis.Key] {
+			return nil, fmt.Errorf("Matrix axis %s is declared more than once.", axis.Key)
+		} else if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("Matrix axis %s requires at least one value.", axis.Key)
+		}
+		seen[axis.Key] = true
+
+		expanded := []map[string]string{}
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				next := copySettings(combination)
+				next[axis.Key] = value
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	return combinations, nil
+}
+
+func copySettings(settings map[string]string) map[string]string {
+	result := map[string]string{}
+	for key, value := range settings {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	combinations, err := ExpandMatrix(
+		map[string]string{"team": "growth"},
+		[]starverse.MatrixAxis{
+			{Key: "env", Values: []string{"dev", "prod"}},
+			{Key: "region", Values: []string{"us", "eu"}},
+		},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{
+		{"team": "growth", "env": "dev", "region": "us"},
+		{"team": "growth", "env": "dev", "region": "eu"},
+		{"team": "growth", "env": "prod", "region": "us"},
+		{"team": "growth", "env": "prod", "region": "eu"},
+	}, combinations)
+}
+
+func TestExpandMatrixNoAxes(t *testing.T) {
+	combinations, err := ExpandMatrix(map[string]string{"env": "prod"}, []starverse.MatrixAxis{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{{"env": "prod"}}, combinations)
+}
+
+func TestExpandMatrixDefinedAxis(t *testing.T) {
+	_, err := ExpandMatrix(
+		map[string]string{"env": "prod"},
+		[]starverse.MatrixAxis{{Key: "env", Values: []string{"dev", "prod"}}},
+	)
+
+	assert.ErrorContains(t, err, "Build setting env cannot be both defined and in the matrix.")
+}
+
+func TestExpandMatrixDuplicateAxis(t *testing.T) {
+	_, err := ExpandMatrix(
+		map[string]string{},
+		[]starverse.MatrixAxis{
+			{Key: "env", Values: []string{"dev"}},
+			{Key: "env", Values: []string{"prod"}},
+		},
+	)
+
+	assert.ErrorContains(t, err, "Matrix axis env is declared more than once.")
+}
+
+func TestExpandMatrixEmptyAxis(t *testing.T) {
+	_, err := ExpandMatrix(map[string]string{}, []starverse.MatrixAxis{{Key: "env", Values: []string{}}})
+
+	assert.ErrorContains(t, err, "Matrix axis env requires at least one value.")
+}
@@ -0,0 +1,131 @@
+package evaluator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+	"go.starlark.net/starlark"
+)
+
+// Metadata describes how a target was built, so a config can be traced back to its source.
+type Metadata struct {
+	// The schema of the target. i.e. //fruit/fruit.star:Fruit
+	Schema string
+	// The file and line the target was instantiated at. i.e. //fruit/STARFIG
+	SourceFile string
+	SourceLine int32
+	// The sha256 of the files the target depends on and the build settings it used.
+	Hash           string
+	StarfigVersion string
+	Settings       map[string]string
+}
+
+// NewMetadata creates the metadata of an evaluated target. The graph must be the one the
+// target was evaluated with, since the hash covers the files the target depends on.
+func NewMetadata(
+	starverseDir string,
+	universes map[string]string,
+	graph *native.DependencyGraph,
+	result EvaluateResult,
+	starfigVersion string,
+) (Metadata, error) {
+	position := result.Result.Position
+	sourceFile, found := fileLabel(starverseDir, universes, position.Filename())
+	if !found {
+		sourceFile = position.Filename()
+	}
+	hash, err := inputHash(starverseDir, universes, graph, result)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{
+		Schema:         result.SchemaTarget,
+		SourceFile:     sourceFile,
+		SourceLine:     position.Line,
+		Hash:           hash,
+		StarfigVersion: starfigVersion,
+		Settings:       result.Settings,
+	}, nil
+}
+
+// Envelope wraps the evaluated target with its metadata.
+// i.e. {"metadata": {"schema": ..., "source": ...}, "value": ...}
+func (metadata Metadata) Envelope(evaluated starlark.Value) *starlark.Dict {
+	source := new(starlark.Dict)
+	source.SetKey(starlark.String("file"), starlark.String(metadata.SourceFile))
+	source.SetKey(starlark.String("line"), starlark.MakeInt(int(metadata.SourceLine)))
+
+	settings := new(starlark.Dict)
+	keys := []string{}
+	for key := range metadata.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		settings.SetKey(starlark.String(key), starlark.String(metadata.Settings[key]))
+	}
+
+	values := new(starlark.Dict)
+	values.SetKey(starlark.String("schema"), starlark.String(metadata.Schema))
+	values.SetKey(starlark.String("source"), source)
+	values.SetKey(starlark.String("hash"), starlark.String(metadata.Hash))
+	values.SetKey(starlark.String("starfig_version"), starlark.String(metadata.StarfigVersion))
+	values.SetKey(starlark.String("settings"), settings)
+
+	envelope := new(starlark.Dict)
+	envelope.SetKey(starlark.String("metadata"), values)
+	envelope.SetKey(starlark.String("value"), evaluated)
+	return envelope
+}
+
+// The inputs of a target are the files in its dependencies, i.e. its STARFIG, the files it
+// loads and the packages of the targets it references, along with the settings it used.
+func inputHash(
+	starverseDir string,
+	universes map[string]string,
+	graph *native.DependencyGraph,
+	result EvaluateResult,
+) (string, error) {
+	hash := sha256.New()
+	for _, dependency := range graph.Deps([]string{result.Target.Target()}) {
+		// Build targets are labeled with their name, i.e. //fruit:apple, and files aren't.
+		if strings.Contains(dependency, ":") {
+			continue
+		}
+		path, err := labelPath(starverseDir, universes, dependency)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("Unable to hash %s: %s", dependency, err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", dependency, len(content))
+		hash.Write(content)
+	}
+	fmt.Fprintf(hash, "%s", native.FormatSettings(result.Settings))
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// The path of a file label within the universe or one of its external universes.
+// i.e. //trait/color.star or @platform//schemas/team.star
+func labelPath(starverseDir string, universes map[string]string, label string) (string, error) {
+	repository, relativePath, found := strings.Cut(label, "//")
+	if !found {
+		return "", fmt.Errorf("Invalid file label %s.", label)
+	}
+	dir := starverseDir
+	if repository != "" {
+		universeDir, found := universes[strings.TrimPrefix(repository, "@")]
+		if !found {
+			return "", fmt.Errorf("Unknown universe %s in %s.", repository, label)
+		}
+		dir = universeDir
+	}
+	return filepath.Join(dir, filepath.FromSlash(relativePath)), nil
+}
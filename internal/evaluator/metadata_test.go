@@ -0,0 +1,109 @@
+package evaluator
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func evaluateWithMetadata(t *testing.T, pkg string, options Options) Metadata {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	buildTarget := target.BuildTarget{StarverseDir: testStarverseDir, Package: pkg, TargetName: "..."}
+	options.Graph = native.NewDependencyGraph()
+	results, err := EvaluateBuildTarget(testStarverseDir, buildTarget, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+
+	metadata, err := NewMetadata(testStarverseDir, options.Universes, options.Graph, results[0], "1.2.3")
+	assert.Nil(t, err)
+	return metadata
+}
+
+func TestNewMetadata(t *testing.T) {
+	metadata := evaluateWithMetadata(t, "deploy", Options{Settings: map[string]string{"env": "prod"}})
+
+	assert.Equal(t, "//deploy/job.star:Job", metadata.Schema)
+	assert.Equal(t, "//deploy/STARFIG", metadata.SourceFile)
+	assert.Equal(t, int32(3), metadata.SourceLine)
+	assert.Equal(t, "1.2.3", metadata.StarfigVersion)
+	assert.Equal(t, map[string]string{"env": "prod"}, metadata.Settings)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", metadata.Hash)
+}
+
+func TestNewMetadataHashDependsOnSettings(t *testing.T) {
+	prod := evaluateWithMetadata(t, "deploy", Options{Settings: map[string]string{"env": "prod"}})
+	again := evaluateWithMetadata(t, "deploy", Options{Settings: map[string]string{"env": "prod"}})
+	dev := evaluateWithMetadata(t, "deploy", Options{Settings: map[string]string{"env": "dev"}})
+
+	assert.Equal(t, prod.Hash, again.Hash)
+	assert.NotEqual(t, prod.Hash, dev.Hash)
+}
+
+func TestNewMetadataHashWithSharedGraph(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	evaluate := func(graph *native.DependencyGraph, pkg string, targetName string) EvaluateResult {
+		buildTarget := target.BuildTarget{StarverseDir: testStarverseDir, Package: pkg, TargetName: targetName}
+		options := Options{Settings: map[string]string{"env": "prod"}, Graph: graph}
+		results, err := EvaluateBuildTarget(testStarverseDir, buildTarget, options)
+		assert.Nil(t, err)
+		return results[0]
+	}
+	hash := func(graph *native.DependencyGraph, result EvaluateResult) string {
+		metadata, err := NewMetadata(testStarverseDir, map[string]string{}, graph, result, "dev")
+		assert.Nil(t, err)
+		return metadata.Hash
+	}
+
+	graph := native.NewDependencyGraph()
+	apple := evaluate(graph, "fruit", "apple")
+	appleHash := hash(graph, apple)
+	backfill := evaluate(graph, "deploy", "backfill")
+
+	// Targets in different packages depend on different files, even within the same graph.
+	assert.NotEqual(t, appleHash, hash(graph, backfill))
+	assert.Equal(t, appleHash, hash(graph, apple))
+
+	// The same inputs give the same hash, regardless of the other targets in the graph.
+	freshGraph := native.NewDependencyGraph()
+	assert.Equal(t, appleHash, hash(freshGraph, evaluate(freshGraph, "fruit", "apple")))
+}
+
+func TestNewMetadataExternalUniverse(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	universes := map[string]string{"platform": filepath.Join(testStarverseDir, "..", "platform")}
+	metadata := evaluateWithMetadata(t, "team", Options{Universes: universes})
+
+	assert.Equal(t, "@platform//schemas/team.star:Team", metadata.Schema)
+	assert.Equal(t, "//team/STARFIG", metadata.SourceFile)
+}
+
+func TestNewMetadataMissingUniverse(t *testing.T) {
+	graph := native.NewDependencyGraph()
+	graph.AddEdge("//team:growth", "@platform//schemas/team.star")
+	result := EvaluateResult{Target: target.BuildTarget{Package: "team", TargetName: "growth"}}
+
+	_, err := NewMetadata("/repo", map[string]string{}, graph, result, "dev")
+	assert.Equal(t, "Unknown universe @platform in @platform//schemas/team.star.", err.Error())
+}
+
+func TestMetadataEnvelope(t *testing.T) {
+	metadata := Metadata{
+		Schema:         "//fruit/fruit.star:Fruit",
+		SourceFile:     "//fruit/STARFIG",
+		SourceLine:     4,
+		Hash:           "sha256:abc",
+		StarfigVersion: "dev",
+		Settings:       map[string]string{"region": "eu", "env": "prod"},
+	}
+	envelope := metadata.Envelope(starlark.String("apple"))
+
+	assert.Equal(t, `{"metadata": {"schema": "//fruit/fruit.star:Fruit", `+
+		`"source": {"file": "//fruit/STARFIG", "line": 4}, "hash": "sha256:abc", `+
+		`"starfig_version": "dev", "settings": {"env": "prod", "region": "eu"}}, "value": "apple"}`,
+		envelope.String())
+}
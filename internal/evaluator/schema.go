@@ -0,0 +1,40 @@
+package evaluator
+
+import (
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/starlark"
+)
+
+// ExtractSchemas evaluates the .star files and returns the shape of every schema defined in
+// them by its target. i.e. //trait/color.star:Color
+func ExtractSchemas(
+	starverseDir string, files []target.FileTarget, options Options) (map[string]native.SchemaShape, error) {
+	shapes := map[string]native.SchemaShape{}
+
+	for _, file := range files {
+		thread := newThread("ExtractSchemas", starverseDir)
+		thread.SetLocal(native.BuildSettingsThreadKey, native.NewBuildSettings(options.Settings))
+		thread.SetLocal(starverse.UniversesThreadKey, options.Universes)
+
+		globals, err := starlark.ExecFile(thread, file.Path(), emptySrc, native.Predeclared)
+		if err == nil {
+			err = native.ResolveSchemas(thread, file.Path(), globals)
+		}
+		if err != nil {
+			return shapes, evalErrorMessage(err)
+		}
+
+		for _, value := range globals {
+			builder, ok := value.(*native.SchemaBuilder)
+			// Schemas loaded from other files are extracted with the file they are assigned in.
+			if !ok || builder.Descriptor.Identity.File.Target() != file.Target() {
+				continue
+			}
+			shapes[builder.Descriptor.SKU()] = native.NewSchemaShape(builder.Descriptor)
+		}
+	}
+
+	return shapes, nil
+}
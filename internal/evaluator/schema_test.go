@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jathu/starfig/internal/compat"
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractSchemas(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	files := []target.FileTarget{
+		{StarverseDir: testStarverseDir, Package: "fruit", Filename: "fruit.star"},
+		{StarverseDir: testStarverseDir, Package: "garden", Filename: "plant.star"},
+	}
+
+	shapes, err := ExtractSchemas(testStarverseDir, files, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]native.SchemaShape{
+		"//fruit/fruit.star:Fruit": {
+			Name: "//fruit/fruit.star:Fruit",
+			Fields: []native.FieldShape{
+				{Name: "name", Type: "String"},
+				{Name: "colors", Type: "List", Item: &native.FieldShape{
+					Type: "Object", Schema: "//trait/color.star:Color",
+				}},
+			},
+		},
+		"//garden/plant.star:Plant": {
+			Name: "//garden/plant.star:Plant",
+			Fields: []native.FieldShape{
+				{Name: "name", Type: "String", Required: true},
+				{Name: "color", Type: "Object", Schema: "//trait/color.star:Color"},
+				{Name: "height", Type: "Int"},
+				{Name: "label", Type: "String"},
+			},
+		},
+	}, shapes)
+}
+
+func TestExtractSchemasError(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	files := []target.FileTarget{
+		{StarverseDir: testStarverseDir, Package: "invalid", Filename: "invalidSyntax.star"},
+	}
+
+	_, err := ExtractSchemas(testStarverseDir, files, Options{})
+	assert.ErrorContains(t, err, "invalidSyntax.star")
+}
+
+// Extracts the schemas of the source as //owner/team.star in a new starverse.
+func extractSchemaSource(t *testing.T, src string) map[string]native.SchemaShape {
+	starverseDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(starverseDir, "STARVERSE"), []byte{}, 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(starverseDir, "owner"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(starverseDir, "owner", "team.star"), []byte(src), 0644))
+
+	files := []target.FileTarget{{StarverseDir: starverseDir, Package: "owner", Filename: "team.star"}}
+	shapes, err := ExtractSchemas(starverseDir, files, Options{})
+	assert.Nil(t, err)
+	return shapes
+}
+
+func TestExtractSchemasNested(t *testing.T) {
+	shapes := extractSchemaSource(t, `
+def make_team():
+    Person = Schema(fields = {"name": String(required = True)})
+    return Schema(fields = {"lead": Object(Person), "members": List(Person)})
+
+Team = make_team()
+`)
+	person := []native.FieldShape{{Name: "name", Type: "String", Required: true}}
+	assert.Equal(t, map[string]native.SchemaShape{
+		"//owner/team.star:Team": {
+			Name: "//owner/team.star:Team",
+			Fields: []native.FieldShape{
+				{Name: "lead", Type: "Object", Schema: "//owner/team.star:Team.lead", Nested: true, Fields: person},
+				{Name: "members", Type: "List", Item: &native.FieldShape{
+					Type: "Object", Schema: "//owner/team.star:Team.lead",
+				}},
+			},
+		},
+	}, shapes)
+}
+
+func TestExtractSchemasMoved(t *testing.T) {
+	src := `
+Team = Schema(fields = {
+    "lead": Object(Schema(fields = {"name": String()})),
+    "tags": List(Schema(fields = {"label": String()})),
+})
+`
+	base := extractSchemaSource(t, src)
+	head := extractSchemaSource(t, "# The team of an owner.\n\n\n"+src)
+	assert.Empty(t, compat.CompareSchemas(base, head))
+
+	head = extractSchemaSource(t, `
+Team = Schema(fields = {
+    "lead": Object(Schema(fields = {"name": Int()})),
+    "tags": List(Schema(fields = {})),
+})
+`)
+	assert.Equal(t, []compat.Change{
+		{Schema: "//owner/team.star:Team", Field: "lead.name", Kind: compat.TypeChanged, Detail: "String -> Int"},
+		{Schema: "//owner/team.star:Team", Field: "tags[].label", Kind: compat.FieldRemoved},
+	}, compat.CompareSchemas(base, head))
+}
@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/syntax"
+)
+
+// StaticGraph finds the files a file depends on by parsing it instead of evaluating it, so
+// packages can be skipped before they are evaluated. The dependencies are over-approximated:
+// any string that is a build target label, i.e. "//infra:prod", is a reference to its STARFIG
+// file, and a file that can't be parsed depends on everything.
+type StaticGraph struct {
+	starverseDir string
+	universes    map[string]string
+	// The direct dependencies of each parsed file by label.
+	files map[string]staticFile
+}
+
+type staticFile struct {
+	deps []target.FileTarget
+	// The file couldn't be parsed, so its dependencies are unknown.
+	unknown bool
+}
+
+var referencePattern = regexp.MustCompile(`^//([^:]*):([^:/]+)$`)
+
+func NewStaticGraph(starverseDir string, universes map[string]string) *StaticGraph {
+	return &StaticGraph{starverseDir: starverseDir, universes: universes, files: map[string]staticFile{}}
+}
+
+// DependsOn reports if the file, or any file it loads or references transitively, is one of
+// the labels.
+func (graph *StaticGraph) DependsOn(file target.FileTarget, labels map[string]bool) bool {
+	visited := map[string]bool{}
+	queue := []target.FileTarget{file}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		label := current.Target()
+		if visited[label] {
+			continue
+		}
+		visited[label] = true
+		if labels[label] {
+			return true
+		}
+
+		parsed := graph.parse(current)
+		if parsed.unknown {
+			return true
+		}
+		queue = append(queue, parsed.deps...)
+	}
+	return false
+}
+
+func (graph *StaticGraph) parse(file target.FileTarget) staticFile {
+	label := file.Target()
+	if parsed, found := graph.files[label]; found {
+		return parsed
+	}
+
+	parsed := staticFile{}
+	data, err := os.ReadFile(file.Path())
+	if os.IsNotExist(err) {
+		// A missing file can't load anything, and evaluating it fails anyway.
+	} else if err != nil {
+		parsed.unknown = true
+	} else if syntaxFile, err := syntax.Parse(file.Path(), data, 0); err != nil {
+		parsed.unknown = true
+	} else {
+		for _, stmt := range syntaxFile.Stmts {
+			if load, ok := stmt.(*syntax.LoadStmt); ok {
+				loaded, err := target.ParseLoadTarget(file, graph.universes, load.ModuleName())
+				if err != nil {
+					parsed.unknown = true
+					break
+				}
+				parsed.deps = append(parsed.deps, loaded)
+			}
+		}
+		syntax.Walk(syntaxFile, func(node syntax.Node) bool {
+			if literal, ok := node.(*syntax.Literal); ok {
+				if reference, ok := graph.reference(literal); ok {
+					parsed.deps = append(parsed.deps, reference)
+				}
+			}
+			return true
+		})
+	}
+
+	graph.files[label] = parsed
+	return parsed
+}
+
+// The STARFIG file of the build target a string literal references, which is always resolved
+// within the universe being built.
+func (graph *StaticGraph) reference(literal *syntax.Literal) (target.FileTarget, bool) {
+	value, ok := literal.Value.(string)
+	if !ok {
+		return target.FileTarget{}, false
+	}
+	match := referencePattern.FindStringSubmatch(value)
+	if match == nil {
+		return target.FileTarget{}, false
+	}
+	buildTarget := target.BuildTarget{
+		StarverseDir: graph.starverseDir,
+		Package:      strings.TrimSuffix(match[1], "/"),
+		TargetName:   match[2],
+	}
+	return buildTarget.StarfigFile(), true
+}
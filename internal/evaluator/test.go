@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/starlark"
+)
+
+const testFunctionPrefix string = "test_"
+
+type TestResult struct {
+	// The test file followed by the test function. i.e. //pkg/defs_test.star:test_name
+	Name string
+	Err  error
+}
+
+// RunTests runs every test_* function of the test file. A test passes when it returns without
+// an error. If the file itself fails, it is reported as a single failed result.
+func RunTests(starverseDir string, testFile target.FileTarget, options Options) []TestResult {
+	thread := newThread("RunTests", starverseDir)
+	thread.SetLocal(native.BuildSettingsThreadKey, native.NewBuildSettings(options.Settings))
+	thread.SetLocal(starverse.UniversesThreadKey, options.Universes)
+	eval := newEvaluation(starverseDir, Options{Settings: options.Settings, Universes: options.Universes})
+	thread.SetLocal(native.RefResolverThreadKey, newRefResolver(eval, target.BuildTarget{
+		StarverseDir: starverseDir,
+		Package:      testFile.Package,
+	}, []string{}))
+
+	globals, err := starlark.ExecFile(thread, testFile.Path(), emptySrc, native.TestPredeclared)
+	if err == nil {
+		err = native.ResolveSchemas(thread, testFile.Path(), globals)
+	}
+	if err != nil {
+		return []TestResult{{Name: testFile.Target(), Err: evalErrorMessage(err)}}
+	}
+
+	results := []TestResult{}
+	for _, name := range globals.Keys() {
+		function, ok := globals[name].(*starlark.Function)
+		if !ok || !strings.HasPrefix(name, testFunctionPrefix) {
+			continue
+		}
+
+		_, err := starlark.Call(thread, function, starlark.Tuple{}, []starlark.Tuple{})
+		if err != nil {
+			err = evalErrorMessage(err)
+		}
+		results = append(results, TestResult{
+			Name: fmt.Sprintf("%s:%s", testFile.Target(), name),
+			Err:  err,
+		})
+	}
+
+	if len(results) == 0 {
+		return []TestResult{{
+			Name: testFile.Target(),
+			Err:  fmt.Errorf("%s has no %s functions.", testFile.Target(), testFunctionPrefix),
+		}}
+	}
+	return results
+}
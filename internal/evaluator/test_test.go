@@ -0,0 +1,72 @@
+package evaluator
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTests(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	testFile := target.FileTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "garden",
+		Filename:     "plant_test.star",
+	}
+
+	results := RunTests(testStarverseDir, testFile, Options{})
+	names := []string{}
+	for _, result := range results {
+		assert.Nil(t, result.Err, result.Name)
+		names = append(names, result.Name)
+	}
+	assert.Equal(t, []string{
+		"//garden/plant_test.star:test_derive",
+		"//garden/plant_test.star:test_height_must_be_int",
+		"//garden/plant_test.star:test_immutable",
+		"//garden/plant_test.star:test_label",
+		"//garden/plant_test.star:test_name_is_required",
+		"//garden/plant_test.star:test_type",
+	}, names)
+}
+
+func TestRunTestsFailures(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	testFile := target.FileTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "badtest",
+		Filename:     "fail_test.star",
+	}
+	path := filepath.Join(testStarverseDir, "badtest", "fail_test.star")
+
+	errs := map[string]error{}
+	for _, result := range RunTests(testStarverseDir, testFile, Options{}) {
+		errs[result.Name] = result.Err
+	}
+	assert.Equal(t, 4, len(errs))
+	assert.Nil(t, errs["//badtest/fail_test.star:test_passes"])
+	assert.EqualError(t, errs["//badtest/fail_test.star:test_wrong_label"],
+		fmt.Sprintf(`%s:6: Expected "Rose (1)" to equal "Rose (255)".`, path))
+	assert.EqualError(t, errs["//badtest/fail_test.star:test_valid_is_not_invalid"],
+		fmt.Sprintf("%s:9: Expected //garden/plant.star:Plant to fail, but it succeeded.", path))
+	assert.EqualError(t, errs["//badtest/fail_test.star:test_wrong_message"],
+		fmt.Sprintf("%s:12: Expected lambda to fail with bang, but got: fail: boom", path))
+}
+
+func TestRunTestsExecError(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	testFile := target.FileTarget{
+		StarverseDir: testStarverseDir,
+		Package:      "invalid",
+		Filename:     "invalidSyntax.star",
+	}
+
+	results := RunTests(testStarverseDir, testFile, Options{})
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "//invalid/invalidSyntax.star", results[0].Name)
+	assert.ErrorContains(t, results[0].Err, "undefined: this")
+}
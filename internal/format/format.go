@@ -0,0 +1,529 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+const indentation string = "    "
+
+// Source formats a .star or STARFIG file canonically:
+//   - blocks are indented with 4 spaces, and at most one blank line separates statements
+//   - brackets spanning multiple lines have one item per line with a trailing comma, and
+//     brackets on one line have no trailing comma
+//   - the symbols of a load are sorted
+//   - strings use double quotes, unless they contain double quotes
+//   - operators, keyword arguments and commas are surrounded by consistent spaces
+//
+// Comments are kept with the syntax they are attached to.
+func Source(filename string, src []byte) ([]byte, error) {
+	file, err := syntax.Parse(filename, src, syntax.RetainComments)
+	if err != nil {
+		return nil, err
+	}
+
+	p := printer{atLineStart: true}
+	p.block(file.Stmts)
+	if comments := file.Comments(); comments != nil {
+		for _, comment := range comments.After {
+			p.comment(comment)
+		}
+	}
+	formatted := []byte(p.String())
+
+	// The printer never drops syntax or comments, but a bug would lose configs, so the output
+	// is checked before it replaces the file.
+	formattedFile, err := syntax.Parse(filename, formatted, syntax.RetainComments)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to format %s: %s", filename, err)
+	} else if countComments(formattedFile) != countComments(file) {
+		return nil, fmt.Errorf("Unable to format %s without losing comments.", filename)
+	}
+	return formatted, nil
+}
+
+type printer struct {
+	strings.Builder
+	indent      int
+	atLineStart bool
+	// The last line of the source printed, used to keep blank lines between statements.
+	lastLine int32
+	// Comments printed at the end of the current line.
+	pending []syntax.Comment
+}
+
+// MARK: - Lines
+
+func (p *printer) write(s string) {
+	if p.atLineStart {
+		p.WriteString(strings.Repeat(indentation, p.indent))
+	}
+	p.WriteString(s)
+	p.atLineStart = false
+}
+
+func (p *printer) newline() {
+	for _, comment := range p.pending {
+		p.WriteString("  ")
+		p.WriteString(comment.Text)
+	}
+	p.pending = nil
+	p.WriteString("\n")
+	p.atLineStart = true
+}
+
+// Keeps a single blank line where the source had one or more before the line.
+func (p *printer) gap(line int32, first bool) {
+	if !first && p.lastLine > 0 && line > p.lastLine+1 {
+		p.WriteString("\n")
+	}
+}
+
+func (p *printer) comment(comment syntax.Comment) {
+	p.gap(comment.Start.Line, p.Len() == 0)
+	p.write(comment.Text)
+	p.newline()
+	p.lastLine = comment.Start.Line
+}
+
+// Comments attached to syntax printed in the middle of a line can't be put before it, so they
+// are moved to the end of the line.
+func (p *printer) before(node syntax.Node) {
+	comments := node.Comments()
+	if comments == nil {
+		return
+	}
+	for _, comment := range comments.Before {
+		if p.atLineStart {
+			p.write(comment.Text)
+			p.newline()
+		} else {
+			p.pending = append(p.pending, comment)
+		}
+	}
+}
+
+func (p *printer) suffix(node syntax.Node) {
+	if comments := node.Comments(); comments != nil {
+		p.pending = append(p.pending, comments.Suffix...)
+	}
+}
+
+// MARK: - Statements
+
+func (p *printer) block(stmts []syntax.Stmt) {
+	for i, stmt := range stmts {
+		first := i == 0
+		if comments := stmt.Comments(); comments != nil {
+			for _, comment := range comments.Before {
+				p.gap(comment.Start.Line, first)
+				p.write(comment.Text)
+				p.newline()
+				p.lastLine = comment.Start.Line
+				first = false
+			}
+		}
+		p.gap(syntax.Start(stmt).Line, first)
+		p.stmt(stmt)
+		p.lastLine = syntax.End(stmt).Line
+	}
+}
+
+func (p *printer) stmt(stmt syntax.Stmt) {
+	switch stmt := stmt.(type) {
+	case *syntax.DefStmt:
+		moveSuffix(stmt, stmt.Body)
+		p.write("def ")
+		p.write(stmt.Name.Name)
+		p.params(stmt.Def, stmt.Params)
+		p.write(":")
+		p.body(stmt.Body)
+		return
+	case *syntax.IfStmt:
+		p.ifStmt(stmt)
+		return
+	case *syntax.ForStmt:
+		moveSuffix(stmt, stmt.Body)
+		p.write("for ")
+		p.expr(stmt.Vars)
+		p.write(" in ")
+		p.expr(stmt.X)
+		p.write(":")
+		p.body(stmt.Body)
+		return
+	case *syntax.WhileStmt:
+		moveSuffix(stmt, stmt.Body)
+		p.write("while ")
+		p.expr(stmt.Cond)
+		p.write(":")
+		p.body(stmt.Body)
+		return
+	case *syntax.AssignStmt:
+		p.expr(stmt.LHS)
+		p.write(fmt.Sprintf(" %s ", stmt.Op))
+		p.expr(stmt.RHS)
+	case *syntax.ExprStmt:
+		p.expr(stmt.X)
+	case *syntax.ReturnStmt:
+		p.write("return")
+		if stmt.Result != nil {
+			p.write(" ")
+			p.expr(stmt.Result)
+		}
+	case *syntax.BranchStmt:
+		p.write(stmt.Token.String())
+	case *syntax.LoadStmt:
+		p.load(stmt)
+	}
+	p.suffix(stmt)
+	p.newline()
+}
+
+func (p *printer) body(stmts []syntax.Stmt) {
+	p.newline()
+	p.indent += 1
+	p.block(stmts)
+	p.indent -= 1
+}
+
+// An elif is parsed as an if statement in the else block, starting where the else would.
+func (p *printer) ifStmt(stmt *syntax.IfStmt) {
+	if stmt.False == nil {
+		moveSuffix(stmt, stmt.True)
+	} else {
+		moveSuffix(stmt, stmt.False)
+	}
+	p.write("if ")
+	p.expr(stmt.Cond)
+	p.write(":")
+	p.body(stmt.True)
+	if stmt.False == nil {
+		return
+	}
+
+	elif, isElif := stmt.False[0].(*syntax.IfStmt)
+	if len(stmt.False) == 1 && isElif && elif.If == stmt.ElsePos {
+		if comments := elif.Comments(); comments != nil {
+			for _, comment := range comments.Before {
+				p.write(comment.Text)
+				p.newline()
+			}
+		}
+		p.write("el")
+		p.ifStmt(elif)
+		return
+	}
+	p.write("else:")
+	p.body(stmt.False)
+}
+
+// The suffix comment of a statement with a body is on the last line of the body.
+func moveSuffix(stmt syntax.Stmt, body []syntax.Stmt) {
+	comments := stmt.Comments()
+	if comments == nil || len(comments.Suffix) == 0 || len(body) == 0 {
+		return
+	}
+	last := body[len(body)-1]
+	last.AllocComments()
+	last.Comments().Suffix = append(last.Comments().Suffix, comments.Suffix...)
+	comments.Suffix = nil
+}
+
+type loadSymbol struct {
+	// The name bound in the loading file, and the name in the loaded file.
+	to   *syntax.Ident
+	from *syntax.Ident
+}
+
+func (p *printer) load(stmt *syntax.LoadStmt) {
+	symbols := []loadSymbol{}
+	for i := range stmt.To {
+		symbols = append(symbols, loadSymbol{to: stmt.To[i], from: stmt.From[i]})
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].to.Name < symbols[j].to.Name
+	})
+
+	multiline := stmt.Load.Line != stmt.Rparen.Line || hasComments(stmt.Module)
+	for _, symbol := range symbols {
+		multiline = multiline || hasComments(symbol.to) || hasComments(symbol.from)
+	}
+
+	items := []func(){func() { p.expr(stmt.Module) }}
+	for _, symbol := range symbols {
+		symbol := symbol
+		items = append(items, func() {
+			p.before(symbol.from)
+			if symbol.to != symbol.from {
+				p.before(symbol.to)
+				p.write(fmt.Sprintf("%s = ", symbol.to.Name))
+				p.suffix(symbol.to)
+			}
+			p.write(quote(symbol.from.Name))
+			p.suffix(symbol.from)
+		})
+	}
+	p.write("load")
+	p.items("(", ")", items, multiline)
+}
+
+// MARK: - Expressions
+
+func (p *printer) expr(expr syntax.Expr) {
+	p.before(expr)
+
+	switch expr := expr.(type) {
+	case *syntax.Ident:
+		p.write(expr.Name)
+	case *syntax.Literal:
+		if expr.Token == syntax.STRING || expr.Token == syntax.BYTES {
+			p.write(requote(expr.Raw))
+		} else {
+			p.write(expr.Raw)
+		}
+	case *syntax.BinaryExpr:
+		p.expr(expr.X)
+		p.write(fmt.Sprintf(" %s ", expr.Op))
+		p.expr(expr.Y)
+	case *syntax.UnaryExpr:
+		p.write(expr.Op.String())
+		if expr.Op == syntax.NOT {
+			p.write(" ")
+		}
+		if expr.X != nil {
+			p.expr(expr.X)
+		}
+	case *syntax.CallExpr:
+		p.expr(expr.Fn)
+		p.list("(", ")", expr.Lparen, expr.Rparen, expr.Args)
+	case *syntax.ListExpr:
+		p.list("[", "]", expr.Lbrack, expr.Rbrack, expr.List)
+	case *syntax.DictExpr:
+		p.list("{", "}", expr.Lbrace, expr.Rbrace, expr.List)
+	case *syntax.DictEntry:
+		p.expr(expr.Key)
+		p.write(": ")
+		p.expr(expr.Value)
+	case *syntax.TupleExpr:
+		if expr.Lparen.IsValid() {
+			p.list("(", ")", expr.Lparen, expr.Rparen, expr.List)
+		} else {
+			p.inline(expr.List)
+			if len(expr.List) == 1 {
+				p.write(",")
+			}
+		}
+	case *syntax.ParenExpr:
+		p.write("(")
+		p.expr(expr.X)
+		p.write(")")
+	case *syntax.DotExpr:
+		p.expr(expr.X)
+		p.write(".")
+		p.expr(expr.Name)
+	case *syntax.IndexExpr:
+		p.expr(expr.X)
+		p.write("[")
+		p.expr(expr.Y)
+		p.write("]")
+	case *syntax.SliceExpr:
+		p.expr(expr.X)
+		p.write("[")
+		for i, part := range []syntax.Expr{expr.Lo, expr.Hi, expr.Step} {
+			if i == 2 && part == nil {
+				break
+			} else if i > 0 {
+				p.write(":")
+			}
+			if part != nil {
+				p.expr(part)
+			}
+		}
+		p.write("]")
+	case *syntax.CondExpr:
+		p.expr(expr.True)
+		p.write(" if ")
+		p.expr(expr.Cond)
+		p.write(" else ")
+		p.expr(expr.False)
+	case *syntax.LambdaExpr:
+		p.write("lambda")
+		if len(expr.Params) > 0 {
+			p.write(" ")
+			p.inline(expr.Params)
+		}
+		p.write(": ")
+		p.expr(expr.Body)
+	case *syntax.Comprehension:
+		open, close := "[", "]"
+		if expr.Curly {
+			open, close = "{", "}"
+		}
+		p.write(open)
+		p.expr(expr.Body)
+		for _, clause := range expr.Clauses {
+			p.before(clause)
+			switch clause := clause.(type) {
+			case *syntax.ForClause:
+				p.write(" for ")
+				p.expr(clause.Vars)
+				p.write(" in ")
+				p.expr(clause.X)
+			case *syntax.IfClause:
+				p.write(" if ")
+				p.expr(clause.Cond)
+			}
+			p.suffix(clause)
+		}
+		p.write(close)
+	}
+
+	p.suffix(expr)
+}
+
+func (p *printer) inline(exprs []syntax.Expr) {
+	for i, expr := range exprs {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(expr)
+	}
+}
+
+// The parameters of a function are on one line unless they were on multiple lines. The
+// comment at the end of the line of the def is attached to the last parameter.
+func (p *printer) params(def syntax.Position, params []syntax.Expr) {
+	multiline := false
+	for _, param := range params {
+		comments := param.Comments()
+		multiline = multiline || syntax.End(param).Line != def.Line ||
+			(comments != nil && len(comments.Before) > 0)
+	}
+	p.items("(", ")", exprItems(p, params), multiline)
+}
+
+// Brackets are kept on one line unless the items were on multiple lines. A single item that
+// hugs the brackets, i.e. select({ ... }), stays on the line of the brackets.
+func (p *printer) list(open string, close string, openPos syntax.Position, closePos syntax.Position, exprs []syntax.Expr) {
+	multiline := false
+	if len(exprs) == 1 {
+		start, end := exprs[0].Span()
+		multiline = start.Line != openPos.Line || end.Line != closePos.Line
+	} else if len(exprs) > 1 {
+		multiline = openPos.Line != closePos.Line
+	}
+	for _, expr := range exprs {
+		multiline = multiline || hasComments(expr)
+	}
+	p.items(open, close, exprItems(p, exprs), multiline)
+}
+
+func exprItems(p *printer, exprs []syntax.Expr) []func() {
+	items := []func(){}
+	for _, expr := range exprs {
+		expr := expr
+		items = append(items, func() { p.expr(expr) })
+	}
+	return items
+}
+
+func (p *printer) items(open string, close string, items []func(), multiline bool) {
+	p.write(open)
+	if !multiline || len(items) == 0 {
+		for i, item := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			item()
+		}
+		p.write(close)
+		return
+	}
+
+	p.indent += 1
+	for _, item := range items {
+		p.newline()
+		item()
+		p.write(",")
+	}
+	p.indent -= 1
+	p.newline()
+	p.write(close)
+}
+
+// MARK: - Helpers
+
+// Strings use double quotes, keeping the escapes and prefixes of the source. A string is
+// left as is when changing its quotes would change its value or need escaping, i.e. r'"' or
+// 'say "hi"'.
+func requote(raw string) string {
+	prefix := raw[:strings.IndexAny(raw, `'"`)]
+	raw = raw[len(prefix):]
+	if raw[0] == '"' {
+		return prefix + raw
+	}
+
+	if strings.HasPrefix(raw, "'''") {
+		body := raw[3 : len(raw)-3]
+		if strings.Contains(body, `"""`) || strings.HasSuffix(body, `"`) || strings.HasSuffix(body, `\`) {
+			return prefix + raw
+		}
+		return prefix + `"""` + body + `"""`
+	}
+
+	body := raw[1 : len(raw)-1]
+	if strings.Contains(body, `"`) {
+		return prefix + raw
+	} else if strings.ContainsAny(prefix, "rR") {
+		return prefix + `"` + body + `"`
+	}
+
+	var quoted strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\\' && i+1 < len(body) {
+			if body[i+1] != '\'' {
+				quoted.WriteByte('\\')
+			}
+			quoted.WriteByte(body[i+1])
+			i++
+		} else {
+			quoted.WriteByte(body[i])
+		}
+	}
+	return prefix + `"` + quoted.String() + `"`
+}
+
+func quote(s string) string {
+	return requote(syntax.Quote(s, false))
+}
+
+func hasComments(node syntax.Node) bool {
+	comments := node.Comments()
+	return comments != nil && len(comments.Before)+len(comments.Suffix) > 0
+}
+
+// Loaded symbols share an identifier for both names, so comments are counted by position.
+func countComments(file *syntax.File) int {
+	seen := map[syntax.Position]bool{}
+	count := func(comments *syntax.Comments) {
+		if comments == nil {
+			return
+		}
+		for _, group := range [][]syntax.Comment{comments.Before, comments.Suffix, comments.After} {
+			for _, comment := range group {
+				seen[comment.Start] = true
+			}
+		}
+	}
+	count(file.Comments())
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if node != nil {
+			count(node.Comments())
+		}
+		return true
+	})
+	return len(seen)
+}
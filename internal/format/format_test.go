@@ -0,0 +1,177 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertFormat(t *testing.T, src string, expected string) {
+	formatted, err := Source("defs.star", []byte(src))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(formatted))
+
+	// Formatting is idempotent.
+	again, err := Source("defs.star", formatted)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(again))
+}
+
+func TestSourceIndentation(t *testing.T) {
+	assertFormat(t, `
+def check(value):
+  if value==None :
+	return "missing"
+  elif len( value )>2:
+          return "too long"
+  else:
+    return None
+`, `def check(value):
+    if value == None:
+        return "missing"
+    elif len(value) > 2:
+        return "too long"
+    else:
+        return None
+`)
+}
+
+func TestSourceTrailingCommas(t *testing.T) {
+	assertFormat(t, `apple = Fruit(
+	name = "Apple",
+	colors = [red, green,],
+	size = {"width": 2, "height": 3}
+)
+pear = Fruit(name="Pear", colors=[
+    green,
+])
+`, `apple = Fruit(
+    name = "Apple",
+    colors = [red, green],
+    size = {"width": 2, "height": 3},
+)
+pear = Fruit(
+    name = "Pear",
+    colors = [
+        green,
+    ],
+)
+`)
+}
+
+func TestSourceHugsSingleItem(t *testing.T) {
+	assertFormat(t, `replicas = select({
+    "env:prod": 10,
+    "default": 1
+})
+`, `replicas = select({
+    "env:prod": 10,
+    "default": 1,
+})
+`)
+}
+
+func TestSourceSortsLoadSymbols(t *testing.T) {
+	assertFormat(t, `load("//trait/STARFIG", "red", "green", blue = "yellow")
+load(
+    ":fruit.star",
+    "Fruit",  # The schema.
+    "Apple",
+)
+`, `load("//trait/STARFIG", blue = "yellow", "green", "red")
+load(
+    ":fruit.star",
+    "Apple",
+    "Fruit",  # The schema.
+)
+`)
+}
+
+func TestSourceQuotes(t *testing.T) {
+	assertFormat(t, `a = 'apple'
+b = 'say "hi"'
+c = 'it\'s'
+d = r'\d+'
+e = r'"'
+f = '''doc
+string'''
+g = "already"
+`, `a = "apple"
+b = 'say "hi"'
+c = "it's"
+d = r"\d+"
+e = r'"'
+f = """doc
+string"""
+g = "already"
+`)
+}
+
+func TestSourceQuotesNeedingEscapes(t *testing.T) {
+	assertFormat(t, `a = 'say "hi"'
+b = 'it\'s "ok"'
+c = r'"\d"'
+d = 'tab\t"'
+e = 'tab\t'
+`, `a = 'say "hi"'
+b = 'it\'s "ok"'
+c = r'"\d"'
+d = 'tab\t"'
+e = "tab\t"
+`)
+}
+
+func TestSourceComments(t *testing.T) {
+	assertFormat(t, `# Helpers ----
+
+
+
+def label(plant):  # The label.
+    # Name first.
+    return "%s" % plant.name  # Formatted.
+Plant = Schema(
+    fields = {
+        # Required.
+        "name": String(required = True),
+        "height": Int(),  # In cm.
+    },
+)
+# The end.
+`, `# Helpers ----
+
+def label(plant):  # The label.
+    # Name first.
+    return "%s" % plant.name  # Formatted.
+Plant = Schema(
+    fields = {
+        # Required.
+        "name": String(required = True),
+        "height": Int(),  # In cm.
+    },
+)
+# The end.
+`)
+}
+
+func TestSourceExpressions(t *testing.T) {
+	assertFormat(t, `x = [c["name"] for c in colors if not c.red in (1,)]
+y = values[::-1] + values[1:]
+z = lambda value: -value if value else None
+a, b = b, a
+def f(*args, **kwargs):
+    for k, v in kwargs.items():
+        pass
+`, `x = [c["name"] for c in colors if not c.red in (1,)]
+y = values[::-1] + values[1:]
+z = lambda value: -value if value else None
+a, b = b, a
+def f(*args, **kwargs):
+    for k, v in kwargs.items():
+        pass
+`)
+}
+
+func TestSourceInvalidSyntax(t *testing.T) {
+	_, err := Source("defs.star", []byte("x = ("))
+	assert.ErrorContains(t, err, "defs.star:1:6")
+}
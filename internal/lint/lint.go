@@ -0,0 +1,334 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	TextOutput string = "text"
+	JSONOutput string = "json"
+)
+
+// A comment suppressing findings, i.e. # starfig:disable=SF001,unused-schema
+const disablePrefix string = "# starfig:disable="
+
+type Finding struct {
+	File    string `json:"file"`
+	Line    int32  `json:"line"`
+	Column  int32  `json:"column"`
+	Code    string `json:"code"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (finding Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s %s: %s",
+		finding.File, finding.Line, finding.Column, finding.Code, finding.Rule, finding.Message)
+}
+
+func ValidateOutput(output string) error {
+	switch output {
+	case TextOutput, JSONOutput:
+		return nil
+	default:
+		return fmt.Errorf("Unknown output %s. Expected %s or %s.", output, TextOutput, JSONOutput)
+	}
+}
+
+// FormatFindings formats the findings as lines or a JSON list.
+func FormatFindings(findings []Finding, output string) (string, error) {
+	if output == JSONOutput {
+		data, err := json.MarshalIndent(findings, "", "  ")
+		return string(data), err
+	}
+	lines := []string{}
+	for _, finding := range findings {
+		lines = append(lines, finding.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// MARK: - Linter
+
+// Linter checks the source files of a universe. Some checks need the whole universe, i.e. a
+// schema is unused when no file loads it, so every source file is parsed up front.
+type Linter struct {
+	starverseDir string
+	universes    map[string]string
+	files        map[string]*sourceFile
+	// The symbols loaded from each file by any file of the universe, by file label.
+	loaded map[string]map[string]bool
+}
+
+type sourceFile struct {
+	target target.FileTarget
+	syntax *syntax.File
+	err    error
+	// The schemas defined by the file, i.e. Color = Schema(...), by name.
+	schemas map[string]*syntax.CallExpr
+}
+
+func NewLinter(starverseDir string, universes map[string]string, files []target.FileTarget) *Linter {
+	linter := &Linter{
+		starverseDir: starverseDir,
+		universes:    universes,
+		files:        map[string]*sourceFile{},
+		loaded:       map[string]map[string]bool{},
+	}
+	for _, file := range files {
+		source := linter.file(file)
+		if source.err != nil {
+			continue
+		}
+		for _, load := range loads(source.syntax) {
+			loadedFile, ok := linter.loadTarget(source, load)
+			if !ok {
+				continue
+			}
+			if _, found := linter.loaded[loadedFile.Target()]; !found {
+				linter.loaded[loadedFile.Target()] = map[string]bool{}
+			}
+			for _, from := range load.From {
+				linter.loaded[loadedFile.Target()][from.Name] = true
+			}
+		}
+	}
+	return linter
+}
+
+// Lint runs every check on the file. Findings suppressed by a # starfig:disable comment are
+// left out.
+func (linter *Linter) Lint(file target.FileTarget) []Finding {
+	source := linter.file(file)
+	if source.err != nil {
+		position := syntax.MakePosition(nil, 1, 1)
+		message := source.err.Error()
+		if syntaxErr, ok := source.err.(syntax.Error); ok {
+			position, message = syntaxErr.Pos, syntaxErr.Msg
+		}
+		return []Finding{newFinding(source, SyntaxError, position, message)}
+	}
+
+	findings := []Finding{}
+	suppressions := parseSuppressions(source.syntax)
+	for _, check := range checks {
+		for _, finding := range check(linter, source) {
+			if !suppressions.suppresses(finding) {
+				findings = append(findings, finding)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings
+}
+
+// Files are parsed once and resolved, so every identifier knows what it is bound to.
+func (linter *Linter) file(file target.FileTarget) *sourceFile {
+	if source, found := linter.files[file.Target()]; found {
+		return source
+	}
+
+	source := &sourceFile{target: file, schemas: map[string]*syntax.CallExpr{}}
+	linter.files[file.Target()] = source
+	src, err := os.ReadFile(file.Path())
+	if err != nil {
+		source.err = err
+		return source
+	}
+	source.syntax, source.err = syntax.Parse(file.Path(), src, syntax.RetainComments)
+	if source.err != nil {
+		return source
+	}
+
+	predeclared := native.Predeclared
+	if target.IsTestFile(file.Filename) {
+		predeclared = native.TestPredeclared
+	}
+	// Undefined names are reported when the file is evaluated, so resolve errors are ignored.
+	_ = resolve.File(source.syntax, predeclared.Has, starlark.Universe.Has)
+
+	for _, stmt := range source.syntax.Stmts {
+		if name, call, ok := assignedCall(stmt); ok && callName(call) == "Schema" {
+			source.schemas[name.Name] = call
+		}
+	}
+	return source
+}
+
+// The file loaded by the load statement, in the universe of the loading file.
+func (linter *Linter) loadTarget(source *sourceFile, load *syntax.LoadStmt) (target.FileTarget, bool) {
+	loadedFile, err := target.ParseLoadTarget(source.target, linter.universes, load.ModuleName())
+	return loadedFile, err == nil
+}
+
+// The names bound to schemas in the file, whether they are defined in the file or loaded.
+func (linter *Linter) schemaNames(source *sourceFile) map[string]bool {
+	names := map[string]bool{}
+	for name := range source.schemas {
+		names[name] = true
+	}
+	for _, load := range loads(source.syntax) {
+		loadedFile, ok := linter.loadTarget(source, load)
+		if !ok {
+			continue
+		}
+		loadedSource := linter.file(loadedFile)
+		for i, from := range load.From {
+			if _, isSchema := loadedSource.schemas[from.Name]; isSchema {
+				names[load.To[i].Name] = true
+			}
+		}
+	}
+	return names
+}
+
+func newFinding(source *sourceFile, rule Rule, position syntax.Position, message string) Finding {
+	return Finding{
+		File:    source.target.Target(),
+		Line:    position.Line,
+		Column:  position.Col,
+		Code:    rule.Code,
+		Rule:    rule.Name,
+		Message: message,
+	}
+}
+
+// MARK: - Suppressions
+
+type suppression struct {
+	from  int32
+	to    int32
+	rules map[string]bool
+}
+
+type suppressions []suppression
+
+// A disable comment on its own line suppresses the findings of the syntax after it, i.e. a
+// statement or a field. A comment at the end of a line suppresses the findings of the line.
+func parseSuppressions(file *syntax.File) suppressions {
+	parsed := suppressions{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if node == nil || node.Comments() == nil {
+			return true
+		}
+		start, end := node.Span()
+		for _, comment := range node.Comments().Before {
+			if rules, ok := parseDisable(comment); ok {
+				parsed = append(parsed, suppression{from: start.Line, to: end.Line, rules: rules})
+			}
+		}
+		for _, comment := range node.Comments().Suffix {
+			if rules, ok := parseDisable(comment); ok {
+				line := comment.Start.Line
+				parsed = append(parsed, suppression{from: line, to: line, rules: rules})
+			}
+		}
+		return true
+	})
+	return parsed
+}
+
+func parseDisable(comment syntax.Comment) (map[string]bool, bool) {
+	if !strings.HasPrefix(comment.Text, disablePrefix) {
+		return nil, false
+	}
+	rules := map[string]bool{}
+	for _, rule := range strings.Split(strings.TrimPrefix(comment.Text, disablePrefix), ",") {
+		rules[strings.TrimSpace(rule)] = true
+	}
+	return rules, true
+}
+
+func (parsed suppressions) suppresses(finding Finding) bool {
+	for _, suppression := range parsed {
+		inRange := finding.Line >= suppression.from && finding.Line <= suppression.to
+		if inRange && (suppression.rules[finding.Code] || suppression.rules[finding.Rule]) {
+			return true
+		}
+	}
+	return false
+}
+
+// MARK: - Helpers
+
+func loads(file *syntax.File) []*syntax.LoadStmt {
+	found := []*syntax.LoadStmt{}
+	for _, stmt := range file.Stmts {
+		if load, ok := stmt.(*syntax.LoadStmt); ok {
+			found = append(found, load)
+		}
+	}
+	return found
+}
+
+// A statement assigning a call to a name, i.e. Color = Schema(...)
+func assignedCall(stmt syntax.Stmt) (*syntax.Ident, *syntax.CallExpr, bool) {
+	assign, ok := stmt.(*syntax.AssignStmt)
+	if !ok || assign.Op != syntax.EQ {
+		return nil, nil, false
+	}
+	name, isIdent := assign.LHS.(*syntax.Ident)
+	call, isCall := assign.RHS.(*syntax.CallExpr)
+	return name, call, isIdent && isCall
+}
+
+// The name of the function called, i.e. Schema, or empty when it isn't called by name.
+func callName(expr syntax.Expr) string {
+	call, ok := expr.(*syntax.CallExpr)
+	if !ok {
+		return ""
+	}
+	if name, ok := call.Fn.(*syntax.Ident); ok {
+		return name.Name
+	}
+	return ""
+}
+
+// The value of a keyword argument of the call, i.e. required = True
+func keywordArg(call *syntax.CallExpr, keyword string) (syntax.Expr, bool) {
+	for _, arg := range call.Args {
+		binary, ok := arg.(*syntax.BinaryExpr)
+		if !ok || binary.Op != syntax.EQ {
+			continue
+		}
+		if name, ok := binary.X.(*syntax.Ident); ok && name.Name == keyword {
+			return binary.Y, true
+		}
+	}
+	return nil, false
+}
+
+func binding(ident *syntax.Ident) *resolve.Binding {
+	bound, _ := ident.Binding.(*resolve.Binding)
+	return bound
+}
+
+// The number of times each name is used, by the identifier binding it. Names used by nested
+// functions have their own bindings, so they are counted by where they are first bound.
+func bindingUses(file *syntax.File) map[*syntax.Ident]int {
+	uses := map[*syntax.Ident]int{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if ident, ok := node.(*syntax.Ident); ok {
+			if bound := binding(ident); bound != nil && bound.First != ident {
+				uses[bound.First] += 1
+			}
+		}
+		return true
+	})
+	return uses
+}
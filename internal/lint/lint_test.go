@@ -0,0 +1,319 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/jathu/starfig/internal/target"
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes the files to a new universe and lints one of them.
+func lintFile(t *testing.T, files map[string]string, label string) []Finding {
+	starverseDir := t.TempDir()
+	targets := []target.FileTarget{}
+	for name, src := range files {
+		path := filepath.Join(starverseDir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(src), 0644))
+		targets = append(targets, target.FileTarget{
+			StarverseDir: starverseDir, Package: filepath.Dir(name), Filename: filepath.Base(name)})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Target() < targets[j].Target() })
+
+	linter := NewLinter(starverseDir, map[string]string{}, targets)
+	for _, file := range targets {
+		if file.Target() == label {
+			return linter.Lint(file)
+		}
+	}
+	t.Fatalf("Unknown file %s.", label)
+	return nil
+}
+
+func codes(findings []Finding) []string {
+	found := []string{}
+	for _, finding := range findings {
+		found = append(found, finding.Code)
+	}
+	return found
+}
+
+const documentedFruit = `Fruit = Schema(
+    doc = "A fruit.",
+    fields = {
+        "name": String(doc = "The name."),
+    },
+)
+`
+
+func TestLintClean(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": documentedFruit,
+		"fruit/STARFIG":    "load(\"//fruit/fruit.star\", \"Fruit\")\n\napple = Fruit(name = \"Apple\")\n",
+	}, "//fruit/fruit.star")
+
+	assert.Equal(t, []Finding{}, findings)
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	findings := lintFile(t, map[string]string{"fruit/STARFIG": "apple = Fruit(\n"}, "//fruit/STARFIG")
+
+	assert.Equal(t, 1, len(findings))
+	assert.Equal(t, "SF000", findings[0].Code)
+	assert.Equal(t, "//fruit/STARFIG", findings[0].File)
+}
+
+func TestLintUnusedLoad(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": documentedFruit,
+		"fruit/STARFIG": `load("//fruit/fruit.star", "Fruit", F = "Fruit")
+
+apple = F(name = "Apple")
+`,
+	}, "//fruit/STARFIG")
+
+	assert.Equal(t, []Finding{{
+		File:    "//fruit/STARFIG",
+		Line:    1,
+		Column:  29,
+		Code:    "SF001",
+		Rule:    "unused-load",
+		Message: "Fruit is loaded from //fruit/fruit.star but never used.",
+	}}, findings)
+	assert.Equal(t, "//fruit/STARFIG:1:29: SF001 unused-load: Fruit is loaded from //fruit/fruit.star but never used.",
+		findings[0].String())
+}
+
+func TestLintLoadUsedByFunction(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": documentedFruit,
+		"fruit/defs.star": `load("//fruit/fruit.star", "Fruit")
+
+def make(name):
+    return Fruit(name = name)
+`,
+	}, "//fruit/defs.star")
+
+	assert.Equal(t, []Finding{}, findings)
+}
+
+func TestLintInstanceInStarFile(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": documentedFruit,
+		"fruit/apple.star": `load("//fruit/fruit.star", "Fruit")
+
+apple = Fruit(name = "Apple")
+
+def make(name):
+    return Fruit(name = name)
+`,
+		"fruit/STARFIG": "load(\"//fruit/apple.star\", \"make\")\n\npear = make(\"Pear\")\n",
+	}, "//fruit/apple.star")
+
+	assert.Equal(t, []string{"SF002"}, codes(findings))
+	assert.Equal(t, int32(3), findings[0].Line)
+}
+
+func TestLintNonInstanceInStarfig(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": documentedFruit,
+		"fruit/STARFIG": `load("//fruit/fruit.star", "Fruit")
+
+names = ["Apple", "Pear"]
+
+def make(name):
+    return Fruit(name = name)
+
+apple = make(names[0])
+pear = Fruit(name = names[1])
+field = String()
+`,
+	}, "//fruit/STARFIG")
+
+	assert.Equal(t, []string{"SF003", "SF003", "SF003"}, codes(findings))
+	assert.Equal(t, []int32{3, 5, 10}, []int32{findings[0].Line, findings[1].Line, findings[2].Line})
+}
+
+func TestLintRequiredWithDefault(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": `Fruit = Schema(
+    doc = "A fruit.",
+    fields = {
+        "name": String(doc = "The name.", required = True, default = "Apple"),
+        "ripe": Bool(doc = "Is it ripe.", required = False, default = True),
+    },
+)
+`,
+		"fruit/STARFIG": "load(\"//fruit/fruit.star\", \"Fruit\")\n",
+	}, "//fruit/fruit.star")
+
+	assert.Equal(t, []string{"SF004"}, codes(findings))
+	assert.Equal(t, int32(4), findings[0].Line)
+	assert.Equal(t, int32(70), findings[0].Column)
+}
+
+func TestLintValidationNeverNone(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": `def _always(name):
+    return "Invalid name."
+
+def _sometimes(name):
+    if len(name) == 0:
+        return "Fruit name cannot be empty."
+    return None
+
+def _implicit(name):
+    if len(name) == 0:
+        return "Fruit name cannot be empty."
+
+def _branches(name):
+    if len(name) == 0:
+        return "Fruit name cannot be empty."
+    else:
+        return "Fruit name is " + name
+
+def _max_length(length):
+    def validate(name):
+        return "Too long."
+    return validate
+
+Fruit = Schema(
+    doc = "A fruit.",
+    fields = {
+        "name": String(
+            doc = "The name.",
+            validations = [_always, _sometimes, _implicit, _branches, _max_length(4), lambda name: "No."],
+        ),
+    },
+)
+`,
+		"fruit/STARFIG": "load(\"//fruit/fruit.star\", \"Fruit\")\n",
+	}, "//fruit/fruit.star")
+
+	messages := []string{}
+	for _, finding := range findings {
+		assert.Equal(t, "SF005", finding.Code)
+		messages = append(messages, finding.Message)
+	}
+	assert.Equal(t, []string{
+		"_always never returns None, so it always fails.",
+		"_branches never returns None, so it always fails.",
+		"_max_length() never returns None, so it always fails.",
+		"lambda never returns None, so it always fails.",
+	}, messages)
+}
+
+func TestLintUnusedSchema(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": documentedFruit + `
+Color = Schema(doc = "A color.", fields = {})
+
+Seed = Schema(doc = "A seed.", fields = {})
+
+Tree = Schema(doc = "A tree.", fields = {"seed": Object(Seed, doc = "The seed.")})
+`,
+		"fruit/STARFIG": "load(\"//fruit/fruit.star\", \"Fruit\", \"Tree\")\n",
+	}, "//fruit/fruit.star")
+
+	assert.Equal(t, []string{"SF006"}, codes(findings))
+	assert.Equal(t, "Schema Color is never loaded or used in fruit.star.", findings[0].Message)
+}
+
+func TestLintShadowedPredeclared(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": `def _check(len):
+    String = len
+    return String
+
+Fruit = Schema(doc = "A fruit.", fields = {"name": String(doc = "The name.", validations = [_check])})
+`,
+		"fruit/STARFIG": "load(\"//fruit/fruit.star\", \"Fruit\")\n",
+	}, "//fruit/fruit.star")
+
+	messages := []string{}
+	for _, finding := range findings {
+		messages = append(messages, finding.Message)
+	}
+	assert.Equal(t, []string{"len shadows the builtin len.", "String shadows the builtin String."}, messages)
+}
+
+func TestLintMissingDoc(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": `Fruit = Schema(
+    fields = {
+        "name": String(),
+        "ripe": Bool(doc = "Is it ripe."),
+    },
+)
+`,
+		"fruit/STARFIG": "load(\"//fruit/fruit.star\", \"Fruit\")\n",
+	}, "//fruit/fruit.star")
+
+	messages := []string{}
+	for _, finding := range findings {
+		assert.Equal(t, "SF008", finding.Code)
+		messages = append(messages, finding.Message)
+	}
+	assert.Equal(t, []string{"Schema Fruit has no doc.", "Field name of Fruit has no doc."}, messages)
+}
+
+func TestLintSuppressions(t *testing.T) {
+	findings := lintFile(t, map[string]string{
+		"fruit/fruit.star": `load("//fruit/color.star", "Color")  # starfig:disable=SF001
+
+# starfig:disable=missing-doc,SF006
+Fruit = Schema(
+    fields = {
+        "name": String(),
+    },
+)
+
+Seed = Schema(
+    doc = "A seed.",
+    fields = {
+        "size": Int(),  # starfig:disable=SF008
+        "kind": String(),
+    },
+)
+`,
+		"fruit/color.star": "Color = Schema(doc = \"A color.\", fields = {})\n",
+		"fruit/STARFIG":    "load(\"//fruit/fruit.star\", \"Seed\")\n",
+	}, "//fruit/fruit.star")
+
+	assert.Equal(t, []string{"SF008"}, codes(findings))
+	assert.Equal(t, "Field kind of Seed has no doc.", findings[0].Message)
+}
+
+func TestValidateOutput(t *testing.T) {
+	assert.Nil(t, ValidateOutput("text"))
+	assert.Nil(t, ValidateOutput("json"))
+	assert.Equal(t, "Unknown output label. Expected text or json.", ValidateOutput("label").Error())
+}
+
+func TestFormatFindings(t *testing.T) {
+	findings := []Finding{
+		{File: "//fruit/STARFIG", Line: 1, Column: 6, Code: "SF001", Rule: "unused-load", Message: "Unused."},
+		{File: "//fruit/STARFIG", Line: 3, Column: 1, Code: "SF003", Rule: "non-instance-in-starfig", Message: "Global."},
+	}
+
+	text, err := FormatFindings(findings, TextOutput)
+	assert.Nil(t, err)
+	assert.Equal(t, "//fruit/STARFIG:1:6: SF001 unused-load: Unused.\n"+
+		"//fruit/STARFIG:3:1: SF003 non-instance-in-starfig: Global.", text)
+
+	data, err := FormatFindings(findings[:1], JSONOutput)
+	assert.Nil(t, err)
+	assert.Equal(t, `[
+  {
+    "file": "//fruit/STARFIG",
+    "line": 1,
+    "column": 6,
+    "code": "SF001",
+    "rule": "unused-load",
+    "message": "Unused."
+  }
+]`, data)
+}
@@ -0,0 +1,397 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+type Rule struct {
+	Code        string
+	Name        string
+	Description string
+}
+
+var (
+	SyntaxError          = Rule{"SF000", "syntax-error", "The file can't be parsed."}
+	UnusedLoad           = Rule{"SF001", "unused-load", "A loaded symbol is never used."}
+	InstanceInStarFile   = Rule{"SF002", "instance-in-star-file", "A schema is instantiated by a global of a .star file."}
+	NonInstanceInStarfig = Rule{"SF003", "non-instance-in-starfig", "A global of a STARFIG file isn't a schema instance."}
+	RequiredWithDefault  = Rule{"SF004", "required-with-default", "A required field declares a default, which is never used."}
+	ValidationNeverNone  = Rule{"SF005", "validation-never-none", "A validation never returns None, so it always fails."}
+	UnusedSchema         = Rule{"SF006", "unused-schema", "A schema is never loaded or used in its file."}
+	ShadowedPredeclared  = Rule{"SF007", "shadowed-predeclared", "A name shadows a builtin, i.e. String = ..."}
+	MissingDoc           = Rule{"SF008", "missing-doc", "A schema or field has no doc."}
+)
+
+var Rules = []Rule{
+	SyntaxError,
+	UnusedLoad,
+	InstanceInStarFile,
+	NonInstanceInStarfig,
+	RequiredWithDefault,
+	ValidationNeverNone,
+	UnusedSchema,
+	ShadowedPredeclared,
+	MissingDoc,
+}
+
+type check func(linter *Linter, source *sourceFile) []Finding
+
+var checks = []check{
+	checkUnusedLoads,
+	checkInstancesInStarFile,
+	checkNonInstancesInStarfig,
+	checkRequiredWithDefault,
+	checkValidationsNeverNone,
+	checkUnusedSchemas,
+	checkShadowedPredeclared,
+	checkMissingDocs,
+}
+
+// The builtins creating a field of a schema.
+var fieldBuiltins = map[string]bool{
+	"Bool": true, "Int": true, "Float": true, "String": true, "Object": true, "List": true, "Ref": true,
+}
+
+// MARK: - Checks
+
+func checkUnusedLoads(linter *Linter, source *sourceFile) []Finding {
+	findings := []Finding{}
+	uses := bindingUses(source.syntax)
+	for _, load := range loads(source.syntax) {
+		for _, to := range load.To {
+			if bound := binding(to); bound != nil && uses[bound.First] == 0 {
+				findings = append(findings, newFinding(source, UnusedLoad, to.NamePos,
+					fmt.Sprintf("%s is loaded from %s but never used.", to.Name, load.ModuleName())))
+			}
+		}
+	}
+	return findings
+}
+
+// Instances can be made by functions in .star files, but not by their globals. Loading the
+// file fails when it does, but only once something loads it.
+func checkInstancesInStarFile(linter *Linter, source *sourceFile) []Finding {
+	if !source.target.IsStarFile() || target.IsTestFile(source.target.Filename) {
+		return []Finding{}
+	}
+
+	findings := []Finding{}
+	schemas := linter.schemaNames(source)
+	for _, stmt := range source.syntax.Stmts {
+		name, call, ok := assignedCall(stmt)
+		if ok && (schemas[callName(call)] || callName(call) == "derive") {
+			findings = append(findings, newFinding(source, InstanceInStarFile, name.NamePos, fmt.Sprintf(
+				"%s is an instance of %s, but schemas can only be instantiated in STARFIG files.",
+				name.Name, callName(call))))
+		}
+	}
+	return findings
+}
+
+// Only globals that are certainly not instances are reported, since a helper function or a
+// loaded name may return an instance.
+func checkNonInstancesInStarfig(linter *Linter, source *sourceFile) []Finding {
+	if !source.target.IsStarFigFile() {
+		return []Finding{}
+	}
+
+	findings := []Finding{}
+	for _, stmt := range source.syntax.Stmts {
+		var name *syntax.Ident
+		switch stmt := stmt.(type) {
+		case *syntax.DefStmt:
+			name = stmt.Name
+		case *syntax.AssignStmt:
+			ident, isIdent := stmt.LHS.(*syntax.Ident)
+			if isIdent && !mayBeInstance(stmt.RHS) {
+				name = ident
+			}
+		}
+		if name != nil {
+			findings = append(findings, newFinding(source, NonInstanceInStarfig, name.NamePos, fmt.Sprintf(
+				"%s is not a schema instance, but STARFIG files can only contain schema instances.",
+				name.Name)))
+		}
+	}
+	return findings
+}
+
+func checkRequiredWithDefault(linter *Linter, source *sourceFile) []Finding {
+	findings := []Finding{}
+	syntax.Walk(source.syntax, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || !fieldBuiltins[callName(call)] {
+			return true
+		}
+		required, hasRequired := keywordArg(call, "required")
+		defaultValue, hasDefault := keywordArg(call, "default")
+		if ident, isIdent := required.(*syntax.Ident); hasRequired && hasDefault && isIdent && ident.Name == "True" {
+			findings = append(findings, newFinding(source, RequiredWithDefault, syntax.Start(defaultValue),
+				fmt.Sprintf("%s() is required, so its default is never used.", callName(call))))
+		}
+		return true
+	})
+	return findings
+}
+
+func checkValidationsNeverNone(linter *Linter, source *sourceFile) []Finding {
+	findings := []Finding{}
+	syntax.Walk(source.syntax, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || !(fieldBuiltins[callName(call)] || callName(call) == "Schema") {
+			return true
+		}
+		for _, keyword := range []string{"validations", "constraints"} {
+			functions, ok := keywordArg(call, keyword)
+			list, isList := functions.(*syntax.ListExpr)
+			if !ok || !isList {
+				continue
+			}
+			for _, function := range list.List {
+				if neverReturnsNone(source.syntax, function) {
+					findings = append(findings, newFinding(source, ValidationNeverNone, syntax.Start(function),
+						fmt.Sprintf("%s never returns None, so it always fails.", describe(function))))
+				}
+			}
+		}
+		return true
+	})
+	return findings
+}
+
+func checkUnusedSchemas(linter *Linter, source *sourceFile) []Finding {
+	findings := []Finding{}
+	uses := bindingUses(source.syntax)
+	loaded := linter.loaded[source.target.Target()]
+	for _, stmt := range source.syntax.Stmts {
+		name, call, ok := assignedCall(stmt)
+		if !ok || callName(call) != "Schema" || loaded[name.Name] {
+			continue
+		}
+		if bound := binding(name); bound != nil && uses[bound.First] == 0 {
+			findings = append(findings, newFinding(source, UnusedSchema, name.NamePos,
+				fmt.Sprintf("Schema %s is never loaded or used in %s.", name.Name, source.target.Filename)))
+		}
+	}
+	return findings
+}
+
+func checkShadowedPredeclared(linter *Linter, source *sourceFile) []Finding {
+	predeclared := native.Predeclared
+	if target.IsTestFile(source.target.Filename) {
+		predeclared = native.TestPredeclared
+	}
+
+	findings := []Finding{}
+	seen := map[*syntax.Ident]bool{}
+	syntax.Walk(source.syntax, func(node syntax.Node) bool {
+		ident, ok := node.(*syntax.Ident)
+		if !ok || seen[ident] {
+			return true
+		}
+		seen[ident] = true
+		bound := binding(ident)
+		if bound == nil || bound.First != ident || bound.Scope == resolve.Predeclared || bound.Scope == resolve.Universal {
+			return true
+		}
+		if predeclared.Has(ident.Name) || starlark.Universe.Has(ident.Name) {
+			findings = append(findings, newFinding(source, ShadowedPredeclared, ident.NamePos,
+				fmt.Sprintf("%s shadows the builtin %s.", ident.Name, ident.Name)))
+		}
+		return true
+	})
+	return findings
+}
+
+func checkMissingDocs(linter *Linter, source *sourceFile) []Finding {
+	findings := []Finding{}
+	for _, stmt := range source.syntax.Stmts {
+		name, call, ok := assignedCall(stmt)
+		if !ok || callName(call) != "Schema" {
+			continue
+		}
+		if _, hasDoc := keywordArg(call, "doc"); !hasDoc {
+			findings = append(findings, newFinding(source, MissingDoc, syntax.Start(call),
+				fmt.Sprintf("Schema %s has no doc.", name.Name)))
+		}
+
+		fields, ok := keywordArg(call, "fields")
+		dict, isDict := fields.(*syntax.DictExpr)
+		if !ok || !isDict {
+			continue
+		}
+		for _, entry := range dict.List {
+			entry := entry.(*syntax.DictEntry)
+			fieldCall, isCall := entry.Value.(*syntax.CallExpr)
+			if !isCall || !fieldBuiltins[callName(fieldCall)] {
+				continue
+			}
+			if _, hasDoc := keywordArg(fieldCall, "doc"); !hasDoc {
+				findings = append(findings, newFinding(source, MissingDoc, syntax.Start(entry),
+					fmt.Sprintf("Field %s of %s has no doc.", describe(entry.Key), name.Name)))
+			}
+		}
+	}
+	return findings
+}
+
+// MARK: - Helpers
+
+// Everything but values that are certainly not instances, i.e. literals and builtins.
+func mayBeInstance(expr syntax.Expr) bool {
+	switch expr := expr.(type) {
+	case *syntax.CallExpr:
+		name := callName(expr)
+		_, isPredeclared := native.Predeclared[name]
+		return name != "select" && name != "settings" && !isPredeclared && !starlark.Universe.Has(name) ||
+			name == "derive"
+	case *syntax.Ident, *syntax.DotExpr, *syntax.IndexExpr, *syntax.ParenExpr, *syntax.CondExpr:
+		return true
+	}
+	return false
+}
+
+// A validation is a function defined in the file, a lambda or a call to a function defined in
+// the file returning a nested function, i.e. not_empty("name").
+func neverReturnsNone(file *syntax.File, function syntax.Expr) bool {
+	switch function := function.(type) {
+	case *syntax.LambdaExpr:
+		return notNone(function.Body)
+	case *syntax.Ident:
+		def := definition(file, function)
+		return def != nil && bodyNeverReturnsNone(def.Body)
+	case *syntax.CallExpr:
+		factory, ok := function.Fn.(*syntax.Ident)
+		if !ok {
+			return false
+		}
+		def := definition(file, factory)
+		if def == nil {
+			return false
+		}
+		for _, stmt := range def.Body {
+			returned, ok := stmt.(*syntax.ReturnStmt)
+			if !ok {
+				continue
+			}
+			name, isIdent := returned.Result.(*syntax.Ident)
+			if !isIdent {
+				continue
+			}
+			if nested := definition(file, name); nested != nil {
+				return bodyNeverReturnsNone(nested.Body)
+			}
+		}
+	}
+	return false
+}
+
+// The function statement of the file an identifier is bound to, if any.
+func definition(file *syntax.File, ident *syntax.Ident) *syntax.DefStmt {
+	bound := binding(ident)
+	if bound == nil || bound.First == nil {
+		return nil
+	}
+	var def *syntax.DefStmt
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if stmt, ok := node.(*syntax.DefStmt); ok && stmt.Name == bound.First {
+			def = stmt
+		}
+		return def == nil
+	})
+	return def
+}
+
+func bodyNeverReturnsNone(body []syntax.Stmt) bool {
+	if !terminates(body) {
+		return false
+	}
+	neverNone := true
+	walkReturns(body, func(stmt *syntax.ReturnStmt) {
+		neverNone = neverNone && stmt.Result != nil && notNone(stmt.Result)
+	})
+	return neverNone
+}
+
+// Reports if the statements always return, rather than falling through to return None.
+func terminates(stmts []syntax.Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *syntax.ReturnStmt:
+			return true
+		case *syntax.IfStmt:
+			if stmt.False != nil && terminates(stmt.True) && terminates(stmt.False) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Calls the function with every return statement of the body, excluding nested functions.
+func walkReturns(stmts []syntax.Stmt, function func(stmt *syntax.ReturnStmt)) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *syntax.ReturnStmt:
+			function(stmt)
+		case *syntax.IfStmt:
+			walkReturns(stmt.True, function)
+			walkReturns(stmt.False, function)
+		case *syntax.ForStmt:
+			walkReturns(stmt.Body, function)
+		case *syntax.WhileStmt:
+			walkReturns(stmt.Body, function)
+		}
+	}
+}
+
+// Reports if the expression can never be None, i.e. a string or a formatted string.
+func notNone(expr syntax.Expr) bool {
+	switch expr := expr.(type) {
+	case *syntax.Literal, *syntax.ListExpr, *syntax.DictExpr, *syntax.TupleExpr, *syntax.Comprehension,
+		*syntax.UnaryExpr, *syntax.LambdaExpr:
+		return true
+	case *syntax.Ident:
+		return expr.Name == "True" || expr.Name == "False"
+	case *syntax.ParenExpr:
+		return notNone(expr.X)
+	case *syntax.CondExpr:
+		return notNone(expr.True) && notNone(expr.False)
+	case *syntax.BinaryExpr:
+		if expr.Op == syntax.AND || expr.Op == syntax.OR {
+			return notNone(expr.X) && notNone(expr.Y)
+		}
+		return true
+	case *syntax.CallExpr:
+		if dot, ok := expr.Fn.(*syntax.DotExpr); ok {
+			_, isLiteral := dot.X.(*syntax.Literal)
+			return isLiteral
+		}
+		name := callName(expr)
+		return name == "str" || name == "repr" || name == "len"
+	}
+	return false
+}
+
+func describe(expr syntax.Expr) string {
+	switch expr := expr.(type) {
+	case *syntax.Ident:
+		return expr.Name
+	case *syntax.Literal:
+		if value, ok := expr.Value.(string); ok {
+			return value
+		}
+		return expr.Raw
+	case *syntax.CallExpr:
+		return fmt.Sprintf("%s()", describe(expr.Fn))
+	case *syntax.LambdaExpr:
+		return "lambda"
+	}
+	return "validation"
+}
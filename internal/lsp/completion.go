@@ -0,0 +1,145 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var keywordPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=($|[^=])`)
+
+// Completes the field names of a schema instantiation, i.e. Fruit(na|). The text is scanned
+// rather than parsed, since the line being typed usually doesn't parse yet.
+func (server *Server) completion(uri string, position Position) []CompletionItem {
+	items := []CompletionItem{}
+	text := server.documents[uri]
+	cursor := offset(text, position)
+
+	// Only the name of an argument is completed, not its value.
+	start := cursor
+	for start > 0 && isIdentifierByte(text[start-1]) {
+		start -= 1
+	}
+	last := -1
+	scanCode(text[:start], func(index int) {
+		if !strings.ContainsRune(" \t\r\n", rune(text[index])) {
+			last = index
+		}
+	})
+	if last < 0 || (text[last] != '(' && text[last] != ',') {
+		return items
+	}
+
+	open, ok := openBracket(text[:start])
+	if !ok || text[open] != '(' {
+		return items
+	}
+	callee := strings.TrimRight(text[:open], " \t")
+	nameStart := len(callee)
+	for nameStart > 0 && isIdentifierByte(callee[nameStart-1]) {
+		nameStart -= 1
+	}
+	schema, found := server.schemas[uri][callee[nameStart:]]
+	if !found {
+		return items
+	}
+
+	provided := map[string]bool{}
+	for _, argument := range splitArguments(text[open+1 : start]) {
+		if match := keywordPattern.FindStringSubmatch(strings.TrimSpace(argument)); match != nil {
+			provided[match[1]] = true
+		}
+	}
+
+	for i, field := range schemaFields(schema) {
+		if provided[field.name] {
+			continue
+		}
+		item := CompletionItem{
+			Label:      field.name,
+			Kind:       fieldCompletion,
+			Detail:     field.detail(),
+			InsertText: fmt.Sprintf("%s = ", field.name),
+			// Fields are listed in the order they are declared in the schema.
+			SortText: fmt.Sprintf("%04d", i),
+		}
+		if field.doc != "" {
+			item.Documentation = &MarkupContent{Kind: markdownKind, Value: field.doc}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Calls the function with the offset of every byte of the code outside of strings and
+// comments.
+func scanCode(code string, function func(index int)) {
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '#':
+			for i < len(code) && code[i] != '\n' {
+				i += 1
+			}
+		case '"', '\'':
+			quote := code[i : i+1]
+			if strings.HasPrefix(code[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			i += len(quote)
+			for i < len(code) && !strings.HasPrefix(code[i:], quote) {
+				if code[i] == '\\' {
+					i += 1
+				} else if code[i] == '\n' && len(quote) == 1 {
+					break
+				}
+				i += 1
+			}
+			i += len(quote) - 1
+		default:
+			function(i)
+		}
+	}
+}
+
+// The offset of the innermost bracket left open at the end of the code.
+func openBracket(code string) (int, bool) {
+	open := []int{}
+	scanCode(code, func(index int) {
+		switch code[index] {
+		case '(', '[', '{':
+			open = append(open, index)
+		case ')', ']', '}':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	})
+	if len(open) == 0 {
+		return 0, false
+	}
+	return open[len(open)-1], true
+}
+
+// Splits the arguments of a call at the commas outside of brackets.
+func splitArguments(code string) []string {
+	arguments := []string{}
+	depth, start := 0, 0
+	scanCode(code, func(index int) {
+		switch code[index] {
+		case '(', '[', '{':
+			depth += 1
+		case ')', ']', '}':
+			depth -= 1
+		case ',':
+			if depth == 0 {
+				arguments = append(arguments, code[start:index])
+				start = index + 1
+			}
+		}
+	})
+	return append(arguments, code[start:])
+}
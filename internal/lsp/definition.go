@@ -0,0 +1,121 @@
+package lsp
+
+import (
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// Loads are followed this many times to find where a loaded name is defined, since a file
+// can load a name to make it available to others.
+const maxLoadDepth int = 16
+
+// The definition of the symbol at the position: the file of a load label, or where a name is
+// defined, following loads into the files they load.
+func (server *Server) definition(uri string, position Position) *Location {
+	file, ok := server.fileTarget(uriToPath(uri))
+	if !ok {
+		return nil
+	}
+	text, ok := server.text(file.Path())
+	if !ok {
+		return nil
+	}
+	parsed, err := parseResolved(file, text)
+	if err != nil {
+		return nil
+	}
+	line, col := toSyntax(text, position)
+
+	for _, stmt := range parsed.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+		if within(load.Module, line, col) {
+			loadedFile, err := target.ParseLoadTarget(file, server.options.Universes, load.ModuleName())
+			if err != nil || !loadedFile.Exists() {
+				return nil
+			}
+			return &Location{URI: pathToURI(loadedFile.Path())}
+		}
+		for i := range load.From {
+			if within(load.From[i], line, col) || within(load.To[i], line, col) {
+				return server.loadedDefinition(file, load, i, 0)
+			}
+		}
+	}
+
+	ident := identAt(parsed, line, col)
+	if ident == nil {
+		return nil
+	}
+	bound, _ := ident.Binding.(*resolve.Binding)
+	if bound == nil || bound.First == nil {
+		return nil
+	}
+	for _, stmt := range parsed.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+		for i, to := range load.To {
+			if to == bound.First {
+				return server.loadedDefinition(file, load, i, 0)
+			}
+		}
+	}
+	return &Location{URI: pathToURI(file.Path()), Range: nodeRange(text, bound.First)}
+}
+
+// Where the i-th symbol of the load statement is defined in the loaded file.
+func (server *Server) loadedDefinition(file target.FileTarget, load *syntax.LoadStmt, i int, depth int) *Location {
+	loadedFile, err := target.ParseLoadTarget(file, server.options.Universes, load.ModuleName())
+	if err != nil || depth >= maxLoadDepth {
+		return nil
+	}
+	text, ok := server.text(loadedFile.Path())
+	if !ok {
+		return nil
+	}
+	parsed, err := syntax.Parse(loadedFile.Path(), text, 0)
+	if err != nil {
+		return nil
+	}
+
+	name := load.From[i].Name
+	for _, stmt := range parsed.Stmts {
+		switch stmt := stmt.(type) {
+		case *syntax.AssignStmt:
+			if ident, ok := stmt.LHS.(*syntax.Ident); ok && ident.Name == name {
+				return &Location{URI: pathToURI(loadedFile.Path()), Range: nodeRange(text, ident)}
+			}
+		case *syntax.DefStmt:
+			if stmt.Name.Name == name {
+				return &Location{URI: pathToURI(loadedFile.Path()), Range: nodeRange(text, stmt.Name)}
+			}
+		case *syntax.LoadStmt:
+			for j, to := range stmt.To {
+				if to.Name == name {
+					return server.loadedDefinition(loadedFile, stmt, j, depth+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// The innermost identifier at the starlark line and column.
+func identAt(file *syntax.File, line int32, col int32) *syntax.Ident {
+	var found *syntax.Ident
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if node == nil || !within(node, line, col) {
+			return node == file
+		}
+		if ident, ok := node.(*syntax.Ident); ok {
+			found = ident
+		}
+		return true
+	})
+	return found
+}
@@ -0,0 +1,134 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+	"go.starlark.net/syntax"
+)
+
+type field struct {
+	name     string
+	kind     string
+	required bool
+	doc      string
+}
+
+func (field field) detail() string {
+	if field.required {
+		return fmt.Sprintf("%s, required", field.kind)
+	}
+	return field.kind
+}
+
+// The fields of the schema in the order they are declared.
+func schemaFields(schema native.SchemaDescriptor) []field {
+	fields := []field{}
+	shape := native.NewSchemaShape(schema)
+	for i, tuple := range schema.Fields.Items() {
+		fields = append(fields, field{
+			name:     shape.Fields[i].Name,
+			kind:     fieldType(shape.Fields[i]),
+			required: shape.Fields[i].Required,
+			doc:      string(tuple.Index(1).(native.Descriptor).Documentation()),
+		})
+	}
+	return fields
+}
+
+// The type of a field as it is declared, i.e. List(Object(//trait/color.star:Color))
+func fieldType(shape native.FieldShape) string {
+	if shape.Item != nil {
+		return fmt.Sprintf("%s(%s)", shape.Type, fieldType(*shape.Item))
+	} else if shape.Inline {
+		return fmt.Sprintf("%s(%s, inline = True)", shape.Type, shape.Schema)
+	} else if shape.Schema != "" {
+		return fmt.Sprintf("%s(%s)", shape.Type, shape.Schema)
+	}
+	return shape.Type
+}
+
+// Describes the schema or field at the position: a schema name, or the name of an argument
+// of a schema instantiation.
+func (server *Server) hover(uri string, position Position) *Hover {
+	file, ok := server.fileTarget(uriToPath(uri))
+	if !ok {
+		return nil
+	}
+	text := server.documents[uri]
+	parsed, err := syntax.Parse(file.Path(), text, 0)
+	if err != nil {
+		return nil
+	}
+	line, col := toSyntax(text, position)
+	ident := identAt(parsed, line, col)
+	if ident == nil {
+		return nil
+	}
+	schemas := server.schemas[uri]
+
+	var contents string
+	syntax.Walk(parsed, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || contents != "" {
+			return contents == ""
+		}
+		callee, isIdent := call.Fn.(*syntax.Ident)
+		if !isIdent {
+			return true
+		}
+		schema, found := schemas[callee.Name]
+		if !found {
+			return true
+		}
+		for _, arg := range call.Args {
+			keyword, ok := arg.(*syntax.BinaryExpr)
+			if ok && keyword.Op == syntax.EQ && keyword.X == ident {
+				contents = fieldHover(schema, ident.Name)
+			}
+		}
+		return true
+	})
+	if schema, found := schemas[ident.Name]; contents == "" && found {
+		contents = schemaHover(ident.Name, schema)
+	}
+	if contents == "" {
+		return nil
+	}
+
+	identRange := nodeRange(text, ident)
+	return &Hover{Contents: MarkupContent{Kind: markdownKind, Value: contents}, Range: &identRange}
+}
+
+func fieldHover(schema native.SchemaDescriptor, name string) string {
+	for _, field := range schemaFields(schema) {
+		if field.name != name {
+			continue
+		}
+		contents := fmt.Sprintf("```starlark\n%s: %s\n```\nField of `%s`", field.name, field.detail(), schema.SKU())
+		if field.doc != "" {
+			contents += "\n\n" + field.doc
+		}
+		return contents
+	}
+	return ""
+}
+
+func schemaHover(name string, schema native.SchemaDescriptor) string {
+	lines := []string{fmt.Sprintf("```starlark\n%s = Schema(...)\n```\n`%s`", name, schema.SKU())}
+	if schema.Doc != "" {
+		lines = append(lines, "", string(schema.Doc))
+	}
+	if fields := schemaFields(schema); len(fields) > 0 {
+		lines = append(lines, "")
+		for _, field := range fields {
+			line := fmt.Sprintf("- `%s`: %s", field.name, field.detail())
+			if field.doc != "" {
+				line += fmt.Sprintf(" — %s", field.doc)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,41 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMessage(t *testing.T) {
+	var output bytes.Buffer
+	err := writeMessage(&output, message{Method: "initialized", Params: json.RawMessage(`{}`)})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Content-Length: 52\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"initialized\",\"params\":{}}",
+		output.String())
+}
+
+func TestReadMessage(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("Content-Type: application/vscode-jsonrpc\r\n" +
+		"content-length: 40\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":7,\"method\":\"exit\"}"))
+	read, err := readMessage(reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "exit", read.Method)
+	assert.Equal(t, "7", string(*read.ID))
+}
+
+func TestReadMessageInvalid(t *testing.T) {
+	for input, expected := range map[string]string{
+		"\r\n{}":                      "Expected a Content-Length header.",
+		"Content-Length: ten\r\n\r\n": "Invalid Content-Length header Content-Length: ten.",
+		"Content-Length: 2\r\n\r\n{]": "Invalid message: invalid character ']' looking for beginning of object key string",
+	} {
+		_, err := readMessage(bufio.NewReader(strings.NewReader(input)))
+		assert.Equal(t, expected, err.Error(), input)
+	}
+}
@@ -0,0 +1,268 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/native"
+	"github.com/jathu/starfig/internal/target"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Server is a language server for .star and STARFIG files. Documents are evaluated as they
+// are edited, so errors are reported without building.
+type Server struct {
+	starverseDir string
+	options      evaluator.Options
+	reader       *bufio.Reader
+	writer       io.Writer
+	// The text of each open document by URI.
+	documents map[string]string
+	// The schemas each open document can instantiate, by URI. They are kept from the last time
+	// the document parsed, so completion works while a line is incomplete.
+	schemas  map[string]map[string]native.SchemaDescriptor
+	shutdown bool
+}
+
+func NewServer(starverseDir string, options evaluator.Options, reader io.Reader, writer io.Writer) *Server {
+	return &Server{
+		starverseDir: starverseDir,
+		options:      options,
+		reader:       bufio.NewReader(reader),
+		writer:       writer,
+		documents:    map[string]string{},
+		schemas:      map[string]map[string]native.SchemaDescriptor{},
+	}
+}
+
+// Serve handles messages until the client exits.
+func (server *Server) Serve() error {
+	for {
+		request, err := readMessage(server.reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if request.Method == "exit" {
+			if !server.shutdown {
+				return fmt.Errorf("The client exited without shutting down the server.")
+			}
+			return nil
+		}
+
+		result, requestErr := server.handle(request)
+		// Notifications have no ID and are never answered.
+		if request.ID == nil {
+			continue
+		}
+		response := message{ID: request.ID, Error: requestErr}
+		if requestErr == nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			raw := json.RawMessage(data)
+			response.Result = &raw
+		}
+		err = writeMessage(server.writer, response)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (server *Server) handle(request message) (interface{}, *responseError) {
+	if server.shutdown {
+		return nil, &responseError{Code: invalidRequestCode, Message: "The server is shut down."}
+	}
+
+	switch request.Method {
+	case "initialize":
+		return server.initialize(), nil
+	case "shutdown":
+		server.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		server.documents[params.TextDocument.URI] = params.TextDocument.Text
+		server.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		// Documents are synced in full, so the last change is the whole text.
+		if len(params.ContentChanges) > 0 {
+			server.documents[params.TextDocument.URI] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		}
+		server.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didSave":
+		// Saving a file can fix or break the files loading it, so every document is checked again.
+		for uri := range server.documents {
+			server.publishDiagnostics(uri)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(server.documents, params.TextDocument.URI)
+		delete(server.schemas, params.TextDocument.URI)
+		server.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/definition", "textDocument/completion", "textDocument/hover":
+		var params positionParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		switch request.Method {
+		case "textDocument/definition":
+			return server.definition(params.TextDocument.URI, params.Position), nil
+		case "textDocument/completion":
+			return server.completion(params.TextDocument.URI, params.Position), nil
+		default:
+			return server.hover(params.TextDocument.URI, params.Position), nil
+		}
+	default:
+		if request.ID != nil && !strings.HasPrefix(request.Method, "$/") && request.Method != "initialized" {
+			return nil, &responseError{
+				Code: methodNotFoundCode, Message: fmt.Sprintf("Unknown method %s.", request.Method)}
+		}
+	}
+	return nil, nil
+}
+
+func (server *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    fullDocumentSync,
+				"save":      true,
+			},
+			"definitionProvider": true,
+			"hoverProvider":      true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"(", ","},
+			},
+		},
+		"serverInfo": map[string]string{"name": "starfig"},
+	}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: invalidParamsCode, Message: fmt.Sprintf("Invalid params: %s", err)}
+}
+
+func (server *Server) notify(method string, params interface{}) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	// A failed write surfaces on the next response, so the notification is dropped.
+	_ = writeMessage(server.writer, message{Method: method, Params: data})
+}
+
+// MARK: - Diagnostics
+
+func (server *Server) publishDiagnostics(uri string) {
+	text := server.documents[uri]
+	diagnostics := []Diagnostic{}
+
+	file, ok := server.fileTarget(uriToPath(uri))
+	if ok {
+		document := evaluator.EvaluateDocument(server.starverseDir, file, []byte(text), server.options)
+		if _, err := syntax.Parse(file.Path(), text, 0); err == nil {
+			server.schemas[uri] = document.Schemas
+		}
+		for _, documentErr := range document.Errors {
+			line, col := documentErr.Position.Line, documentErr.Position.Col
+			if line < 1 {
+				line, col = 1, 1
+			}
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    lineRange(text, line, col),
+				Severity: errorSeverity,
+				Source:   "starfig",
+				Message:  documentErr.Message,
+			})
+		}
+	}
+
+	server.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+// MARK: - Files
+
+// The file at the path, in the universe or the innermost external universe containing it.
+func (server *Server) fileTarget(path string) (target.FileTarget, bool) {
+	file := target.FileTarget{}
+	if !target.IsSourceFile(filepath.Base(path)) {
+		return file, false
+	}
+
+	roots := map[string]string{"": server.starverseDir}
+	for name, dir := range server.options.Universes {
+		roots[name] = dir
+	}
+	found := false
+	for repository, dir := range roots {
+		relativePath, err := filepath.Rel(dir, path)
+		relativePath = filepath.ToSlash(relativePath)
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, "../") {
+			continue
+		}
+		if found && len(dir) <= len(file.StarverseDir) {
+			continue
+		}
+		pkg := filepath.ToSlash(filepath.Dir(relativePath))
+		if pkg == "." {
+			pkg = ""
+		}
+		file = target.FileTarget{
+			StarverseDir: dir,
+			Package:      pkg,
+			Filename:     filepath.Base(path),
+			Repository:   repository,
+		}
+		found = true
+	}
+	return file, found
+}
+
+// The text of a file, from its open document if it is being edited.
+func (server *Server) text(path string) (string, bool) {
+	if text, found := server.documents[pathToURI(path)]; found {
+		return text, true
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err == nil
+}
+
+// Parses the text of a file and resolves what every identifier is bound to. Undefined names
+// are reported by the diagnostics, so resolve errors are ignored.
+func parseResolved(file target.FileTarget, text string) (*syntax.File, error) {
+	parsed, err := syntax.Parse(file.Path(), text, 0)
+	if err != nil {
+		return nil, err
+	}
+	predeclared := native.Predeclared
+	if target.IsTestFile(file.Filename) {
+		predeclared = native.TestPredeclared
+	}
+	_ = resolve.File(parsed, predeclared.Has, starlark.Universe.Has)
+	return parsed, nil
+}
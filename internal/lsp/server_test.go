@@ -0,0 +1,319 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/jathu/starfig/internal/evaluator"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+)
+
+// A client sends every message up front, then serves them and reads what the server wrote.
+type client struct {
+	t      *testing.T
+	input  bytes.Buffer
+	nextID int
+}
+
+func (client *client) send(id *int, method string, params interface{}) {
+	data, err := json.Marshal(params)
+	assert.Nil(client.t, err)
+	request := message{Method: method, Params: data}
+	if id != nil {
+		raw, err := json.Marshal(*id)
+		assert.Nil(client.t, err)
+		rawID := json.RawMessage(raw)
+		request.ID = &rawID
+	}
+	assert.Nil(client.t, writeMessage(&client.input, request))
+}
+
+func (client *client) request(method string, params interface{}) int {
+	client.nextID += 1
+	id := client.nextID
+	client.send(&id, method, params)
+	return id
+}
+
+func (client *client) notify(method string, params interface{}) {
+	client.send(nil, method, params)
+}
+
+type served struct {
+	err           error
+	responses     map[int]message
+	notifications []message
+}
+
+func (client *client) serve(starverseDir string) served {
+	var output bytes.Buffer
+	server := NewServer(starverseDir, evaluator.Options{}, &client.input, &output)
+	result := served{err: server.Serve(), responses: map[int]message{}}
+
+	reader := bufio.NewReader(&output)
+	for {
+		written, err := readMessage(reader)
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(client.t, err)
+		if written.ID == nil {
+			result.notifications = append(result.notifications, written)
+			continue
+		}
+		var id int
+		assert.Nil(client.t, json.Unmarshal(*written.ID, &id))
+		result.responses[id] = written
+	}
+	return result
+}
+
+func (result served) result(t *testing.T, id int, value interface{}) {
+	response, found := result.responses[id]
+	assert.True(t, found)
+	assert.Nil(t, response.Error)
+	assert.Nil(t, json.Unmarshal(*response.Result, value))
+}
+
+// A null result is decoded as a missing result.
+func (result served) null(t *testing.T, id int) {
+	response, found := result.responses[id]
+	assert.True(t, found)
+	assert.Nil(t, response.Error)
+	assert.Nil(t, response.Result)
+}
+
+func (result served) diagnostics(t *testing.T) []publishDiagnosticsParams {
+	published := []publishDiagnosticsParams{}
+	for _, notification := range result.notifications {
+		assert.Equal(t, "textDocument/publishDiagnostics", notification.Method)
+		var params publishDiagnosticsParams
+		assert.Nil(t, json.Unmarshal(notification.Params, &params))
+		published = append(published, params)
+	}
+	return published
+}
+
+func documentURI(t *testing.T, pkg string, filename string) string {
+	return pathToURI(filepath.Join(tester.GetTestStarverseDir(t), pkg, filename))
+}
+
+func openParams(uri string, text string) didOpenParams {
+	return didOpenParams{TextDocument: textDocumentItem{URI: uri, Text: text}}
+}
+
+func atPosition(uri string, line int, character int) positionParams {
+	return positionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+}
+
+const appleSrc = `load("//fruit/fruit.star", "Fruit")
+load("//trait/STARFIG", "red", "green")
+
+apple = Fruit(
+    name = "Apple",
+    colors = [red, green],
+)
+`
+
+// MARK: - Lifecycle
+
+func TestServeLifecycle(t *testing.T) {
+	client := &client{t: t}
+	initialize := client.request("initialize", map[string]interface{}{})
+	client.notify("initialized", map[string]interface{}{})
+	unknown := client.request("textDocument/rename", map[string]interface{}{})
+	shutdown := client.request("shutdown", nil)
+	afterShutdown := client.request("textDocument/hover", atPosition("file:///STARFIG", 0, 0))
+	client.notify("exit", nil)
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	assert.Nil(t, result.err)
+	var capabilities struct {
+		Capabilities struct {
+			DefinitionProvider bool `json:"definitionProvider"`
+			HoverProvider      bool `json:"hoverProvider"`
+		} `json:"capabilities"`
+	}
+	result.result(t, initialize, &capabilities)
+	assert.True(t, capabilities.Capabilities.DefinitionProvider)
+	assert.True(t, capabilities.Capabilities.HoverProvider)
+	assert.Equal(t, &responseError{Code: methodNotFoundCode, Message: "Unknown method textDocument/rename."},
+		result.responses[unknown].Error)
+	result.null(t, shutdown)
+	assert.Equal(t, invalidRequestCode, result.responses[afterShutdown].Error.Code)
+}
+
+func TestServeExitWithoutShutdown(t *testing.T) {
+	client := &client{t: t}
+	client.notify("exit", nil)
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	assert.Equal(t, "The client exited without shutting down the server.", result.err.Error())
+}
+
+// MARK: - Diagnostics
+
+func TestDiagnostics(t *testing.T) {
+	uri := documentURI(t, "fruit", "STARFIG")
+	client := &client{t: t}
+	client.notify("textDocument/didOpen", openParams(uri, appleSrc))
+	client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   textDocumentIdentifier{URI: uri},
+		"contentChanges": []map[string]string{{"text": "load(\"//fruit/fruit.star\", \"Fruit\")\n\napple = Fruit(colors = 416)\n"}},
+	})
+	client.notify("textDocument/didClose", didCloseParams{TextDocument: textDocumentIdentifier{URI: uri}})
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	published := result.diagnostics(t)
+	assert.Equal(t, 3, len(published))
+	assert.Equal(t, []Diagnostic{}, published[0].Diagnostics)
+	assert.Equal(t, 1, len(published[1].Diagnostics))
+	assert.Equal(t, uri, published[1].URI)
+	assert.Equal(t, Range{Position{2, 13}, Position{2, 27}}, published[1].Diagnostics[0].Range)
+	assert.Equal(t, errorSeverity, published[1].Diagnostics[0].Severity)
+	assert.Contains(t, published[1].Diagnostics[0].Message, "colors")
+	assert.Equal(t, []Diagnostic{}, published[2].Diagnostics)
+}
+
+func TestDiagnosticsSyntaxError(t *testing.T) {
+	uri := documentURI(t, "fruit", "STARFIG")
+	client := &client{t: t}
+	client.notify("textDocument/didOpen", openParams(uri, "apple = Fruit(\n"))
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	published := result.diagnostics(t)
+	assert.Equal(t, 1, len(published[0].Diagnostics))
+	assert.Equal(t, 1, published[0].Diagnostics[0].Range.Start.Line)
+}
+
+// MARK: - Definition
+
+func TestDefinition(t *testing.T) {
+	uri := documentURI(t, "fruit", "STARFIG")
+	client := &client{t: t}
+	client.notify("textDocument/didOpen", openParams(uri, appleSrc))
+	label := client.request("textDocument/definition", atPosition(uri, 0, 10))
+	loaded := client.request("textDocument/definition", atPosition(uri, 0, 30))
+	schema := client.request("textDocument/definition", atPosition(uri, 3, 10))
+	instance := client.request("textDocument/definition", atPosition(uri, 5, 16))
+	local := client.request("textDocument/definition", atPosition(uri, 3, 2))
+	field := client.request("textDocument/definition", atPosition(uri, 4, 6))
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	var location Location
+	result.result(t, label, &location)
+	assert.Equal(t, Location{URI: documentURI(t, "fruit", "fruit.star")}, location)
+
+	fruitLocation := Location{URI: documentURI(t, "fruit", "fruit.star"), Range: Range{Position{8, 0}, Position{8, 5}}}
+	result.result(t, loaded, &location)
+	assert.Equal(t, fruitLocation, location)
+	result.result(t, schema, &location)
+	assert.Equal(t, fruitLocation, location)
+
+	result.result(t, instance, &location)
+	assert.Equal(t, documentURI(t, "trait", "STARFIG"), location.URI)
+
+	result.result(t, local, &location)
+	assert.Equal(t, Location{URI: uri, Range: Range{Position{3, 0}, Position{3, 5}}}, location)
+
+	result.null(t, field)
+}
+
+// MARK: - Completion
+
+func TestCompletion(t *testing.T) {
+	uri := documentURI(t, "fruit", "STARFIG")
+	src := `load("//fruit/fruit.star", "Fruit")
+
+apple = Fruit(
+    name = "Apple, pear",
+    co
+banana = Fruit(name = `
+	client := &client{t: t}
+	client.notify("textDocument/didOpen", openParams(uri, appleSrc))
+	client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   textDocumentIdentifier{URI: uri},
+		"contentChanges": []map[string]string{{"text": src}},
+	})
+	remaining := client.request("textDocument/completion", atPosition(uri, 4, 6))
+	all := client.request("textDocument/completion", atPosition(uri, 2, 14))
+	value := client.request("textDocument/completion", atPosition(uri, 5, 22))
+	inString := client.request("textDocument/completion", atPosition(uri, 3, 22))
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	var items []CompletionItem
+	result.result(t, remaining, &items)
+	assert.Equal(t, []CompletionItem{{
+		Label:      "colors",
+		Kind:       fieldCompletion,
+		Detail:     "List(Object(//trait/color.star:Color))",
+		InsertText: "colors = ",
+		SortText:   "0001",
+	}}, items)
+
+	result.result(t, all, &items)
+	labels := []string{}
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	assert.Equal(t, []string{"name", "colors"}, labels)
+
+	result.result(t, value, &items)
+	assert.Equal(t, []CompletionItem{}, items)
+	result.result(t, inString, &items)
+	assert.Equal(t, []CompletionItem{}, items)
+}
+
+// MARK: - Hover
+
+const seedSrc = `Seed = Schema(
+    doc = "A seed of a fruit.",
+    fields = {
+        "size": Int(doc = "The size in mm.", required = True),
+        "kind": String(),
+    },
+)
+
+def make():
+    return Seed(size = 1)
+`
+
+func TestHoverField(t *testing.T) {
+	uri := documentURI(t, "fruit", "seed.star")
+	client := &client{t: t}
+	client.notify("textDocument/didOpen", openParams(uri, seedSrc))
+	field := client.request("textDocument/hover", atPosition(uri, 9, 17))
+	argument := client.request("textDocument/hover", atPosition(uri, 9, 24))
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	var hover Hover
+	result.result(t, field, &hover)
+	assert.Equal(t, Hover{
+		Contents: MarkupContent{
+			Kind:  markdownKind,
+			Value: "```starlark\nsize: Int, required\n```\nField of `//fruit/seed.star:Seed`\n\nThe size in mm.",
+		},
+		Range: &Range{Position{9, 16}, Position{9, 20}},
+	}, hover)
+	result.null(t, argument)
+}
+
+func TestHoverSchema(t *testing.T) {
+	uri := documentURI(t, "fruit", "seed.star")
+	client := &client{t: t}
+	client.notify("textDocument/didOpen", openParams(uri, seedSrc))
+	schema := client.request("textDocument/hover", atPosition(uri, 9, 12))
+	result := client.serve(tester.GetTestStarverseDir(t))
+
+	var hover Hover
+	result.result(t, schema, &hover)
+	assert.Equal(t, "```starlark\nSeed = Schema(...)\n```\n`//fruit/seed.star:Seed`\n\n"+
+		"A seed of a fruit.\n\n"+
+		"- `size`: Int, required — The size in mm.\n"+
+		"- `kind`: String", hover.Contents.Value)
+}
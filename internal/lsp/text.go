@@ -0,0 +1,111 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// LSP positions count UTF-16 code units from zero, while starlark positions count runes
+// from one.
+
+func textLine(text string, line int) string {
+	lines := strings.Split(text, "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line], "\r")
+}
+
+func utf16Length(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// The starlark line and column of a position in the text.
+func toSyntax(text string, position Position) (int32, int32) {
+	units, col := 0, int32(1)
+	for _, r := range textLine(text, position.Line) {
+		if units >= position.Character {
+			break
+		}
+		units += utf16Length(r)
+		col += 1
+	}
+	return int32(position.Line + 1), col
+}
+
+// The position of a starlark line and column in the text.
+func fromSyntax(text string, line int32, col int32) Position {
+	position := Position{Line: int(line) - 1}
+	if position.Line < 0 {
+		return Position{}
+	}
+	runes := []rune(textLine(text, position.Line))
+	for i := 0; i < int(col)-1 && i < len(runes); i++ {
+		position.Character += utf16Length(runes[i])
+	}
+	return position
+}
+
+// The range from the starlark line and column to the end of the line.
+func lineRange(text string, line int32, col int32) Range {
+	start := fromSyntax(text, line, col)
+	end := Position{Line: start.Line}
+	for _, r := range textLine(text, start.Line) {
+		end.Character += utf16Length(r)
+	}
+	if end.Character <= start.Character {
+		end.Character = start.Character + 1
+	}
+	return Range{Start: start, End: end}
+}
+
+func nodeRange(text string, node syntax.Node) Range {
+	start, end := node.Span()
+	return Range{Start: fromSyntax(text, start.Line, start.Col), End: fromSyntax(text, end.Line, end.Col)}
+}
+
+// The byte offset of a position in the text.
+func offset(text string, position Position) int {
+	lineStart := 0
+	for line := 0; line < position.Line; line++ {
+		newline := strings.IndexByte(text[lineStart:], '\n')
+		if newline < 0 {
+			return len(text)
+		}
+		lineStart += newline + 1
+	}
+	units := 0
+	for index, r := range text[lineStart:] {
+		if units >= position.Character || r == '\n' {
+			return lineStart + index
+		}
+		units += utf16Length(r)
+	}
+	return len(text)
+}
+
+// Reports if the starlark line and column is within the node, including its end.
+func within(node syntax.Node, line int32, col int32) bool {
+	start, end := node.Span()
+	afterStart := line > start.Line || line == start.Line && col >= start.Col
+	beforeEnd := line < end.Line || line == end.Line && col <= end.Col
+	return afterStart && beforeEnd
+}
+
+func uriToPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(parsed.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,43 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The fruit emoji is two UTF-16 code units, and the other characters are one.
+const unicodeText = "name = \"🍎\"\r\ncolor = \"é\" # red\n"
+
+func TestToSyntax(t *testing.T) {
+	line, col := toSyntax(unicodeText, Position{0, 11})
+	assert.Equal(t, []int32{1, 11}, []int32{line, col})
+
+	line, col = toSyntax(unicodeText, Position{1, 0})
+	assert.Equal(t, []int32{2, 1}, []int32{line, col})
+}
+
+func TestFromSyntax(t *testing.T) {
+	assert.Equal(t, Position{0, 11}, fromSyntax(unicodeText, 1, 11))
+	assert.Equal(t, Position{1, 9}, fromSyntax(unicodeText, 2, 10))
+	assert.Equal(t, Position{}, fromSyntax(unicodeText, 0, 0))
+}
+
+func TestOffset(t *testing.T) {
+	assert.Equal(t, 12, offset(unicodeText, Position{0, 10}))
+	assert.Equal(t, 15, offset(unicodeText, Position{1, 0}))
+	assert.Equal(t, 14, offset(unicodeText, Position{0, 99}))
+	assert.Equal(t, len(unicodeText), offset(unicodeText, Position{5, 0}))
+}
+
+func TestLineRange(t *testing.T) {
+	assert.Equal(t, Range{Position{1, 8}, Position{1, 17}}, lineRange(unicodeText, 2, 9))
+	assert.Equal(t, Range{Position{2, 0}, Position{2, 1}}, lineRange(unicodeText, 3, 1))
+}
+
+func TestURIs(t *testing.T) {
+	uri := pathToURI("/universe/my fruit/STARFIG")
+
+	assert.Equal(t, "file:///universe/my%20fruit/STARFIG", uri)
+	assert.Equal(t, "/universe/my fruit/STARFIG", uriToPath(uri))
+}
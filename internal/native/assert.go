@@ -0,0 +1,134 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.starlark.net/starlark"
+)
+
+// TestPredeclared are the builtins of *_test.star files, which can use every builtin of
+// .star files and the assertions.
+var TestPredeclared = testPredeclared()
+
+func testPredeclared() starlark.StringDict {
+	predeclared := starlark.StringDict{
+		"assert_eq":      starlark.NewBuiltin("assert_eq", AssertEqProvider),
+		"assert_true":    starlark.NewBuiltin("assert_true", AssertTrueProvider),
+		"assert_fails":   starlark.NewBuiltin("assert_fails", AssertFailsProvider),
+		"expect_invalid": starlark.NewBuiltin("expect_invalid", ExpectInvalidProvider),
+	}
+	for name, value := range Predeclared {
+		predeclared[name] = value
+	}
+	return predeclared
+}
+
+// MARK: - AssertEqProvider
+
+func AssertEqProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var actual, expected starlark.Value
+	var message string
+	err := starlark.UnpackArgs(builtin.Name(), args, kwargs,
+		"actual", &actual, "expected", &expected, "msg?", &message)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	equal, err := starlark.Equal(actual, expected)
+	if err != nil {
+		return starlark.None, err
+	} else if !equal {
+		if message != "" {
+			return starlark.None, errors.New(message)
+		}
+		return starlark.None, fmt.Errorf("Expected %s to equal %s.", actual.String(), expected.String())
+	}
+	return starlark.None, nil
+}
+
+// MARK: - AssertTrueProvider
+
+func AssertTrueProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var condition starlark.Value
+	var message string
+	err := starlark.UnpackArgs(builtin.Name(), args, kwargs, "condition", &condition, "msg?", &message)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	if !condition.Truth() {
+		if message != "" {
+			return starlark.None, errors.New(message)
+		}
+		return starlark.None, fmt.Errorf("Expected %s to be true.", condition.String())
+	}
+	return starlark.None, nil
+}
+
+// MARK: - AssertFailsProvider
+
+// AssertFails calls the function without arguments and expects it to fail with an error
+// matching the regular expression.
+func AssertFailsProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var function starlark.Callable
+	var match string
+	err := starlark.UnpackArgs(builtin.Name(), args, kwargs, "fn", &function, "match", &match)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	_, callErr := starlark.Call(thread, function, starlark.Tuple{}, []starlark.Tuple{})
+	return starlark.None, expectFailure(function.Name(), callErr, match)
+}
+
+// MARK: - ExpectInvalidProvider
+
+// ExpectInvalid instantiates the schema with the keyword arguments and expects it to be
+// invalid, i.e. expect_invalid(Plant, "Expected int", height = "tall"). The match is optional.
+func ExpectInvalidProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if args.Len() < 1 || args.Len() > 2 {
+		return starlark.None, fmt.Errorf(
+			"expect_invalid requires a schema and an optional match. i.e. expect_invalid(Plant, \"Expected int\", height = \"tall\").")
+	}
+	schema, ok := schemaArgument(args[0])
+	contextManager := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+	if !ok {
+		return starlark.None, fmt.Errorf("Expected a schema, but got %s.", args[0])
+	}
+	descriptor, ok := contextManager.GetDescriptor(schema.Name())
+	if !ok {
+		return starlark.None, fmt.Errorf("Expected a schema, but got %s.", args[0])
+	}
+	name := descriptor.SKU()
+	match := ""
+	if args.Len() == 2 {
+		matchValue, ok := starlark.AsString(args[1])
+		if !ok {
+			return starlark.None, fmt.Errorf("Expected match to be a string, but got %s.", args[1])
+		}
+		match = matchValue
+	}
+
+	_, callErr := starlark.Call(thread, schema, starlark.Tuple{}, kwargs)
+	return starlark.None, expectFailure(name, callErr, match)
+}
+
+func expectFailure(name string, err error, match string) error {
+	if err == nil {
+		return fmt.Errorf("Expected %s to fail, but it succeeded.", name)
+	}
+
+	message := err.Error()
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		message = evalErr.Msg
+	}
+	matched, regexErr := regexp.MatchString(match, message)
+	if regexErr != nil {
+		return fmt.Errorf("Invalid match %s: %s", match, regexErr)
+	} else if !matched {
+		return fmt.Errorf("Expected %s to fail with %s, but got: %s", name, match, message)
+	}
+	return nil
+}
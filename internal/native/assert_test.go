@@ -0,0 +1,57 @@
+package native
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestAssertEqProvider(t *testing.T) {
+	assert.Nil(t, execAssertions(t, `assert_eq([1, 2], [1, 2])`))
+	assert.ErrorContains(t, execAssertions(t, `assert_eq(1, 2)`), "Expected 1 to equal 2.")
+	assert.ErrorContains(t, execAssertions(t, `assert_eq(1, 2, msg = "Not the same.")`), "Not the same.")
+}
+
+func TestAssertTrueProvider(t *testing.T) {
+	assert.Nil(t, execAssertions(t, `assert_true(1 < 2)`))
+	assert.ErrorContains(t, execAssertions(t, `assert_true([])`), "Expected [] to be true.")
+}
+
+func TestAssertFailsProvider(t *testing.T) {
+	assert.Nil(t, execAssertions(t, `
+def boom():
+    fail("boom 42")
+
+assert_fails(boom, "boom [0-9]+")
+`))
+	assert.ErrorContains(t, execAssertions(t, `assert_fails(lambda: 1, "boom")`),
+		"Expected lambda to fail, but it succeeded.")
+	assert.ErrorContains(t, execAssertions(t, `assert_fails(lambda: fail("bang"), "boom")`),
+		"Expected lambda to fail with boom, but got: fail: bang")
+	assert.ErrorContains(t, execAssertions(t, `assert_fails(lambda: fail("bang"), "[")`),
+		"Invalid match [")
+}
+
+func TestExpectInvalidProvider(t *testing.T) {
+	// Schemas can only be instantiated once they are loaded.
+	load := `load("//garden/plant.star", "Plant")
+`
+	assert.Nil(t, execAssertions(t, load+`expect_invalid(Plant, "Expected int", name = "Rose", height = "tall")`))
+	assert.Nil(t, execAssertions(t, load+`expect_invalid(Plant)`))
+	assert.ErrorContains(t, execAssertions(t, load+`expect_invalid(Plant, name = "Rose")`),
+		"Expected //garden/plant.star:Plant to fail, but it succeeded.")
+	assert.ErrorContains(t, execAssertions(t, `expect_invalid(len)`), "Expected a schema, but got")
+}
+
+// MARK: - Helpers
+
+func execAssertions(t *testing.T, src string) error {
+	thread := starlark.Thread{Load: LoadProvider}
+	thread.SetLocal(SchemaContextManagerThreadKey, NewSchemaContextManager())
+	thread.SetLocal(starverse.StarverseDirThreadKey, tester.GetTestStarverseDir(t))
+	_, err := starlark.ExecFile(&thread, "assert_test.star", src, TestPredeclared)
+	return err
+}
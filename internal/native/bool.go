@@ -15,6 +15,7 @@ func BoolProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starl
 		UUID:         uuid.New(),
 		DefaultValue: false,
 		Required:     false,
+		Unique:       false,
 		Validations:  []starlark.Callable{},
 	}
 
@@ -22,9 +23,11 @@ func BoolProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starl
 		return starlark.None, fmt.Errorf("Invalid positional arguments %s in Bool().", args)
 	}
 
+	hasDefault := false
 	for kwargName, kwargValue := range util.KwargsToMap(kwargs) {
 		switch kwargName {
 		case "default":
+			hasDefault = true
 			defaultValue, ok := kwargValue.(starlark.Bool)
 			if !ok {
 				return starlark.None, fmt.Errorf(
@@ -38,16 +41,40 @@ func BoolProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starl
 					"Expected required value to be bool, but got %s.", kwargValue)
 			}
 			provider.Required = requiredValue
+		case "unique":
+			uniqueValue, ok := kwargValue.(starlark.Bool)
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected unique value to be bool, but got %s.", kwargValue)
+			}
+			provider.Unique = uniqueValue
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		case "validations":
 			err := extractValidations(&provider.Validations, kwargValue)
 			if err != nil {
 				return starlark.None, err
 			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in Bool().", kwargName)
 		}
 	}
 
+	if provider.Required && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot be required in Bool().")
+	}
+	if hasDefault && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot have a default in Bool().")
+	}
+
 	return provider, nil
 }
 
@@ -57,7 +84,10 @@ type BoolDescriptor struct {
 	UUID         uuid.UUID
 	DefaultValue starlark.Bool
 	Required     starlark.Bool
+	Unique       starlark.Bool
 	Validations  []starlark.Callable
+	Computed     starlark.Callable
+	Doc          starlark.String `json:",omitempty"`
 }
 
 func (descriptor BoolDescriptor) SKU() string {
@@ -72,6 +102,18 @@ func (descriptor BoolDescriptor) IsRequired() starlark.Bool {
 	return descriptor.Required
 }
 
+func (descriptor BoolDescriptor) IsUnique() starlark.Bool {
+	return descriptor.Unique
+}
+
+func (descriptor BoolDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor BoolDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
 func (descriptor BoolDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
 	boolValue, ok := value.(starlark.Bool)
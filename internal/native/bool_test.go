@@ -26,6 +26,7 @@ func TestBoolProvider(t *testing.T) {
 		[]starlark.Tuple{
 			{starlark.String("default"), starlark.Bool(true)},
 			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("unique"), starlark.Bool(true)},
 			{starlark.String("validations"), validations},
 		},
 	)
@@ -34,6 +35,7 @@ func TestBoolProvider(t *testing.T) {
 	provider := value.(BoolDescriptor)
 	assert.Equal(t, starlark.Bool(true), provider.Default())
 	assert.Equal(t, starlark.Bool(true), provider.IsRequired())
+	assert.Equal(t, starlark.Bool(true), provider.IsUnique())
 	tester.AssertSameValidations(t, validations, provider.Validations)
 }
 
@@ -74,6 +76,76 @@ func TestBoolProviderWithInvalidRequiredType(t *testing.T) {
 	assert.ErrorContains(t, err, `Expected required value to be bool, but got 416.`)
 }
 
+func TestBoolProviderWithInvalidUniqueType(t *testing.T) {
+	_, err := BoolProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("unique"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected unique value to be bool, but got 416.`)
+}
+
+func TestBoolProviderWithComputed(t *testing.T) {
+	computed := tester.MockBuiltin()
+	value, err := BoolProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), computed},
+		},
+	)
+
+	assert.Nil(t, err)
+	provider := value.(BoolDescriptor)
+	assert.Same(t, computed, provider.ComputedFunction())
+}
+
+func TestBoolProviderWithInvalidComputedType(t *testing.T) {
+	_, err := BoolProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected computed value to be a function, but got 416.`)
+}
+
+func TestBoolProviderWithRequiredComputed(t *testing.T) {
+	_, err := BoolProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot be required in Bool().`)
+}
+
+func TestBoolProviderWithDefaultComputed(t *testing.T) {
+	_, err := BoolProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("default"), starlark.Bool(true)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot have a default in Bool().`)
+}
+
 func TestBoolProviderWithInvalidValidationsType(t *testing.T) {
 	_, err := BoolProvider(
 		&starlark.Thread{},
@@ -135,6 +207,12 @@ func TestBoolDescriptorIsRequired(t *testing.T) {
 	assert.Equal(t, starlark.Bool(true), descriptor.IsRequired())
 }
 
+func TestBoolDescriptorIsUnique(t *testing.T) {
+	descriptor := BoolDescriptor{Unique: true}
+
+	assert.Equal(t, starlark.Bool(true), descriptor.IsUnique())
+}
+
 func TestBoolDescriptorEvaluate(t *testing.T) {
 	descriptor := BoolDescriptor{
 		Validations: []starlark.Callable{
@@ -188,7 +266,7 @@ func TestBoolDescriptorString(t *testing.T) {
 		DefaultValue: true,
 		Required:     true,
 	}
-	expected := fmt.Sprintf(`{"Type":"BoolDescriptor","Descriptor":{"UUID":"%s","DefaultValue":true,"Required":true,"Validations":null}}`, id)
+	expected := fmt.Sprintf(`{"Type":"BoolDescriptor","Descriptor":{"UUID":"%s","DefaultValue":true,"Required":true,"Unique":false,"Validations":null,"Computed":null}}`, id)
 
 	assert.Equal(t, expected, descriptor.String())
 }
@@ -214,5 +292,5 @@ func TestBoolDescriptorHash(t *testing.T) {
 	hash, err := BoolDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(349392354), hash)
+	assert.Equal(t, uint32(3345285584), hash)
 }
@@ -1,94 +1,322 @@
 package native
 
 import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jathu/starfig/internal/starverse"
 	"github.com/jathu/starfig/internal/target"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 var SchemaContextManagerThreadKey string = "starfig-schema-context-manager"
 
-// MARK: - SchemaContextItem
+// MARK: - SchemaIdentity
+
+// SchemaIdentity is the deterministic name of a schema, which is the file it was defined in
+// followed by the global it was assigned to. i.e. //example/geography/metadata.star:Language
+type SchemaIdentity struct {
+	File target.FileTarget
+	Name string
+}
+
+func (identity SchemaIdentity) Target() string {
+	return fmt.Sprintf("%s:%s", identity.File.Target(), identity.Name)
+}
 
-type SchemaContextItem struct {
-	SchemaName       string
-	SchemaDescriptor SchemaDescriptor
-	FileTarget       target.FileTarget
+func (identity SchemaIdentity) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", identity.Target())), nil
+}
+
+// The provisional identity of a schema created by the current Schema() call, which is used
+// until the module creating it has run and its final identity is resolved. Starlark doesn't
+// tell a builtin which global its result is assigned to, so it is named by the global assigned
+// by the top level statement being executed, and numbered when the statement creates several
+// schemas, i.e. in a loop. A schema created outside of a top level assignment is named by the
+// position of the call.
+func schemaIdentity(thread *starlark.Thread) *SchemaIdentity {
+	pos := callerPosition(thread)
+	identity := &SchemaIdentity{
+		File: fileLocation(thread, pos.Filename()),
+		Name: fmt.Sprintf("Schema@%d:%d", pos.Line, pos.Col),
+	}
+	contextManager, ok := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+	if !ok || thread.CallStackDepth() < 2 {
+		return identity
+	}
+	if name, found := contextManager.assignedGlobal(pos); found {
+		identity.Name = name
+	} else if name, found := ToplevelGlobal(thread); found {
+		identity.Name = name
+	}
+
+	// A schema created by a function called from Go, i.e. a test, is numbered within its
+	// outermost frame instead of a module.
+	depth, isToplevel := toplevelDepth(thread)
+	if !isToplevel {
+		depth = thread.CallStackDepth() - 1
+	}
+	call := schemaCall{execution: thread.DebugFrame(depth).Callable(), name: identity.Name}
+	contextManager.calls[call] += 1
+	if count := contextManager.calls[call]; count > 1 {
+		identity.Name = fmt.Sprintf("%s#%d", identity.Name, count)
+	}
+	return identity
+}
+
+// ResolveSchemas names the schemas created while executing the module by the globals they are
+// bound to once it has run, so a schema made by a helper function is named by the global it
+// is returned to, i.e. Outer = make_schema(). Globals are matched in the order they are
+// assigned, exported globals first. A schema that isn't bound to a global but is nested in
+// one is named by the path of its field, i.e. Outer.inner for an Object(Schema(...)) field.
+func ResolveSchemas(thread *starlark.Thread, path string, globals starlark.StringDict) error {
+	contextManager, ok := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+	if !ok || len(contextManager.pending) == 0 {
+		return nil
+	}
+
+	names := []string{}
+	if file, err := contextManager.parseFile(path); err == nil {
+		for _, stmt := range file.Stmts {
+			if assign, ok := stmt.(*syntax.AssignStmt); ok {
+				if ident, ok := assign.LHS.(*syntax.Ident); ok {
+					names = append(names, ident.Name)
+				}
+			}
+		}
+	}
+	names = append(names, globals.Keys()...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return !strings.HasPrefix(names[i], "_") && strings.HasPrefix(names[j], "_")
+	})
+
+	file := fileLocation(thread, path)
+	resolutions := []schemaResolution{}
+	for _, name := range names {
+		builder, ok := globals[name].(*SchemaBuilder)
+		if !ok || !contextManager.pending[builder.Descriptor.Identity] {
+			continue
+		}
+		delete(contextManager.pending, builder.Descriptor.Identity)
+		resolutions = append(resolutions, schemaResolution{
+			descriptor: builder.Descriptor,
+			identity:   SchemaIdentity{File: file, Name: name},
+		})
+	}
+	for i := 0; i < len(resolutions); i++ {
+		resolutions = append(resolutions, contextManager.nestedResolutions(resolutions[i])...)
+	}
+
+	// The provisional identities are replaced first, since a provisional identity can be the
+	// final identity of another schema.
+	for _, resolution := range resolutions {
+		registered, found := contextManager.schemas[resolution.descriptor.SKU()]
+		if found && registered.Identity == resolution.descriptor.Identity {
+			delete(contextManager.schemas, resolution.descriptor.SKU())
+		}
+	}
+	for _, resolution := range resolutions {
+		*resolution.descriptor.Identity = resolution.identity
+		err := contextManager.RegisterSchema(resolution.descriptor)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The depth of the innermost top level frame, which is the module being executed.
+func toplevelDepth(thread *starlark.Thread) (int, bool) {
+	for i := 1; i < thread.CallStackDepth(); i++ {
+		if thread.CallFrame(i).Name == "<toplevel>" {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// ToplevelGlobal is the global assigned by the top level statement being executed, i.e. apple
+// while executing apple = Fruit(...)
+func ToplevelGlobal(thread *starlark.Thread) (string, bool) {
+	depth, found := toplevelDepth(thread)
+	contextManager, ok := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+	if !found || !ok {
+		return "", false
+	}
+	pos := thread.CallFrame(depth).Pos
+	file, err := contextManager.parseFile(pos.Filename())
+	if err != nil {
+		return "", false
+	}
+	for _, stmt := range file.Stmts {
+		assign, ok := stmt.(*syntax.AssignStmt)
+		if !ok {
+			continue
+		}
+		name, isIdent := assign.LHS.(*syntax.Ident)
+		if !isIdent {
+			continue
+		}
+		start, end := stmt.Span()
+		if !isBefore(pos, start) && isBefore(pos, end) {
+			return name.Name, true
+		}
+	}
+	return "", false
+}
+
+func isBefore(p syntax.Position, q syntax.Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+// The file target of a file being evaluated. When universes are nested, i.e. a vendored
+// directory, the innermost universe is used.
+func fileLocation(thread *starlark.Thread, path string) target.FileTarget {
+	starverseDir, _ := thread.Local(starverse.StarverseDirThreadKey).(string)
+	universes, _ := thread.Local(starverse.UniversesThreadKey).(map[string]string)
+	repository, repositoryDir, pkg := directoryLocation(starverseDir, universes, filepath.Dir(path))
+	if pkg == "" {
+		pkg = "."
+	}
+	return target.FileTarget{
+		StarverseDir: repositoryDir,
+		Package:      pkg,
+		Filename:     filepath.Base(path),
+		Repository:   repository,
+	}
 }
 
 // MARK: - SchemaContextManager
 
+// SchemaContextManager keeps track of the schemas created by a thread, so the schema builders
+// passed to Object, List and Ref can be resolved to their descriptors by name.
 type SchemaContextManager struct {
-	builders map[string]*SchemaContextItem
-	queue    map[string]*SchemaDescriptor
+	schemas map[string]SchemaDescriptor
+	files   map[string]*syntax.File
+	calls   map[schemaCall]int
+	// The schemas whose identity is provisional until the module binding them has run.
+	pending map[*SchemaIdentity]bool
+}
+
+// A statement creating schemas within an execution of a module.
+type schemaCall struct {
+	execution starlark.Callable
+	name      string
 }
 
 func NewSchemaContextManager() SchemaContextManager {
 	return SchemaContextManager{
-		builders: map[string]*SchemaContextItem{},
-		queue:    map[string]*SchemaDescriptor{},
+		schemas: map[string]SchemaDescriptor{},
+		files:   map[string]*syntax.File{},
+		calls:   map[schemaCall]int{},
+		pending: map[*SchemaIdentity]bool{},
 	}
 }
 
-func (manager SchemaContextManager) QueueSeenDescriptor(descriptor SchemaDescriptor) {
-	manager.queue[descriptor.SKU()] = &descriptor
+// Starlark executes a module every time it is loaded, which creates its schemas again with the
+// same identity, so the latest one replaces the previous. A schema can only be replaced by one
+// created by the same Schema() call, so two different schemas never replace each other.
+func (manager SchemaContextManager) RegisterSchema(descriptor SchemaDescriptor) error {
+	registered, found := manager.schemas[descriptor.SKU()]
+	if found && registered.origin.String() != descriptor.origin.String() {
+		return fmt.Errorf("Schema %s is already defined at %s.", descriptor.SKU(), registered.origin)
+	}
+	manager.schemas[descriptor.SKU()] = descriptor
+	return nil
 }
 
-func (manager SchemaContextManager) UpdateRecognizedSchema(
-	schemaBuilder *starlark.Builtin, schemaName string, fileTarget target.FileTarget) {
-	recognizedSchemaSKU := schemaBuilder.Name()
+// The final identity of a schema created while executing a module.
+type schemaResolution struct {
+	descriptor SchemaDescriptor
+	identity   SchemaIdentity
+}
 
-	descriptor, found := manager.queue[recognizedSchemaSKU]
-	if found {
-		manager.builders[recognizedSchemaSKU] = &SchemaContextItem{
-			SchemaName:       schemaName,
-			SchemaDescriptor: *descriptor,
-			FileTarget:       fileTarget,
+// The pending schemas nested in the fields of a resolved schema, which are named by the path
+// of the field.
+func (manager SchemaContextManager) nestedResolutions(parent schemaResolution) []schemaResolution {
+	resolutions := []schemaResolution{}
+	if parent.descriptor.Fields == nil {
+		return resolutions
+	}
+	for _, tuple := range parent.descriptor.Fields.Items() {
+		fieldName, _ := starlark.AsString(tuple.Index(0))
+		nested, ok := NestedSchema(tuple.Index(1).(Descriptor))
+		if !ok || !manager.pending[nested.Identity] {
+			continue
 		}
+		delete(manager.pending, nested.Identity)
+		resolutions = append(resolutions, schemaResolution{
+			descriptor: nested,
+			identity: SchemaIdentity{
+				File: parent.identity.File,
+				Name: fmt.Sprintf("%s.%s", parent.identity.Name, fieldName),
+			},
+		})
+	}
+	return resolutions
+}
 
-		delete(manager.queue, recognizedSchemaSKU)
+// NestedSchema is the schema of an Object, Ref or List field.
+func NestedSchema(descriptor Descriptor) (SchemaDescriptor, bool) {
+	switch typed := descriptor.(type) {
+	case SchemaDescriptor:
+		return typed, true
+	case ObjectDescriptor:
+		return NestedSchema(typed.WrappedDescriptor)
+	case RefDescriptor:
+		return NestedSchema(typed.WrappedDescriptor)
+	case ListDescriptor:
+		return NestedSchema(typed.WrappedDescriptor)
 	}
+	return SchemaDescriptor{}, false
 }
 
 func (manager SchemaContextManager) GetDescriptor(descriptorSKU string) (Descriptor, bool) {
-	item, ok := manager.builders[descriptorSKU]
-	if ok {
-		return item.SchemaDescriptor, true
-	} else {
-		// If the schema has not been loaded using "load", it might be still defined in the current
-		// file, thus we don't currently know it's name or file target. So loop through the queue
-		// to find the SKU.
-		descriptor, found := manager.queue[descriptorSKU]
-		if found {
-			return descriptor, true
-		}
+	descriptor, found := manager.schemas[descriptorSKU]
+	if !found {
+		return BoolDescriptor{}, false
 	}
+	return descriptor, true
+}
 
-	return BoolDescriptor{}, false
+// RegisterFile sets the syntax of a file that differs from the file on disk, i.e. a file
+// being edited, so its schemas are identified by its unsaved source.
+func (manager SchemaContextManager) RegisterFile(path string, file *syntax.File) {
+	manager.files[path] = file
 }
 
-func (manager SchemaContextManager) GetSchemaName(descriptor Descriptor) (string, bool) {
-	item, ok := manager.builders[descriptor.SKU()]
-	if ok {
-		return item.SchemaName, true
-	} else {
-		return "", false
+func (manager SchemaContextManager) parseFile(path string) (*syntax.File, error) {
+	if file, found := manager.files[path]; found {
+		return file, nil
+	}
+	file, err := syntax.Parse(path, nil, 0)
+	if err != nil {
+		return nil, err
 	}
+	manager.files[path] = file
+	return file, nil
 }
 
-// Starlark loads modules multiple times, this causes our schema builder to run
-// multiple times. Which causes the schema to be defined multiple times. However,
-// the schema builder doesn't have context of what file it's in — so we determine
-// if schemas are the same if they are from the same file and have the same name.
-func (manager SchemaContextManager) EqualDescriptor(
-	left SchemaDescriptor, right SchemaDescriptor) bool {
-	leftDesc, leftOk := manager.builders[left.SKU()]
-	rightDesc, rightOk := manager.builders[right.SKU()]
-	if !leftOk || !rightOk {
-		return false
+// The global a top level statement assigns the call at the position to, i.e. Language for
+// Language = Schema(...)
+func (manager SchemaContextManager) assignedGlobal(pos syntax.Position) (string, bool) {
+	file, err := manager.parseFile(pos.Filename())
+	if err != nil {
+		return "", false
 	}
-
-	isSameFile := leftDesc.FileTarget.Target() == rightDesc.FileTarget.Target()
-	isSameSchemaName := leftDesc.SchemaName == rightDesc.SchemaName
-
-	return isSameFile && isSameSchemaName
+	for _, stmt := range file.Stmts {
+		assign, ok := stmt.(*syntax.AssignStmt)
+		if !ok || assign.Op != syntax.EQ {
+			continue
+		}
+		name, isIdent := assign.LHS.(*syntax.Ident)
+		call, isCall := assign.RHS.(*syntax.CallExpr)
+		if isIdent && isCall && call.Lparen.Line == pos.Line && call.Lparen.Col == pos.Col {
+			return name.Name, true
+		}
+	}
+	return "", false
 }
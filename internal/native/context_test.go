@@ -1,192 +1,244 @@
 package native
 
 import (
+	"path/filepath"
 	"testing"
 
-	"github.com/google/uuid"
+	"github.com/jathu/starfig/internal/starverse"
 	"github.com/jathu/starfig/internal/target"
 	"github.com/jathu/starfig/internal/tester"
 	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
-func TestContextQueueSeenDescriptor(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager := NewSchemaContextManager()
-	manager.QueueSeenDescriptor(descriptor)
-
-	assert.Empty(t, manager.builders)
-	assert.Equal(t, 1, len(manager.queue))
-	foundDescriptor, found := manager.queue[descriptor.SKU()]
-	assert.True(t, found)
-	assert.Equal(t, &descriptor, foundDescriptor)
+func makeSchemaDescriptor(name string, fields *starlark.Dict) SchemaDescriptor {
+	return SchemaDescriptor{
+		Identity: &SchemaIdentity{
+			File: target.FileTarget{Package: "example", Filename: "defs.star"},
+			Name: name,
+		},
+		Fields: fields,
+	}
 }
 
-func TestContextUpdateRecognizedSchema(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager := NewSchemaContextManager()
-	manager.QueueSeenDescriptor(descriptor)
-
-	assert.Empty(t, manager.builders)
-	assert.Equal(t, 1, len(manager.queue))
+// MARK: - SchemaIdentity
 
-	fileTarget := target.FileTarget{
-		StarverseDir: tester.GetTestStarverseDir(t),
-		Package:      "example",
-		Filename:     "STARFIG",
+func TestSchemaIdentityTarget(t *testing.T) {
+	identity := SchemaIdentity{
+		File: target.FileTarget{Package: "example/geography", Filename: "metadata.star"},
+		Name: "Language",
 	}
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		fileTarget,
-	)
+	assert.Equal(t, "//example/geography/metadata.star:Language", identity.Target())
 
-	assert.Empty(t, manager.queue)
+	identity.File.Repository = "platform"
+	assert.Equal(t, "@platform//example/geography/metadata.star:Language", identity.Target())
+}
 
-	foundItem, found := manager.builders[descriptor.SKU()]
-	assert.True(t, found)
-	assert.Equal(t, descriptor, foundItem.SchemaDescriptor)
-	assert.Equal(t, "Supreme", foundItem.SchemaName)
-	assert.Equal(t, fileTarget, foundItem.FileTarget)
+func TestSchemaIdentityMarshalJSON(t *testing.T) {
+	data, err := makeSchemaDescriptor("Supreme", nil).Identity.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `"//example/defs.star:Supreme"`, string(data))
 }
 
-func TestContextUpdateRecognizedSchemaWithMissingSKU(t *testing.T) {
-	manager := NewSchemaContextManager()
-	assert.Empty(t, manager.builders)
-	assert.Empty(t, manager.queue)
+func TestSchemaIdentityFromAssignment(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	thread := starlark.Thread{Load: LoadProvider}
+	thread.SetLocal(starverse.StarverseDirThreadKey, testStarverseDir)
+	thread.SetLocal(SchemaContextManagerThreadKey, NewSchemaContextManager())
+
+	path := filepath.Join(testStarverseDir, "trait", "color.star")
+	globals, err := starlark.ExecFile(&thread, path, nil, Predeclared)
+	assert.Nil(t, err)
+	assert.Nil(t, ResolveSchemas(&thread, path, globals))
+	assert.Equal(t, "//trait/color.star:Color", globals["Color"].(*SchemaBuilder).Name())
+
+	// Loading the file again creates a schema with the same identity.
+	loaded, err := starlark.ExecFile(&thread, path, nil, Predeclared)
+	assert.Nil(t, err)
+	assert.Equal(t, globals["Color"].(*SchemaBuilder).Name(), loaded["Color"].(*SchemaBuilder).Name())
+}
 
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName("FakeSKU"),
-		"Supreme",
-		target.FileTarget{},
-	)
+// Executes the source as the file in the test starverse, with its syntax registered so the
+// schemas are identified by the source rather than the file on disk.
+func execSchemaSource(
+	t *testing.T, manager SchemaContextManager, pkg string, filename string, src string) (starlark.StringDict, error) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	thread := starlark.Thread{}
+	thread.SetLocal(starverse.StarverseDirThreadKey, testStarverseDir)
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+
+	path := filepath.Join(testStarverseDir, pkg, filename)
+	file, err := syntax.Parse(path, src, 0)
+	assert.Nil(t, err)
+	manager.RegisterFile(path, file)
+	globals, err := starlark.ExecFile(&thread, path, src, Predeclared)
+	if err != nil {
+		return globals, err
+	}
+	return globals, ResolveSchemas(&thread, path, globals)
+}
 
-	assert.Empty(t, manager.builders)
-	assert.Empty(t, manager.queue)
+func TestSchemaIdentityFromHelper(t *testing.T) {
+	globals, err := execSchemaSource(t, NewSchemaContextManager(), "example", "defs.star", `
+def _make():
+    return Schema(fields = {})
+
+Made = _make()
+Listed = [Schema(fields = {})]
+Looped = [_make() for _ in range(3)]
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "//example/defs.star:Made", globals["Made"].(*SchemaBuilder).Name())
+	assert.Equal(t, "//example/defs.star:Listed",
+		globals["Listed"].(*starlark.List).Index(0).(*SchemaBuilder).Name())
+	looped := globals["Looped"].(*starlark.List)
+	assert.Equal(t, "//example/defs.star:Looped", looped.Index(0).(*SchemaBuilder).Name())
+	assert.Equal(t, "//example/defs.star:Looped#2", looped.Index(1).(*SchemaBuilder).Name())
+	assert.Equal(t, "//example/defs.star:Looped#3", looped.Index(2).(*SchemaBuilder).Name())
 }
 
-func TestContextGetDescriptor(t *testing.T) {
+func TestSchemaIdentityOfNestedSchema(t *testing.T) {
 	manager := NewSchemaContextManager()
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders["SupremeSKU"] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: descriptor,
-		FileTarget:       target.FileTarget{},
+	globals, err := execSchemaSource(t, manager, "example", "defs.star", `
+def make():
+    Inner = Schema(fields = {"name": String()})
+    return Schema(fields = {"inner": Object(Inner), "items": List(Schema(fields = {}))})
+
+Outer = make()
+Holder = Schema(fields = {"x": Object(Schema(fields = {}))})
+`)
+	assert.Nil(t, err)
+	outer := globals["Outer"].(*SchemaBuilder).Descriptor
+	assert.Equal(t, "//example/defs.star:Outer", outer.SKU())
+	inner, _ := NestedSchema(fieldOf(t, outer.Fields, "inner"))
+	assert.Equal(t, "//example/defs.star:Outer.inner", inner.SKU())
+	items, _ := NestedSchema(fieldOf(t, outer.Fields, "items"))
+	assert.Equal(t, "//example/defs.star:Outer.items", items.SKU())
+	holder := globals["Holder"].(*SchemaBuilder).Descriptor
+	inline, _ := NestedSchema(fieldOf(t, holder.Fields, "x"))
+	assert.Equal(t, "//example/defs.star:Holder.x", inline.SKU())
+
+	descriptor, found := manager.GetDescriptor("//example/defs.star:Outer")
+	assert.True(t, found)
+	assert.Equal(t, outer.origin, descriptor.(SchemaDescriptor).origin)
+	_, found = manager.GetDescriptor("//example/defs.star:Outer.inner")
+	assert.True(t, found)
+}
+
+func TestSchemaIdentityIgnoresPosition(t *testing.T) {
+	src := `
+def make():
+    return Schema(fields = {"inner": Object(Schema(fields = {}))})
+
+Outer = make()
+Listed = [Schema(fields = {})]
+`
+	names := func(globals starlark.StringDict) []string {
+		outer := globals["Outer"].(*SchemaBuilder).Descriptor
+		inner, _ := NestedSchema(fieldOf(t, outer.Fields, "inner"))
+		listed := globals["Listed"].(*starlark.List).Index(0).(*SchemaBuilder)
+		return []string{outer.SKU(), inner.SKU(), listed.Name()}
 	}
+	globals, err := execSchemaSource(t, NewSchemaContextManager(), "example", "defs.star", src)
+	assert.Nil(t, err)
+	moved, err := execSchemaSource(t, NewSchemaContextManager(), "example", "defs.star",
+		"# A comment.\n\n"+src)
+	assert.Nil(t, err)
+	assert.Equal(t, names(globals), names(moved))
+}
 
-	foundDescriptor, found := manager.GetDescriptor("SupremeSKU")
+// The descriptor of the field.
+func fieldOf(t *testing.T, fields *starlark.Dict, name string) Descriptor {
+	value, found, err := fields.Get(starlark.String(name))
 	assert.True(t, found)
-	assert.Equal(t, descriptor, foundDescriptor)
+	assert.Nil(t, err)
+	return value.(Descriptor)
 }
 
-func TestContextGetDescriptorFoundInQueue(t *testing.T) {
+func TestSchemasFromSameHelper(t *testing.T) {
 	manager := NewSchemaContextManager()
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.queue["SupremeSKU"] = &descriptor
-
-	foundDescriptor, found := manager.GetDescriptor("SupremeSKU")
+	globals, err := execSchemaSource(t, manager, "example", "defs.star", `
+def make(fields):
+    return Schema(fields = fields)
+
+A = make({"a": String()})
+B = make({"b": String()})
+Holder = Schema(fields = {"x": Object(A)})
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "//example/defs.star:A", globals["A"].(*SchemaBuilder).Name())
+	assert.Equal(t, "//example/defs.star:B", globals["B"].(*SchemaBuilder).Name())
+	descriptor, found := manager.GetDescriptor(globals["A"].(*SchemaBuilder).Name())
 	assert.True(t, found)
-	assert.Equal(t, &descriptor, foundDescriptor)
+	assert.Equal(t, []starlark.Value{starlark.String("a")}, descriptor.(SchemaDescriptor).Fields.Keys())
+
+	_, err = execSchemaSource(t, NewSchemaContextManager(), "example", "STARFIG", `
+def make(fields):
+    return Schema(fields = fields)
+
+A = make({"a": String()})
+B = make({"b": String()})
+Holder = Schema(fields = {"x": Object(A)})
+holder = Holder(x = B(b = "oops"))
+`)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Expected //example/STARFIG:A but got //example/STARFIG:B.")
 }
 
-func TestContextGetDescriptorNotFound(t *testing.T) {
-	manager := NewSchemaContextManager()
-	_, found := manager.GetDescriptor("SupremeSKU")
-	assert.False(t, found)
+func TestSchemaInstantiatedInStarFile(t *testing.T) {
+	_, err := execSchemaSource(t, NewSchemaContextManager(), "example", "defs.star", `
+Supreme = Schema(fields = {})
+_default = [Supreme()]
+`)
+	assert.Contains(t, err.Error(),
+		"Unable to instantiate //example/defs.star:Supreme in defs.star. Schema types can only be instantiated in STARFIG files.")
 }
 
-func TestContextGetSchemaName(t *testing.T) {
+// MARK: - SchemaContextManager
+
+func TestContextRegisterSchema(t *testing.T) {
 	manager := NewSchemaContextManager()
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[descriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: descriptor,
-		FileTarget:       target.FileTarget{},
-	}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 
-	foundSchemaName, found := manager.GetSchemaName(descriptor)
+	foundDescriptor, found := manager.GetDescriptor("//example/defs.star:Supreme")
 	assert.True(t, found)
-	assert.Equal(t, "Supreme", foundSchemaName)
-}
-
-func TestContextGetSchemaNameNotFound(t *testing.T) {
-	manager := NewSchemaContextManager()
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	foundSchemaName, found := manager.GetSchemaName(descriptor)
-	assert.False(t, found)
-	assert.Equal(t, "", foundSchemaName)
+	assert.Equal(t, descriptor, foundDescriptor)
 }
 
-func TestContextEqualDescriptorSame(t *testing.T) {
+func TestContextRegisterSchemaAgain(t *testing.T) {
 	manager := NewSchemaContextManager()
-	fileTarget := target.FileTarget{
-		StarverseDir: tester.GetTestStarverseDir(t),
-		Package:      "example",
-		Filename:     "STARFIG",
-	}
-	firstDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[firstDescriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: firstDescriptor,
-		FileTarget:       fileTarget,
-	}
-	secondDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[secondDescriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: secondDescriptor,
-		FileTarget:       fileTarget,
-	}
-
-	assert.True(t, manager.EqualDescriptor(firstDescriptor, secondDescriptor))
+	manager.RegisterSchema(makeSchemaDescriptor("Supreme", nil))
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("ovo"), StringDescriptor{})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
+
+	assert.Equal(t, 1, len(manager.schemas))
+	foundDescriptor, found := manager.GetDescriptor(descriptor.SKU())
+	assert.True(t, found)
+	assert.Equal(t, descriptor, foundDescriptor)
 }
 
-func TestContextEqualDescriptorDifferentFile(t *testing.T) {
+func TestContextRegisterDifferentSchema(t *testing.T) {
 	manager := NewSchemaContextManager()
-	firstDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[firstDescriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: firstDescriptor,
-		FileTarget: target.FileTarget{
-			StarverseDir: tester.GetTestStarverseDir(t),
-			Package:      "example",
-			Filename:     "A",
-		},
-	}
-	secondDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[secondDescriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: secondDescriptor,
-		FileTarget: target.FileTarget{
-			StarverseDir: tester.GetTestStarverseDir(t),
-			Package:      "example",
-			Filename:     "B",
-		},
-	}
-
-	assert.False(t, manager.EqualDescriptor(firstDescriptor, secondDescriptor))
+	path := "example/defs.star"
+	registered := makeSchemaDescriptor("Supreme", nil)
+	registered.origin = syntax.MakePosition(&path, 1, 11)
+	assert.Nil(t, manager.RegisterSchema(registered))
+	other := makeSchemaDescriptor("Supreme", new(starlark.Dict))
+	other.origin = syntax.MakePosition(&path, 4, 11)
+
+	err := manager.RegisterSchema(other)
+	assert.Equal(t, "Schema //example/defs.star:Supreme is already defined at example/defs.star:1:11.", err.Error())
+	foundDescriptor, found := manager.GetDescriptor(registered.SKU())
+	assert.True(t, found)
+	assert.Equal(t, registered, foundDescriptor)
 }
 
-func TestContextEqualDescriptorDifferentSchemaName(t *testing.T) {
+func TestContextGetDescriptorNotFound(t *testing.T) {
 	manager := NewSchemaContextManager()
-	firstDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[firstDescriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Supreme",
-		SchemaDescriptor: firstDescriptor,
-		FileTarget: target.FileTarget{
-			StarverseDir: tester.GetTestStarverseDir(t),
-			Package:      "example",
-			Filename:     "SAME",
-		},
-	}
-	secondDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.builders[secondDescriptor.SKU()] = &SchemaContextItem{
-		SchemaName:       "Patagonia",
-		SchemaDescriptor: secondDescriptor,
-		FileTarget: target.FileTarget{
-			StarverseDir: tester.GetTestStarverseDir(t),
-			Package:      "example",
-			Filename:     "SAME",
-		},
-	}
-
-	assert.False(t, manager.EqualDescriptor(firstDescriptor, secondDescriptor))
+	_, found := manager.GetDescriptor("//example/defs.star:Supreme")
+	assert.False(t, found)
 }
@@ -0,0 +1,121 @@
+package native
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jathu/starfig/internal/util"
+	"go.starlark.net/starlark"
+)
+
+// MARK: - DeriveProvider
+
+func DeriveProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if args.Len() != 1 {
+		return starlark.None, fmt.Errorf(
+			"derive requires a single schema instance. i.e. derive(base, foo = 1).")
+	}
+
+	base, ok := args[0].(SchemaResult)
+	if !ok {
+		return starlark.None, fmt.Errorf(
+			"derive can only derive from a schema instance, not %s.", args[0])
+	}
+
+	return base.Derive(thread, kwargs)
+}
+
+// Derive creates a copy of the instance with the overrides applied, which runs through the
+// full evaluation again. A dict override of an object field is applied to the nested instance.
+func (result SchemaResult) Derive(
+	thread *starlark.Thread, kwargs []starlark.Tuple) (SchemaResult, error) {
+	arguments := new(starlark.Dict)
+	if result.Arguments != nil {
+		for _, tuple := range result.Arguments.Items() {
+			arguments.SetKey(tuple.Index(0), tuple.Index(1))
+		}
+	}
+
+	for name, override := range util.KwargsToMap(kwargs) {
+		overrideDict, ok := override.(*starlark.Dict)
+		if ok {
+			nested, ok, err := result.deriveNested(thread, name, overrideDict)
+			if err != nil {
+				return result, err
+			} else if ok {
+				override = nested
+			}
+		}
+		arguments.SetKey(starlark.String(name), override)
+	}
+
+	derived := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: result.SchemaDescriptor,
+		Evaluated:        result.SchemaDescriptor.Default().(*starlark.Dict),
+		Arguments:        arguments,
+		Position:         callerPosition(thread),
+		Base:             &result,
+	}
+	err := derived.Evaluate(thread, starlark.Tuple{}, dictToKwargs(arguments))
+	if err != nil {
+		return derived, fmt.Errorf("%s (derived from %s)", err, result.Position)
+	}
+	derived.Freeze()
+
+	return derived, nil
+}
+
+// Derives the nested instance of an object field. If the base didn't provide the field, the
+// overrides are applied to a new instance of the object schema.
+func (result SchemaResult) deriveNested(
+	thread *starlark.Thread, name string, overrides *starlark.Dict) (SchemaResult, bool, error) {
+	fieldDescriptorValue, found, err := result.SchemaDescriptor.Fields.Get(starlark.String(name))
+	if err != nil || !found {
+		return result, false, nil
+	}
+	objectDescriptor, ok := fieldDescriptorValue.(ObjectDescriptor)
+	if !ok {
+		return result, false, nil
+	}
+	nestedDescriptor, ok := objectDescriptor.WrappedDescriptor.(SchemaDescriptor)
+	if !ok {
+		return result, false, nil
+	}
+
+	nestedBase := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: nestedDescriptor,
+		Evaluated:        nestedDescriptor.Default().(*starlark.Dict),
+		Arguments:        new(starlark.Dict),
+		Position:         result.Position,
+	}
+	if result.Arguments != nil {
+		baseValue, found, _ := result.Arguments.Get(starlark.String(name))
+		baseInstance, ok := baseValue.(SchemaResult)
+		if found && ok {
+			nestedBase = baseInstance
+		}
+	}
+
+	kwargs := []starlark.Tuple{}
+	for _, tuple := range overrides.Items() {
+		_, ok := tuple.Index(0).(starlark.String)
+		if !ok {
+			return result, false, fmt.Errorf(
+				"Expected override keys of %s to be strings, but got %s.", name, tuple.Index(0))
+		}
+		kwargs = append(kwargs, tuple)
+	}
+
+	nested, err := nestedBase.Derive(thread, kwargs)
+	return nested, true, err
+}
+
+func dictToKwargs(dict *starlark.Dict) []starlark.Tuple {
+	kwargs := []starlark.Tuple{}
+	for _, tuple := range dict.Items() {
+		kwargs = append(kwargs, tuple)
+	}
+	return kwargs
+}
@@ -0,0 +1,174 @@
+package native
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// MARK: - DeriveProvider
+
+func TestDeriveProvider(t *testing.T) {
+	thread, base := makeDeriveBase(t)
+	value, err := DeriveProvider(
+		thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{base},
+		[]starlark.Tuple{
+			{starlark.String("name"), starlark.String("Tulip")},
+		},
+	)
+
+	assert.Nil(t, err)
+	derived := value.(SchemaResult)
+	assert.NotEqual(t, base.UUID, derived.UUID)
+	assert.Equal(t, base.UUID, derived.Base.UUID)
+	assert.Equal(t, starlark.String("Tulip"), getField(t, derived, "name"))
+	assert.Equal(t, starlark.MakeInt(2), getField(t, derived, "height"))
+	assert.Equal(t, starlark.String("Rose"), getField(t, base, "name"))
+}
+
+func TestDeriveProviderNoArguments(t *testing.T) {
+	_, err := DeriveProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "derive requires a single schema instance. i.e. derive(base, foo = 1).")
+}
+
+func TestDeriveProviderNonSchemaResult(t *testing.T) {
+	_, err := DeriveProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{starlark.MakeInt(416)},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "derive can only derive from a schema instance, not 416.")
+}
+
+func TestDeriveProviderInvalidOverride(t *testing.T) {
+	thread, base := makeDeriveBase(t)
+	_, err := DeriveProvider(
+		thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{base},
+		[]starlark.Tuple{
+			{starlark.String("height"), starlark.String("tall")},
+		},
+	)
+
+	assert.ErrorContains(t, err,
+		"Invalid field height in //example/defs.star:Plant: Expected int type but got \"tall\". (derived from plant:3:1)")
+}
+
+func TestDeriveProviderComputedOverride(t *testing.T) {
+	thread, base := makeDeriveBase(t)
+	base.SchemaDescriptor.Fields.SetKey(
+		starlark.String("label"), StringDescriptor{Computed: tester.MockBuiltin()})
+	_, err := DeriveProvider(
+		thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{base},
+		[]starlark.Tuple{
+			{starlark.String("label"), starlark.String("Tulip")},
+		},
+	)
+
+	assert.ErrorContains(t, err, "Cannot set computed field label in //example/defs.star:Plant.")
+}
+
+func TestDeriveProviderNestedOverride(t *testing.T) {
+	thread, base := makeDeriveBase(t)
+	overrides := new(starlark.Dict)
+	overrides.SetKey(starlark.String("green"), starlark.MakeInt(128))
+	value, err := DeriveProvider(
+		thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{base},
+		[]starlark.Tuple{
+			{starlark.String("color"), overrides},
+		},
+	)
+
+	assert.Nil(t, err)
+	expected := new(starlark.Dict)
+	expected.SetKey(starlark.String("red"), starlark.MakeInt(255))
+	expected.SetKey(starlark.String("green"), starlark.MakeInt(128))
+	expected.Freeze()
+	assert.Equal(t, expected, getField(t, value.(SchemaResult), "color"))
+}
+
+func TestDeriveProviderNestedOverrideNonStringKey(t *testing.T) {
+	thread, base := makeDeriveBase(t)
+	overrides := new(starlark.Dict)
+	overrides.SetKey(starlark.MakeInt(416), starlark.MakeInt(128))
+	_, err := DeriveProvider(
+		thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{base},
+		[]starlark.Tuple{
+			{starlark.String("color"), overrides},
+		},
+	)
+
+	assert.ErrorContains(t, err, "Expected override keys of color to be strings, but got 416.")
+}
+
+// MARK: - Helpers
+
+func makeDeriveBase(t *testing.T) (*starlark.Thread, SchemaResult) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+
+	colorFields := new(starlark.Dict)
+	colorFields.SetKey(starlark.String("red"), IntDescriptor{})
+	colorFields.SetKey(starlark.String("green"), IntDescriptor{})
+	colorDescriptor := makeSchemaDescriptor("Color", colorFields)
+	manager.RegisterSchema(colorDescriptor)
+
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("name"), StringDescriptor{Required: true})
+	fields.SetKey(starlark.String("height"), IntDescriptor{})
+	fields.SetKey(starlark.String("color"), ObjectDescriptor{WrappedDescriptor: colorDescriptor})
+	descriptor := makeSchemaDescriptor("Plant", fields)
+	manager.RegisterSchema(descriptor)
+
+	color := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: colorDescriptor,
+		Evaluated:        colorDescriptor.Default().(*starlark.Dict),
+		Arguments:        new(starlark.Dict),
+	}
+	color.Arguments.SetKey(starlark.String("red"), starlark.MakeInt(255))
+	assert.Nil(t, color.Evaluate(&thread, starlark.Tuple{}, dictToKwargs(color.Arguments)))
+
+	base := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: descriptor,
+		Evaluated:        descriptor.Default().(*starlark.Dict),
+		Arguments:        new(starlark.Dict),
+		Position:         syntax.MakePosition(&[]string{"plant"}[0], 3, 1),
+	}
+	base.Arguments.SetKey(starlark.String("name"), starlark.String("Rose"))
+	base.Arguments.SetKey(starlark.String("height"), starlark.MakeInt(2))
+	base.Arguments.SetKey(starlark.String("color"), color)
+	assert.Nil(t, base.Evaluate(&thread, starlark.Tuple{}, dictToKwargs(base.Arguments)))
+
+	return &thread, base
+}
+
+func getField(t *testing.T, result SchemaResult, name string) starlark.Value {
+	value, found, err := result.Evaluated.Get(starlark.String(name))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	return value
+}
@@ -15,6 +15,7 @@ func FloatProvider(thread *starlark.Thread, builtin *starlark.Builtin, args star
 		UUID:         uuid.New(),
 		DefaultValue: 0.0,
 		Required:     false,
+		Unique:       false,
 		Validations:  []starlark.Callable{},
 	}
 
@@ -22,9 +23,11 @@ func FloatProvider(thread *starlark.Thread, builtin *starlark.Builtin, args star
 		return starlark.None, fmt.Errorf("Invalid positional arguments %s in Float().", args)
 	}
 
+	hasDefault := false
 	for kwargName, kwargValue := range util.KwargsToMap(kwargs) {
 		switch kwargName {
 		case "default":
+			hasDefault = true
 			defaultValue, ok := kwargValue.(starlark.Float)
 			if !ok {
 				return starlark.None, fmt.Errorf(
@@ -38,16 +41,40 @@ func FloatProvider(thread *starlark.Thread, builtin *starlark.Builtin, args star
 					"Expected required value to be bool, but got %s.", kwargValue)
 			}
 			provider.Required = requiredValue
+		case "unique":
+			uniqueValue, ok := kwargValue.(starlark.Bool)
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected unique value to be bool, but got %s.", kwargValue)
+			}
+			provider.Unique = uniqueValue
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		case "validations":
 			err := extractValidations(&provider.Validations, kwargValue)
 			if err != nil {
 				return starlark.None, err
 			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in Float().", kwargName)
 		}
 	}
 
+	if provider.Required && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot be required in Float().")
+	}
+	if hasDefault && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot have a default in Float().")
+	}
+
 	return provider, nil
 }
 
@@ -57,7 +84,10 @@ type FloatDescriptor struct {
 	UUID         uuid.UUID
 	DefaultValue starlark.Float
 	Required     starlark.Bool
+	Unique       starlark.Bool
 	Validations  []starlark.Callable
+	Computed     starlark.Callable
+	Doc          starlark.String `json:",omitempty"`
 }
 
 func (descriptor FloatDescriptor) SKU() string {
@@ -72,6 +102,18 @@ func (descriptor FloatDescriptor) IsRequired() starlark.Bool {
 	return descriptor.Required
 }
 
+func (descriptor FloatDescriptor) IsUnique() starlark.Bool {
+	return descriptor.Unique
+}
+
+func (descriptor FloatDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor FloatDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
 func (descriptor FloatDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
 	floatValue, ok := value.(starlark.Float)
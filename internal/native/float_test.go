@@ -26,6 +26,7 @@ func TestFloatProvider(t *testing.T) {
 		[]starlark.Tuple{
 			{starlark.String("default"), starlark.Float(3.14)},
 			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("unique"), starlark.Bool(true)},
 			{starlark.String("validations"), validations},
 		},
 	)
@@ -36,6 +37,7 @@ func TestFloatProvider(t *testing.T) {
 
 	assert.Equal(t, starlark.Float(3.14), provider.Default())
 	assert.Equal(t, starlark.Bool(true), provider.IsRequired())
+	assert.Equal(t, starlark.Bool(true), provider.IsUnique())
 	tester.AssertSameValidations(t, validations, provider.Validations)
 }
 
@@ -76,6 +78,76 @@ func TestFloatProviderWithInvalidRequiredType(t *testing.T) {
 	assert.ErrorContains(t, err, `Expected required value to be bool, but got 416.`)
 }
 
+func TestFloatProviderWithInvalidUniqueType(t *testing.T) {
+	_, err := FloatProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("unique"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected unique value to be bool, but got 416.`)
+}
+
+func TestFloatProviderWithComputed(t *testing.T) {
+	computed := tester.MockBuiltin()
+	value, err := FloatProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), computed},
+		},
+	)
+
+	assert.Nil(t, err)
+	provider := value.(FloatDescriptor)
+	assert.Same(t, computed, provider.ComputedFunction())
+}
+
+func TestFloatProviderWithInvalidComputedType(t *testing.T) {
+	_, err := FloatProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected computed value to be a function, but got 416.`)
+}
+
+func TestFloatProviderWithRequiredComputed(t *testing.T) {
+	_, err := FloatProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot be required in Float().`)
+}
+
+func TestFloatProviderWithDefaultComputed(t *testing.T) {
+	_, err := FloatProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("default"), starlark.Float(4.16)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot have a default in Float().`)
+}
+
 func TestFloatProviderWithInvalidValidationsType(t *testing.T) {
 	_, err := FloatProvider(
 		&starlark.Thread{},
@@ -137,6 +209,12 @@ func TestFloatDescriptorIsRequired(t *testing.T) {
 	assert.Equal(t, starlark.Bool(true), descriptor.IsRequired())
 }
 
+func TestFloatDescriptorIsUnique(t *testing.T) {
+	descriptor := FloatDescriptor{Unique: true}
+
+	assert.Equal(t, starlark.Bool(true), descriptor.IsUnique())
+}
+
 func TestFloatDescriptorEvaluate(t *testing.T) {
 	descriptor := FloatDescriptor{
 		Validations: []starlark.Callable{
@@ -189,7 +267,7 @@ func TestFloatDescriptorString(t *testing.T) {
 		DefaultValue: starlark.Float(3.14),
 		Required:     true,
 	}
-	expected := fmt.Sprintf(`{"Type":"FloatDescriptor","Descriptor":{"UUID":"%s","DefaultValue":3.14,"Required":true,"Validations":null}}`, id)
+	expected := fmt.Sprintf(`{"Type":"FloatDescriptor","Descriptor":{"UUID":"%s","DefaultValue":3.14,"Required":true,"Unique":false,"Validations":null,"Computed":null}}`, id)
 
 	assert.Equal(t, expected, descriptor.String())
 }
@@ -215,5 +293,5 @@ func TestFloatDescriptorHash(t *testing.T) {
 	hash, err := FloatDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(2649988975), hash)
+	assert.Equal(t, uint32(3240688191), hash)
 }
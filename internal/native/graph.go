@@ -0,0 +1,94 @@
+package native
+
+import (
+	"sort"
+
+	"go.starlark.net/starlark"
+)
+
+var DependencyGraphThreadKey string = "starfig-dependency-graph"
+
+// DependencyGraph records what each node depends on while evaluating. Nodes are labels, i.e. a
+// build target //pkg:t depends on //pkg/STARFIG, which depends on the files it loads and the
+// build targets it references.
+type DependencyGraph struct {
+	edges map[string]map[string]bool
+}
+
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{edges: map[string]map[string]bool{}}
+}
+
+func (graph *DependencyGraph) AddEdge(from string, to string) {
+	graph.addNode(from)
+	graph.addNode(to)
+	graph.edges[from][to] = true
+}
+
+func (graph *DependencyGraph) addNode(node string) {
+	if _, found := graph.edges[node]; !found {
+		graph.edges[node] = map[string]bool{}
+	}
+}
+
+func (graph *DependencyGraph) HasNode(node string) bool {
+	_, found := graph.edges[node]
+	return found
+}
+
+// Nodes returns every node in the graph, sorted.
+func (graph *DependencyGraph) Nodes() []string {
+	nodes := []string{}
+	for node := range graph.edges {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Edges returns the direct dependencies of the node, sorted.
+func (graph *DependencyGraph) Edges(node string) []string {
+	edges := []string{}
+	for to := range graph.edges[node] {
+		edges = append(edges, to)
+	}
+	sort.Strings(edges)
+	return edges
+}
+
+// Deps returns the nodes and all their transitive dependencies, sorted.
+func (graph *DependencyGraph) Deps(nodes []string) []string {
+	seen := map[string]bool{}
+	queue := append([]string{}, nodes...)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		queue = append(queue, graph.Edges(node)...)
+	}
+
+	deps := []string{}
+	for node := range seen {
+		deps = append(deps, node)
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// DependsOn reports if the node is, or transitively depends on, the dependency.
+func (graph *DependencyGraph) DependsOn(node string, dependency string) bool {
+	for _, dep := range graph.Deps([]string{node}) {
+		if dep == dependency {
+			return true
+		}
+	}
+	return false
+}
+
+func getDependencyGraph(thread *starlark.Thread) (*DependencyGraph, bool) {
+	graph, ok := thread.Local(DependencyGraphThreadKey).(*DependencyGraph)
+	return graph, ok
+}
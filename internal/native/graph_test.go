@@ -0,0 +1,51 @@
+package native
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/starverse"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestDependencyGraphDeps(t *testing.T) {
+	graph := NewDependencyGraph()
+	graph.AddEdge("//a:x", "//a/STARFIG")
+	graph.AddEdge("//a/STARFIG", "//a/defs.star")
+	graph.AddEdge("//a/STARFIG", "//b:y")
+	graph.AddEdge("//b:y", "//b/STARFIG")
+	graph.AddEdge("//c:z", "//c/STARFIG")
+
+	assert.Equal(t, []string{"//a/defs.star", "//b:y"}, graph.Edges("//a/STARFIG"))
+	assert.Equal(t,
+		[]string{"//a/STARFIG", "//a/defs.star", "//a:x", "//b/STARFIG", "//b:y"},
+		graph.Deps([]string{"//a:x"}))
+	assert.True(t, graph.DependsOn("//a:x", "//b/STARFIG"))
+	assert.True(t, graph.DependsOn("//a:x", "//a:x"))
+	assert.False(t, graph.DependsOn("//c:z", "//a/defs.star"))
+	assert.True(t, graph.HasNode("//c/STARFIG"))
+	assert.False(t, graph.HasNode("//d/STARFIG"))
+	assert.Equal(t, 7, len(graph.Nodes()))
+}
+
+func TestDependencyGraphCycle(t *testing.T) {
+	graph := NewDependencyGraph()
+	graph.AddEdge("//a/STARFIG", "//b/STARFIG")
+	graph.AddEdge("//b/STARFIG", "//a/STARFIG")
+
+	assert.Equal(t, []string{"//a/STARFIG", "//b/STARFIG"}, graph.Deps([]string{"//a/STARFIG"}))
+}
+
+func TestLoadProviderRecordsDependencyGraph(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	graph := NewDependencyGraph()
+	thread := starlark.Thread{Load: LoadProvider}
+	thread.SetLocal(SchemaContextManagerThreadKey, NewSchemaContextManager())
+	thread.SetLocal(starverse.StarverseDirThreadKey, testStarverseDir)
+	thread.SetLocal(DependencyGraphThreadKey, graph)
+
+	_, err := LoadProvider(&thread, "//fruit/fruit.star")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//trait/color.star"}, graph.Edges("//fruit/fruit.star"))
+}
@@ -15,6 +15,7 @@ func IntProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starla
 		UUID:         uuid.New(),
 		DefaultValue: starlark.MakeInt(0),
 		Required:     false,
+		Unique:       false,
 		Validations:  []starlark.Callable{},
 	}
 
@@ -22,9 +23,11 @@ func IntProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starla
 		return starlark.None, fmt.Errorf("Invalid positional arguments %s in Int().", args)
 	}
 
+	hasDefault := false
 	for kwargName, kwargValue := range util.KwargsToMap(kwargs) {
 		switch kwargName {
 		case "default":
+			hasDefault = true
 			defaultValue, ok := kwargValue.(starlark.Int)
 			if !ok {
 				return starlark.None, fmt.Errorf(
@@ -38,16 +41,40 @@ func IntProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starla
 					"Expected required value to be bool, but got %s.", kwargValue)
 			}
 			provider.Required = requiredValue
+		case "unique":
+			uniqueValue, ok := kwargValue.(starlark.Bool)
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected unique value to be bool, but got %s.", kwargValue)
+			}
+			provider.Unique = uniqueValue
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		case "validations":
 			err := extractValidations(&provider.Validations, kwargValue)
 			if err != nil {
 				return starlark.None, err
 			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in Int().", kwargName)
 		}
 	}
 
+	if provider.Required && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot be required in Int().")
+	}
+	if hasDefault && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot have a default in Int().")
+	}
+
 	return provider, nil
 }
 
@@ -57,7 +84,10 @@ type IntDescriptor struct {
 	UUID         uuid.UUID
 	DefaultValue starlark.Int
 	Required     starlark.Bool
+	Unique       starlark.Bool
 	Validations  []starlark.Callable
+	Computed     starlark.Callable
+	Doc          starlark.String `json:",omitempty"`
 }
 
 func (descriptor IntDescriptor) SKU() string {
@@ -72,6 +102,18 @@ func (descriptor IntDescriptor) IsRequired() starlark.Bool {
 	return descriptor.Required
 }
 
+func (descriptor IntDescriptor) IsUnique() starlark.Bool {
+	return descriptor.Unique
+}
+
+func (descriptor IntDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor IntDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
 func (descriptor IntDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
 	intValue, ok := value.(starlark.Int)
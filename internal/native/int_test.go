@@ -26,6 +26,7 @@ func TestIntProvider(t *testing.T) {
 		[]starlark.Tuple{
 			{starlark.String("default"), starlark.MakeInt(416)},
 			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("unique"), starlark.Bool(true)},
 			{starlark.String("validations"), validations},
 		},
 	)
@@ -36,6 +37,7 @@ func TestIntProvider(t *testing.T) {
 
 	assert.Equal(t, starlark.MakeInt(416), provider.Default())
 	assert.Equal(t, starlark.Bool(true), provider.IsRequired())
+	assert.Equal(t, starlark.Bool(true), provider.IsUnique())
 	tester.AssertSameValidations(t, validations, provider.Validations)
 }
 
@@ -76,6 +78,76 @@ func TestIntProviderWithInvalidRequiredType(t *testing.T) {
 	assert.ErrorContains(t, err, `Expected required value to be bool, but got 416.`)
 }
 
+func TestIntProviderWithInvalidUniqueType(t *testing.T) {
+	_, err := IntProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("unique"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected unique value to be bool, but got 416.`)
+}
+
+func TestIntProviderWithComputed(t *testing.T) {
+	computed := tester.MockBuiltin()
+	value, err := IntProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), computed},
+		},
+	)
+
+	assert.Nil(t, err)
+	provider := value.(IntDescriptor)
+	assert.Same(t, computed, provider.ComputedFunction())
+}
+
+func TestIntProviderWithInvalidComputedType(t *testing.T) {
+	_, err := IntProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected computed value to be a function, but got 416.`)
+}
+
+func TestIntProviderWithRequiredComputed(t *testing.T) {
+	_, err := IntProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot be required in Int().`)
+}
+
+func TestIntProviderWithDefaultComputed(t *testing.T) {
+	_, err := IntProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("default"), starlark.MakeInt(416)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot have a default in Int().`)
+}
+
 func TestIntProviderWithInvalidValidationsType(t *testing.T) {
 	_, err := IntProvider(
 		&starlark.Thread{},
@@ -137,6 +209,12 @@ func TestIntDescriptorIsRequired(t *testing.T) {
 	assert.Equal(t, starlark.Bool(true), descriptor.IsRequired())
 }
 
+func TestIntDescriptorIsUnique(t *testing.T) {
+	descriptor := IntDescriptor{Unique: true}
+
+	assert.Equal(t, starlark.Bool(true), descriptor.IsUnique())
+}
+
 func TestIntDescriptorEvaluate(t *testing.T) {
 	descriptor := IntDescriptor{
 		Validations: []starlark.Callable{
@@ -190,7 +268,7 @@ func TestIntDescriptorString(t *testing.T) {
 		DefaultValue: starlark.MakeInt(416),
 		Required:     true,
 	}
-	expected := fmt.Sprintf(`{"Type":"IntDescriptor","Descriptor":{"UUID":"%s","DefaultValue":{},"Required":true,"Validations":null}}`, id)
+	expected := fmt.Sprintf(`{"Type":"IntDescriptor","Descriptor":{"UUID":"%s","DefaultValue":{},"Required":true,"Unique":false,"Validations":null,"Computed":null}}`, id)
 
 	assert.Equal(t, expected, descriptor.String())
 }
@@ -216,5 +294,5 @@ func TestIntDescriptorHash(t *testing.T) {
 	hash, err := IntDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(1043499210), hash)
+	assert.Equal(t, uint32(352969960), hash)
 }
@@ -24,7 +24,7 @@ func ListProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starl
 			"List can only have one type. i.e. List(String), List(Foo).")
 	}
 
-	schemaBuilderFunction, ok := args[0].(*starlark.Builtin)
+	schemaBuilderFunction, ok := schemaArgument(args[0])
 	if ok {
 		switch schemaBuilderFunction.Name() {
 		case "Bool":
@@ -50,11 +50,21 @@ func ListProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starl
 
 	for kwargName, kwargValue := range util.KwargsToMap(kwargs) {
 		switch kwargName {
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		case "validations":
 			err := extractValidations(&provider.Validations, kwargValue)
 			if err != nil {
 				return starlark.None, err
 			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in List().", kwargName)
 		}
@@ -69,6 +79,8 @@ type ListDescriptor struct {
 	UUID              uuid.UUID
 	WrappedDescriptor Descriptor
 	Validations       []starlark.Callable
+	Computed          starlark.Callable
+	Doc               starlark.String `json:",omitempty"`
 }
 
 func (descriptor ListDescriptor) SKU() string {
@@ -83,6 +95,18 @@ func (descriptor ListDescriptor) IsRequired() starlark.Bool {
 	return false
 }
 
+func (descriptor ListDescriptor) IsUnique() starlark.Bool {
+	return false
+}
+
+func (descriptor ListDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor ListDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
 func (descriptor ListDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
 	listValue, ok := value.(*starlark.List)
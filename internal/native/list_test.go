@@ -93,7 +93,7 @@ func TestListProviderWithSchemaArgument(t *testing.T) {
 		},
 	)
 	assert.Nil(t, err)
-	builder := providerResult.(*starlark.Builtin)
+	builder := providerResult.(*SchemaBuilder)
 	provider, err := ListProvider(
 		&thread,
 		tester.MockBuiltin(),
@@ -189,6 +189,12 @@ func TestListDescriptorIsRequired(t *testing.T) {
 	assert.Equal(t, starlark.Bool(false), descriptor.IsRequired())
 }
 
+func TestListDescriptorIsUnique(t *testing.T) {
+	descriptor := ListDescriptor{}
+
+	assert.Equal(t, starlark.Bool(false), descriptor.IsUnique())
+}
+
 func TestListDescriptorEvaluate(t *testing.T) {
 	userValues := starlark.NewList([]starlark.Value{
 		starlark.String("mock1"),
@@ -262,7 +268,7 @@ func TestListDescriptorString(t *testing.T) {
 		UUID:              id,
 		WrappedDescriptor: IntDescriptor{UUID: childId},
 	}
-	expected := fmt.Sprintf(`{"Type":"ListDescriptor","Descriptor":{"UUID":"%s","WrappedDescriptor":{"UUID":"%s","DefaultValue":{},"Required":false,"Validations":null},"Validations":null}}`, id, childId)
+	expected := fmt.Sprintf(`{"Type":"ListDescriptor","Descriptor":{"UUID":"%s","WrappedDescriptor":{"UUID":"%s","DefaultValue":{},"Required":false,"Unique":false,"Validations":null,"Computed":null},"Validations":null,"Computed":null}}`, id, childId)
 
 	assert.Equal(t, expected, descriptor.String())
 }
@@ -288,5 +294,5 @@ func TestListDescriptorHash(t *testing.T) {
 	hash, err := ListDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(971521322), hash)
+	assert.Equal(t, uint32(3270581538), hash)
 }
@@ -2,6 +2,7 @@ package native
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/jathu/starfig/internal/starverse"
@@ -15,7 +16,13 @@ func LoadProvider(thread *starlark.Thread, module string) (starlark.StringDict,
 	results := starlark.StringDict{}
 	starverseDir := thread.Local(starverse.StarverseDirThreadKey).(string)
 
-	fileTarget, err := target.ParseFileTarget(starverseDir, module)
+	universes, _ := thread.Local(starverse.UniversesThreadKey).(map[string]string)
+
+	// Labels are resolved within the universe of the loading file, so files in an external
+	// universe can load their own files.
+	repository, repositoryDir, currentPackage := loadingLocation(thread, starverseDir, universes)
+	loadingDir := target.FileTarget{StarverseDir: repositoryDir, Package: currentPackage, Repository: repository}
+	fileTarget, err := target.ParseLoadTarget(loadingDir, universes, module)
 	if err != nil {
 		return results, err
 	}
@@ -25,18 +32,29 @@ func LoadProvider(thread *starlark.Thread, module string) (starlark.StringDict,
 			"Only .star and STARFIG files can be loaded, %s is invalid.", fileTarget.String())
 	}
 
+	err = checkVisibility(fileTarget, repository, currentPackage)
+	if err != nil {
+		return results, err
+	}
+	if graph, ok := getDependencyGraph(thread); ok && thread.CallStackDepth() > 0 {
+		loadingFile := target.FileTarget{
+			Package:    currentPackage,
+			Filename:   filepath.Base(thread.CallFrame(0).Pos.Filename()),
+			Repository: repository,
+		}
+		graph.AddEdge(loadingFile.Target(), fileTarget.Target())
+	}
+
 	globals, err := starlark.ExecFile(thread, fileTarget.Path(), emptySrc, Predeclared)
 	if err != nil {
 		return results, err
 	}
+	err = ResolveSchemas(thread, fileTarget.Path(), globals)
+	if err != nil {
+		return results, err
+	}
 
-	contextManager := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
 	for name, value := range globals {
-		schemaBuilder, ok := value.(*starlark.Builtin)
-		if ok {
-			contextManager.UpdateRecognizedSchema(schemaBuilder, name, fileTarget)
-		}
-
 		if fileTarget.IsStarFile() {
 			_, ok := value.(SchemaResult)
 			if ok {
@@ -57,3 +75,36 @@ func LoadProvider(thread *starlark.Thread, module string) (starlark.StringDict,
 
 	return results, nil
 }
+
+// The universe and package of the file calling load. When universes are nested, i.e. a
+// vendored directory, the innermost universe is used.
+func loadingLocation(
+	thread *starlark.Thread, starverseDir string, universes map[string]string) (string, string, string) {
+	if thread.CallStackDepth() == 0 {
+		return "", starverseDir, ""
+	}
+	return directoryLocation(starverseDir, universes, filepath.Dir(thread.CallFrame(0).Pos.Filename()))
+}
+
+// The universe and package of a directory.
+func directoryLocation(
+	starverseDir string, universes map[string]string, directory string) (string, string, string) {
+	repository, repositoryDir := "", starverseDir
+	for name, dir := range universes {
+		if isWithin(directory, dir) && len(dir) > len(repositoryDir) {
+			repository, repositoryDir = name, dir
+		}
+	}
+
+	pkg, err := filepath.Rel(repositoryDir, directory)
+	if err != nil || !isWithin(directory, repositoryDir) {
+		return repository, repositoryDir, ""
+	}
+	return repository, repositoryDir, filepath.ToSlash(pkg)
+}
+
+func isWithin(path string, dir string) bool {
+	relativePath, err := filepath.Rel(dir, path)
+	relativePath = filepath.ToSlash(relativePath)
+	return err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, "../")
+}
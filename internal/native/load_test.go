@@ -17,7 +17,7 @@ func TestLoadProviderMissingTarget(t *testing.T) {
 
 	_, err := LoadProvider(&thread, "invalid_target")
 	assert.ErrorContains(t, err,
-		"Load source invalid_target is invalid because it must be absolute.")
+		"Load source invalid_target is invalid because it must be absolute, i.e. //pkg/file.star, or relative to the package, i.e. :file.star.")
 }
 
 func TestLoadProviderInvalidFile(t *testing.T) {
@@ -82,10 +82,19 @@ func TestLoadProviderUpdatesContextManager(t *testing.T) {
 	assert.ElementsMatch(t, []string{"Fruit"}, globals.Keys())
 
 	contextSchemaNames := []string{}
-	for _, schemaContextItem := range manager.builders {
-		itemName, found := manager.GetSchemaName(schemaContextItem.SchemaDescriptor)
-		assert.True(t, found)
-		contextSchemaNames = append(contextSchemaNames, itemName)
+	for name := range manager.schemas {
+		contextSchemaNames = append(contextSchemaNames, name)
 	}
-	assert.ElementsMatch(t, []string{"Fruit", "Color"}, contextSchemaNames)
+	assert.ElementsMatch(t, []string{"//fruit/fruit.star:Fruit", "//trait/color.star:Color"}, contextSchemaNames)
+}
+
+func TestLoadProviderNotVisible(t *testing.T) {
+	testStarverseDir := tester.GetTestStarverseDir(t)
+	thread := starlark.Thread{Load: LoadProvider}
+	thread.SetLocal(SchemaContextManagerThreadKey, NewSchemaContextManager())
+	thread.SetLocal(starverse.StarverseDirThreadKey, testStarverseDir)
+
+	_, err := LoadProvider(&thread, "//recipe/recipe.star")
+	assert.ErrorContains(t, err,
+		"Package // cannot load //recipe/recipe.star because package //recipe is only visible to [//menu/...].")
 }
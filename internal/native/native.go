@@ -8,22 +8,31 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 var Predeclared = starlark.StringDict{
-	"Bool":   starlark.NewBuiltin("Bool", BoolProvider),
-	"Int":    starlark.NewBuiltin("Int", IntProvider),
-	"Float":  starlark.NewBuiltin("Float", FloatProvider),
-	"String": starlark.NewBuiltin("String", StringProvider),
-	"Object": starlark.NewBuiltin("Object", ObjectProvider),
-	"List":   starlark.NewBuiltin("List", ListProvider),
-	"Schema": starlark.NewBuiltin("Schema", SchemaProvider),
+	"Bool":     starlark.NewBuiltin("Bool", BoolProvider),
+	"Int":      starlark.NewBuiltin("Int", IntProvider),
+	"Float":    starlark.NewBuiltin("Float", FloatProvider),
+	"String":   starlark.NewBuiltin("String", StringProvider),
+	"Object":   starlark.NewBuiltin("Object", ObjectProvider),
+	"List":     starlark.NewBuiltin("List", ListProvider),
+	"Ref":      starlark.NewBuiltin("Ref", RefProvider),
+	"Schema":   starlark.NewBuiltin("Schema", SchemaProvider),
+	"derive":   starlark.NewBuiltin("derive", DeriveProvider),
+	"select":   starlark.NewBuiltin("select", SelectProvider),
+	"settings": starlark.NewBuiltin("settings", SettingsProvider),
+	"package":  starlark.NewBuiltin("package", PackageProvider),
 }
 
 type Descriptor interface {
 	SKU() string
 	Default() starlark.Value
 	IsRequired() starlark.Bool
+	IsUnique() starlark.Bool
+	Documentation() starlark.String
+	ComputedFunction() starlark.Callable
 	Evaluate(thread *starlark.Thread, value starlark.Value) (starlark.Value, error)
 	// Conform to starlark.Value
 	String() string
@@ -53,6 +62,14 @@ func hashify(value starlark.Value) (uint32, error) {
 	return hash.Sum32(), nil
 }
 
+// The position of the code calling the current builtin.
+func callerPosition(thread *starlark.Thread) syntax.Position {
+	if thread.CallStackDepth() > 1 {
+		return thread.CallFrame(1).Pos
+	}
+	return syntax.Position{}
+}
+
 func runValidations(
 	thread *starlark.Thread,
 	args starlark.Tuple,
@@ -72,19 +89,46 @@ func runValidations(
 }
 
 func extractValidations(validations *[]starlark.Callable, rawInputValue starlark.Value) error {
-	validationsValue, ok := rawInputValue.(*starlark.List)
+	return extractFunctions("validation", validations, rawInputValue)
+}
+
+func extractConstraints(constraints *[]starlark.Callable, rawInputValue starlark.Value) error {
+	return extractFunctions("constraint", constraints, rawInputValue)
+}
+
+func extractComputed(computed *starlark.Callable, rawInputValue starlark.Value) error {
+	computedFunc, ok := rawInputValue.(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("Expected computed value to be a function, but got %s.", rawInputValue)
+	}
+	*computed = computedFunc
+	return nil
+}
+
+func extractDoc(doc *starlark.String, rawInputValue starlark.Value) error {
+	docValue, ok := rawInputValue.(starlark.String)
+	if !ok {
+		return fmt.Errorf("Expected doc value to be a string, but got %s.", rawInputValue)
+	}
+	*doc = docValue
+	return nil
+}
+
+func extractFunctions(
+	kind string, functions *[]starlark.Callable, rawInputValue starlark.Value) error {
+	functionsValue, ok := rawInputValue.(*starlark.List)
 	if !ok {
 		return fmt.Errorf(
-			"Expected validations value to be a list of functions, but got %s.", rawInputValue)
+			"Expected %ss value to be a list of functions, but got %s.", kind, rawInputValue)
 	}
 
-	for i := 0; i < validationsValue.Len(); i++ {
-		item := validationsValue.Index(i)
+	for i := 0; i < functionsValue.Len(); i++ {
+		item := functionsValue.Index(i)
 		itemFunc, ok := item.(starlark.Callable)
 		if !ok {
-			return fmt.Errorf("Expected validation to be a functions, but got %s.", item)
+			return fmt.Errorf("Expected %s to be a functions, but got %s.", kind, item)
 		}
-		*validations = append(*validations, itemFunc)
+		*functions = append(*functions, itemFunc)
 	}
 
 	return nil
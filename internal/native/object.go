@@ -23,7 +23,7 @@ func ObjectProvider(thread *starlark.Thread, builtin *starlark.Builtin, args sta
 		return starlark.None, fmt.Errorf("Object can only have one type. i.e. Object(Foo).")
 	}
 
-	schemaBuilderFunction, ok := args[0].(*starlark.Builtin)
+	schemaBuilderFunction, ok := schemaArgument(args[0])
 	if !ok {
 		return starlark.None, fmt.Errorf("Object can only be another schema, not %s.", args[0])
 	} else {
@@ -44,16 +44,30 @@ func ObjectProvider(thread *starlark.Thread, builtin *starlark.Builtin, args sta
 					"Expected required value to be bool, but got %s.", kwargValue)
 			}
 			provider.Required = requiredValue
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		case "validations":
 			err := extractValidations(&provider.Validations, kwargValue)
 			if err != nil {
 				return starlark.None, err
 			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in Object().", kwargName)
 		}
 	}
 
+	if provider.Required && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot be required in Object().")
+	}
+
 	return provider, nil
 }
 
@@ -64,6 +78,8 @@ type ObjectDescriptor struct {
 	WrappedDescriptor Descriptor
 	Required          starlark.Bool
 	Validations       []starlark.Callable
+	Computed          starlark.Callable
+	Doc               starlark.String `json:",omitempty"`
 }
 
 func (descriptor ObjectDescriptor) SKU() string {
@@ -78,6 +94,18 @@ func (descriptor ObjectDescriptor) IsRequired() starlark.Bool {
 	return descriptor.Required
 }
 
+func (descriptor ObjectDescriptor) IsUnique() starlark.Bool {
+	return false
+}
+
+func (descriptor ObjectDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor ObjectDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
 func (descriptor ObjectDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
 	evaluatedValue, err := descriptor.WrappedDescriptor.Evaluate(thread, value)
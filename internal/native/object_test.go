@@ -16,8 +16,8 @@ func TestObjectProvider(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New(), Fields: new(starlark.Dict)}
-	manager.QueueSeenDescriptor(descriptor)
+	descriptor := makeSchemaDescriptor("Supreme", new(starlark.Dict))
+	manager.RegisterSchema(descriptor)
 	validations := starlark.NewList([]starlark.Value{
 		tester.MockBuiltin(),
 		tester.MockBuiltin(),
@@ -91,8 +91,8 @@ func TestObjectProviderWithInvalidRequiredType(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(descriptor)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 	_, err := ObjectProvider(
 		&thread,
 		tester.MockBuiltin(),
@@ -109,8 +109,8 @@ func TestObjectProviderWithInvalidValidationsType(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(descriptor)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 	_, err := ObjectProvider(
 		&thread,
 		tester.MockBuiltin(),
@@ -128,8 +128,8 @@ func TestObjectProviderWithInvalidValidationsElementType(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(descriptor)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 	_, err := ObjectProvider(
 		&thread,
 		tester.MockBuiltin(),
@@ -146,8 +146,8 @@ func TestObjectProviderWithUnknownKeyword(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(descriptor)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 	_, err := ObjectProvider(
 		&thread,
 		tester.MockBuiltin(),
@@ -183,6 +183,12 @@ func TestObjectDescriptorIsRequired(t *testing.T) {
 	assert.Equal(t, starlark.Bool(true), descriptor.IsRequired())
 }
 
+func TestObjectDescriptorIsUnique(t *testing.T) {
+	descriptor := ObjectDescriptor{Required: true}
+
+	assert.Equal(t, starlark.Bool(false), descriptor.IsUnique())
+}
+
 func TestObjectDescriptorEvaluate(t *testing.T) {
 	thread := starlark.Thread{}
 	descriptor := ObjectDescriptor{
@@ -245,7 +251,7 @@ func TestObjectDescriptorString(t *testing.T) {
 		WrappedDescriptor: IntDescriptor{UUID: childId},
 		Required:          true,
 	}
-	expected := fmt.Sprintf(`{"Type":"ObjectDescriptor","Descriptor":{"UUID":"%s","WrappedDescriptor":{"UUID":"%s","DefaultValue":{},"Required":false,"Validations":null},"Required":true,"Validations":null}}`, id, childId)
+	expected := fmt.Sprintf(`{"Type":"ObjectDescriptor","Descriptor":{"UUID":"%s","WrappedDescriptor":{"UUID":"%s","DefaultValue":{},"Required":false,"Unique":false,"Validations":null,"Computed":null},"Required":true,"Validations":null,"Computed":null}}`, id, childId)
 
 	assert.Equal(t, expected, descriptor.String())
 }
@@ -271,5 +277,5 @@ func TestObjectDescriptorHash(t *testing.T) {
 	hash, err := ObjectDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(2807002309), hash)
+	assert.Equal(t, uint32(2306571633), hash)
 }
@@ -0,0 +1,214 @@
+package native
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/util"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const publicVisibility string = "//..."
+
+// MARK: - PackageProvider
+
+// PackageProvider declares the package of a STARFIG file. The declaration is read statically
+// when another package loads from this one, so this only validates it.
+func PackageProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if args.Len() > 0 {
+		return starlark.None, fmt.Errorf(
+			"package only takes keyword arguments. i.e. package(visibility = [\"//team/...\"]).")
+	}
+
+	position := callerPosition(thread)
+	if filepath.Base(position.Filename()) != target.StarfigFilename {
+		return starlark.None, fmt.Errorf("package can only be declared in STARFIG files.")
+	}
+
+	for key, value := range util.KwargsToMap(kwargs) {
+		if key != "visibility" {
+			return starlark.None, fmt.Errorf("package got an unexpected argument %s.", key)
+		}
+		list, ok := value.(*starlark.List)
+		if !ok {
+			return starlark.None, fmt.Errorf(
+				"Expected visibility to be a list of packages, but got %s.", value)
+		}
+		for i := 0; i < list.Len(); i++ {
+			entry, ok := starlark.AsString(list.Index(i))
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected visibility to be a list of packages, but got %s.", list.Index(i))
+			}
+			if err := validateVisibility(entry); err != nil {
+				return starlark.None, err
+			}
+		}
+	}
+
+	// The packages loading from this one read the declaration without evaluating it, so it
+	// must be declared the way it is read.
+	contextManager, ok := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+	if !ok {
+		return starlark.None, nil
+	}
+	file, err := contextManager.parseFile(position.Filename())
+	if err != nil {
+		return starlark.None, nil
+	}
+	call, found := packageCall(file)
+	if !found || call.Lparen.Line != position.Line || call.Lparen.Col != position.Col {
+		return starlark.None, fmt.Errorf(
+			"package must be declared once by a top level statement in %s.", position.Filename())
+	}
+	_, err = visibilityFromCall(position.Filename(), call)
+	return starlark.None, err
+}
+
+func validateVisibility(entry string) error {
+	if !strings.HasPrefix(entry, "//") || strings.Contains(entry, ":") {
+		return fmt.Errorf(
+			"Visibility %s is invalid because it must be a package, i.e. //team or //team/...", entry)
+	}
+	return nil
+}
+
+// MARK: - Visibility
+
+// The visibility declared by package(visibility = [...]) in the STARFIG of the package. A
+// package without a declaration is visible to every package.
+func packageVisibility(starverseDir string, pkg string) ([]string, error) {
+	starfigPath := filepath.Join(starverseDir, pkg, target.StarfigFilename)
+	if !util.PathExists(starfigPath) {
+		return []string{publicVisibility}, nil
+	}
+
+	src, err := os.ReadFile(starfigPath)
+	if err != nil {
+		return []string{}, err
+	}
+	file, err := syntax.Parse(starfigPath, src, 0)
+	if err != nil {
+		return []string{}, err
+	}
+
+	if call, found := packageCall(file); found {
+		return visibilityFromCall(starfigPath, call)
+	}
+	return []string{publicVisibility}, nil
+}
+
+// The first top level package(...) statement of the STARFIG file, which declares the package.
+func packageCall(file *syntax.File) (*syntax.CallExpr, bool) {
+	for _, stmt := range file.Stmts {
+		exprStmt, ok := stmt.(*syntax.ExprStmt)
+		if !ok {
+			continue
+		}
+		call, ok := exprStmt.X.(*syntax.CallExpr)
+		if !ok {
+			continue
+		}
+		if ident, ok := call.Fn.(*syntax.Ident); ok && ident.Name == "package" {
+			return call, true
+		}
+	}
+	return nil, false
+}
+
+func visibilityFromCall(starfigPath string, call *syntax.CallExpr) ([]string, error) {
+	for _, arg := range call.Args {
+		binary, ok := arg.(*syntax.BinaryExpr)
+		if !ok || binary.Op != syntax.EQ {
+			continue
+		}
+		if ident, ok := binary.X.(*syntax.Ident); !ok || ident.Name != "visibility" {
+			continue
+		}
+
+		list, ok := binary.Y.(*syntax.ListExpr)
+		if !ok {
+			return []string{}, fmt.Errorf(
+				"Visibility in %s must be a list of string literals.", starfigPath)
+		}
+		visibility := []string{}
+		for _, item := range list.List {
+			literal, ok := item.(*syntax.Literal)
+			if !ok || literal.Token != syntax.STRING {
+				return []string{}, fmt.Errorf(
+					"Visibility in %s must be a list of string literals.", starfigPath)
+			}
+			visibility = append(visibility, literal.Value.(string))
+		}
+		return visibility, nil
+	}
+	return []string{publicVisibility}, nil
+}
+
+// isVisible reports if the package can be seen from the loading package, given the visibility
+// of the package. i.e. //team/a/... matches //team/a and all its subpackages.
+func isVisible(visibility []string, loadingPackage string) bool {
+	if loadingPackage == "." {
+		loadingPackage = ""
+	}
+	for _, entry := range visibility {
+		pattern := path.Clean(strings.TrimPrefix(entry, "//"))
+		if pattern == "..." {
+			return true
+		} else if parent := strings.TrimSuffix(pattern, "/..."); parent != pattern {
+			if loadingPackage == parent || strings.HasPrefix(loadingPackage, parent+"/") {
+				return true
+			}
+		} else if pattern == loadingPackage || (pattern == "." && loadingPackage == "") {
+			return true
+		}
+	}
+	return false
+}
+
+func packageLabel(repository string, pkg string) string {
+	label := "//"
+	if pkg != "." {
+		label += pkg
+	}
+	if repository != "" {
+		return fmt.Sprintf("@%s%s", repository, label)
+	}
+	return label
+}
+
+// checkVisibility returns an error if the loading package can't load the file. Files in the
+// same package are always visible.
+func checkVisibility(
+	fileTarget target.FileTarget, loadingRepository string, loadingPackage string) error {
+	if loadingPackage == "" {
+		loadingPackage = "."
+	}
+	if fileTarget.Repository == loadingRepository && fileTarget.Package == loadingPackage {
+		return nil
+	}
+
+	visibility, err := packageVisibility(fileTarget.StarverseDir, fileTarget.Package)
+	if err != nil {
+		return err
+	}
+
+	// Visibility labels are packages in the same universe, so other universes only see public
+	// packages.
+	visible := isVisible(visibility, loadingPackage)
+	if fileTarget.Repository != loadingRepository {
+		visible = isVisible(visibility, publicVisibility)
+	}
+	if !visible {
+		return fmt.Errorf(
+			"Package %s cannot load %s because package %s is only visible to [%s].",
+			packageLabel(loadingRepository, loadingPackage), fileTarget.Target(),
+			packageLabel(fileTarget.Repository, fileTarget.Package), strings.Join(visibility, ", "))
+	}
+	return nil
+}
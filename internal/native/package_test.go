@@ -0,0 +1,131 @@
+package native
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jathu/starfig/internal/target"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+func TestPackageProvider(t *testing.T) {
+	_, err := execPackage(t, "STARFIG", `package(visibility = ["//team/...", "//public"])`)
+	assert.Nil(t, err)
+}
+
+func TestPackageProviderInStarFile(t *testing.T) {
+	_, err := execPackage(t, "team.star", `package(visibility = ["//team/..."])`)
+	assert.ErrorContains(t, err, "package can only be declared in STARFIG files.")
+}
+
+func TestPackageProviderInvalidVisibility(t *testing.T) {
+	_, err := execPackage(t, "STARFIG", `package(visibility = "//team")`)
+	assert.ErrorContains(t, err, `Expected visibility to be a list of packages, but got "//team".`)
+
+	_, err = execPackage(t, "STARFIG", `package(visibility = ["//team:target"])`)
+	assert.ErrorContains(t, err,
+		"Visibility //team:target is invalid because it must be a package, i.e. //team or //team/...")
+
+	_, err = execPackage(t, "STARFIG", `package(owner = "team")`)
+	assert.ErrorContains(t, err, "package got an unexpected argument owner.")
+}
+
+func TestPackageProviderDynamicVisibility(t *testing.T) {
+	_, err := execPackage(t, "STARFIG", `package(visibility = ["//" + "team"])`)
+	assert.ErrorContains(t, err, "must be a list of string literals.")
+
+	_, err = execPackage(t, "STARFIG", `
+_teams = ["//team"]
+package(visibility = _teams)
+`)
+	assert.ErrorContains(t, err, "must be a list of string literals.")
+
+	_, err = execPackage(t, "STARFIG", `
+def declare():
+    package(visibility = ["//team"])
+
+declare()
+`)
+	assert.ErrorContains(t, err, "package must be declared once by a top level statement in")
+
+	_, err = execPackage(t, "STARFIG", `
+package(visibility = ["//team"])
+package(visibility = ["//public"])
+`)
+	assert.ErrorContains(t, err, "package must be declared once by a top level statement in")
+}
+
+func TestPackageVisibility(t *testing.T) {
+	starverseDir := t.TempDir()
+	writeStarfig(t, starverseDir, "public", `load(":a.star", "A")`)
+	writeStarfig(t, starverseDir, "private", "package(visibility = [\"//team/...\", \"//\"])\n")
+	writeStarfig(t, starverseDir, "dynamic", "package(visibility = [\"//\" + \"team\"])\n")
+
+	visibility, err := packageVisibility(starverseDir, "missing")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//..."}, visibility)
+
+	visibility, err = packageVisibility(starverseDir, "public")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//..."}, visibility)
+
+	visibility, err = packageVisibility(starverseDir, "private")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//team/...", "//"}, visibility)
+
+	_, err = packageVisibility(starverseDir, "dynamic")
+	assert.ErrorContains(t, err, "must be a list of string literals.")
+}
+
+func TestIsVisible(t *testing.T) {
+	visibility := []string{"//team/a/...", "//public"}
+
+	assert.True(t, isVisible(visibility, "team/a"))
+	assert.True(t, isVisible(visibility, "team/a/b"))
+	assert.True(t, isVisible(visibility, "public"))
+	assert.False(t, isVisible(visibility, "team/ab"))
+	assert.False(t, isVisible(visibility, "public/a"))
+	assert.False(t, isVisible(visibility, "."))
+	assert.True(t, isVisible([]string{"//"}, "."))
+	assert.True(t, isVisible([]string{"//..."}, "anything"))
+	assert.False(t, isVisible([]string{}, "team/a"))
+}
+
+func TestCheckVisibilityOtherUniverse(t *testing.T) {
+	starverseDir := t.TempDir()
+	writeStarfig(t, starverseDir, "private", "package(visibility = [\"//team/...\"])\n")
+	writeStarfig(t, starverseDir, "public", "package(visibility = [\"//...\"])\n")
+
+	private := target.FileTarget{
+		StarverseDir: starverseDir, Package: "private", Filename: "a.star", Repository: "platform"}
+	err := checkVisibility(private, "", "team")
+	assert.ErrorContains(t, err,
+		"Package //team cannot load @platform//private/a.star because package @platform//private is only visible to [//team/...].")
+
+	public := target.FileTarget{
+		StarverseDir: starverseDir, Package: "public", Filename: "a.star", Repository: "platform"}
+	assert.Nil(t, checkVisibility(public, "", "team"))
+	assert.Nil(t, checkVisibility(private, "platform", "private"))
+}
+
+// MARK: - Helpers
+
+func execPackage(t *testing.T, filename string, src string) (starlark.StringDict, error) {
+	path := filepath.Join(t.TempDir(), filename)
+	manager := NewSchemaContextManager()
+	file, err := syntax.Parse(path, src, 0)
+	assert.Nil(t, err)
+	manager.RegisterFile(path, file)
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	return starlark.ExecFile(&thread, path, src, Predeclared)
+}
+
+func writeStarfig(t *testing.T, starverseDir string, pkg string, src string) {
+	dir := filepath.Join(starverseDir, pkg)
+	assert.Nil(t, os.MkdirAll(dir, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, target.StarfigFilename), []byte(src), 0644))
+}
@@ -0,0 +1,178 @@
+package native
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jathu/starfig/internal/util"
+	"go.starlark.net/starlark"
+)
+
+var RefResolverThreadKey string = "starfig-ref-resolver"
+
+// A RefResolver evaluates the build target of a reference made by the thread and returns the
+// target of its schema and its evaluated value. The evaluator provides this, since evaluating
+// other build targets is outside the scope of a single file.
+type RefResolver func(thread *starlark.Thread, label string) (string, *starlark.Dict, error)
+
+// MARK: - RefProvider
+
+func RefProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	provider := RefDescriptor{
+		UUID:        uuid.New(),
+		Required:    false,
+		Inline:      false,
+		Validations: []starlark.Callable{},
+	}
+
+	if args.Len() == 0 {
+		return starlark.None, fmt.Errorf("Ref requires a schema type. i.e. Ref(Foo).")
+	} else if args.Len() > 1 {
+		return starlark.None, fmt.Errorf("Ref can only have one type. i.e. Ref(Foo).")
+	}
+
+	schemaBuilderFunction, ok := schemaArgument(args[0])
+	if !ok {
+		return starlark.None, fmt.Errorf("Ref can only be another schema, not %s.", args[0])
+	} else {
+		contextManager := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+		descriptor, found := contextManager.GetDescriptor(schemaBuilderFunction.Name())
+		if !found {
+			return provider, fmt.Errorf("Unable to find schema %s.", schemaBuilderFunction.Name())
+		}
+		provider.WrappedDescriptor = descriptor
+	}
+
+	for kwargName, kwargValue := range util.KwargsToMap(kwargs) {
+		switch kwargName {
+		case "required":
+			requiredValue, ok := kwargValue.(starlark.Bool)
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected required value to be bool, but got %s.", kwargValue)
+			}
+			provider.Required = requiredValue
+		case "inline":
+			inlineValue, ok := kwargValue.(starlark.Bool)
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected inline value to be bool, but got %s.", kwargValue)
+			}
+			provider.Inline = inlineValue
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
+		case "validations":
+			err := extractValidations(&provider.Validations, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
+		default:
+			return starlark.None, fmt.Errorf("Unknown keyword %s in Ref().", kwargName)
+		}
+	}
+
+	if provider.Required && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot be required in Ref().")
+	}
+
+	return provider, nil
+}
+
+// MARK: - RefDescriptor
+
+type RefDescriptor struct {
+	UUID              uuid.UUID
+	WrappedDescriptor Descriptor
+	Required          starlark.Bool
+	Inline            starlark.Bool
+	Validations       []starlark.Callable
+	Computed          starlark.Callable
+	Doc               starlark.String `json:",omitempty"`
+}
+
+func (descriptor RefDescriptor) SKU() string {
+	return fmt.Sprintf("starfig::descriptor:ref:%s", descriptor.UUID)
+}
+
+// An optional reference that isn't set is None, rather than an empty label or an instance that
+// was never built.
+func (descriptor RefDescriptor) Default() starlark.Value {
+	return starlark.None
+}
+
+func (descriptor RefDescriptor) IsRequired() starlark.Bool {
+	return descriptor.Required
+}
+
+func (descriptor RefDescriptor) IsUnique() starlark.Bool {
+	return false
+}
+
+func (descriptor RefDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor RefDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
+func (descriptor RefDescriptor) Evaluate(
+	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
+	label, ok := value.(starlark.String)
+	if !ok {
+		return starlark.None, fmt.Errorf("Expected build target string but got %s.", value)
+	}
+
+	expectedSchemaTarget := descriptor.WrappedDescriptor.SKU()
+
+	resolver, ok := thread.Local(RefResolverThreadKey).(RefResolver)
+	if !ok {
+		return starlark.None, fmt.Errorf("Unable to resolve reference %s.", label)
+	}
+	schemaTarget, evaluated, err := resolver(thread, label.GoString())
+	if err != nil {
+		return starlark.None, err
+	} else if schemaTarget != expectedSchemaTarget {
+		return starlark.None, fmt.Errorf(
+			"Expected %s to be %s but got %s.", label.GoString(), expectedSchemaTarget, schemaTarget)
+	}
+
+	var evaluatedValue starlark.Value = label
+	if descriptor.Inline {
+		evaluatedValue = evaluated
+	}
+
+	args := starlark.Tuple{evaluatedValue}
+	kwargs := []starlark.Tuple{}
+	err = runValidations(thread, args, kwargs, descriptor.Validations)
+
+	return evaluatedValue, err
+}
+
+func (descriptor RefDescriptor) String() string {
+	return jsonify(descriptor)
+}
+
+func (descriptor RefDescriptor) Type() string {
+	return "RefDescriptor"
+}
+
+func (descriptor RefDescriptor) Freeze() {
+	// no-op for now
+}
+
+func (descriptor RefDescriptor) Truth() starlark.Bool {
+	return true
+}
+
+func (descriptor RefDescriptor) Hash() (uint32, error) {
+	return hashify(descriptor)
+}
@@ -0,0 +1,293 @@
+package native
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jathu/starfig/internal/target"
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+// MARK: - RefProvider
+
+func TestRefProvider(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	descriptor := makeSchemaDescriptor("Supreme", new(starlark.Dict))
+	manager.RegisterSchema(descriptor)
+	validations := starlark.NewList([]starlark.Value{
+		tester.MockBuiltin(),
+		tester.MockBuiltin(),
+	})
+	value, err := RefProvider(
+		&thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{tester.MockBuiltinWithName(descriptor.SKU())},
+		[]starlark.Tuple{
+			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("inline"), starlark.Bool(true)},
+			{starlark.String("validations"), validations},
+		},
+	)
+
+	assert.Nil(t, err)
+	provider := value.(RefDescriptor)
+	assert.Equal(t, descriptor.SKU(), provider.WrappedDescriptor.SKU())
+	assert.Equal(t, starlark.Bool(true), provider.IsRequired())
+	assert.Equal(t, starlark.Bool(true), provider.Inline)
+	tester.AssertSameValidations(t, validations, provider.Validations)
+}
+
+func TestRefProviderNoArguments(t *testing.T) {
+	_, err := RefProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "Ref requires a schema type. i.e. Ref(Foo).")
+}
+
+func TestRefProviderTooManyArguments(t *testing.T) {
+	_, err := RefProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{starlark.MakeInt(416), starlark.MakeInt(905)},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "Ref can only have one type. i.e. Ref(Foo).")
+}
+
+func TestRefProviderNonSchemaWrapped(t *testing.T) {
+	_, err := RefProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{starlark.MakeInt(416)},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "Ref can only be another schema, not 416.")
+}
+
+func TestRefProviderUnknownSchema(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	_, err := RefProvider(
+		&thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{tester.MockBuiltinWithName("unknown-builtin")},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "Unable to find schema unknown-builtin.")
+}
+
+func TestRefProviderWithInvalidInlineType(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
+	_, err := RefProvider(
+		&thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{tester.MockBuiltinWithName(descriptor.SKU())},
+		[]starlark.Tuple{
+			{starlark.String("inline"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected inline value to be bool, but got 416.`)
+}
+
+func TestRefProviderWithUnknownKeyword(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
+	_, err := RefProvider(
+		&thread,
+		tester.MockBuiltin(),
+		starlark.Tuple{tester.MockBuiltinWithName(descriptor.SKU())},
+		[]starlark.Tuple{
+			{starlark.String("supreme"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Unknown keyword supreme in Ref().`)
+}
+
+// MARK: - RefDescriptor
+
+func TestRefDescriptorSKU(t *testing.T) {
+	id := uuid.New()
+	descriptor := RefDescriptor{UUID: id}
+
+	assert.Equal(t, fmt.Sprintf("starfig::descriptor:ref:%s", id), descriptor.SKU())
+}
+
+func TestRefDescriptorDefault(t *testing.T) {
+	descriptor := RefDescriptor{WrappedDescriptor: IntDescriptor{
+		DefaultValue: starlark.MakeInt(416),
+	}}
+
+	assert.Equal(t, starlark.None, descriptor.Default())
+}
+
+func TestRefDescriptorDefaultInline(t *testing.T) {
+	descriptor := RefDescriptor{
+		WrappedDescriptor: IntDescriptor{DefaultValue: starlark.MakeInt(416)},
+		Inline:            true,
+	}
+
+	assert.Equal(t, starlark.None, descriptor.Default())
+}
+
+func TestRefDescriptorDefaultInSchema(t *testing.T) {
+	team := makeSchemaDescriptor("Team", starlark.NewDict(0))
+	fields := starlark.NewDict(2)
+	fields.SetKey(starlark.String("team"), RefDescriptor{WrappedDescriptor: team})
+	fields.SetKey(starlark.String("owner"), RefDescriptor{WrappedDescriptor: team, Inline: true})
+	descriptor := makeSchemaDescriptor("Service", fields)
+
+	expected := starlark.NewDict(2)
+	expected.SetKey(starlark.String("team"), starlark.None)
+	expected.SetKey(starlark.String("owner"), starlark.None)
+	assert.Equal(t, expected, descriptor.Default())
+}
+
+func TestRefDescriptorIsRequired(t *testing.T) {
+	descriptor := RefDescriptor{Required: true}
+
+	assert.Equal(t, starlark.Bool(true), descriptor.IsRequired())
+}
+
+func TestRefDescriptorIsUnique(t *testing.T) {
+	descriptor := RefDescriptor{}
+
+	assert.Equal(t, starlark.Bool(false), descriptor.IsUnique())
+}
+
+func TestRefDescriptorEvaluate(t *testing.T) {
+	thread, wrappedDescriptor := makeRefThread("//fruit/fruit.star:Fruit", new(starlark.Dict), nil)
+	descriptor := RefDescriptor{
+		WrappedDescriptor: wrappedDescriptor,
+		Validations: []starlark.Callable{
+			tester.MockBuiltinWithCallback(func(args starlark.Tuple, kwargs []starlark.Tuple) {
+				assert.Equal(t, starlark.Tuple{starlark.String("//fruit:apple")}, args)
+				assert.ElementsMatch(t, []starlark.Tuple{}, kwargs)
+			}),
+		},
+	}
+	value, err := descriptor.Evaluate(thread, starlark.String("//fruit:apple"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, starlark.String("//fruit:apple"), value)
+}
+
+func TestRefDescriptorEvaluateInline(t *testing.T) {
+	evaluated := new(starlark.Dict)
+	evaluated.SetKey(starlark.String("name"), starlark.String("Apple"))
+	thread, wrappedDescriptor := makeRefThread("//fruit/fruit.star:Fruit", evaluated, nil)
+	descriptor := RefDescriptor{WrappedDescriptor: wrappedDescriptor, Inline: true}
+	value, err := descriptor.Evaluate(thread, starlark.String("//fruit:apple"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, evaluated, value)
+}
+
+func TestRefDescriptorEvaluateInvalidType(t *testing.T) {
+	descriptor := RefDescriptor{}
+	_, err := descriptor.Evaluate(&starlark.Thread{}, starlark.MakeInt(416))
+
+	assert.ErrorContains(t, err, `Expected build target string but got 416.`)
+}
+
+func TestRefDescriptorEvaluateResolverError(t *testing.T) {
+	thread, wrappedDescriptor := makeRefThread(
+		"//fruit/fruit.star:Fruit", nil, fmt.Errorf("//fruit:tangerine not found."))
+	descriptor := RefDescriptor{WrappedDescriptor: wrappedDescriptor}
+	_, err := descriptor.Evaluate(thread, starlark.String("//fruit:tangerine"))
+
+	assert.ErrorContains(t, err, "//fruit:tangerine not found.")
+}
+
+func TestRefDescriptorEvaluateIncorrectSchemaType(t *testing.T) {
+	thread, wrappedDescriptor := makeRefThread("//trait/color.star:Color", new(starlark.Dict), nil)
+	descriptor := RefDescriptor{WrappedDescriptor: wrappedDescriptor}
+	_, err := descriptor.Evaluate(thread, starlark.String("//trait:red"))
+
+	assert.ErrorContains(t, err,
+		"Expected //trait:red to be //fruit/fruit.star:Fruit but got //trait/color.star:Color.")
+}
+
+func TestRefDescriptorString(t *testing.T) {
+	id := uuid.New()
+	childId := uuid.New()
+	descriptor := RefDescriptor{
+		UUID:              id,
+		WrappedDescriptor: StringDescriptor{UUID: childId},
+		Inline:            true,
+	}
+	expected := fmt.Sprintf(`{"Type":"RefDescriptor","Descriptor":{"UUID":"%s","WrappedDescriptor":{"UUID":"%s","DefaultValue":"","Required":false,"Unique":false,"Validations":null,"Computed":null},"Required":false,"Inline":true,"Validations":null,"Computed":null}}`, id, childId)
+
+	assert.Equal(t, expected, descriptor.String())
+}
+
+func TestRefDescriptorType(t *testing.T) {
+	descriptor := RefDescriptor{}
+
+	assert.Equal(t, "RefDescriptor", descriptor.Type())
+}
+
+func TestRefDescriptorFreeze(t *testing.T) {
+	descriptor := RefDescriptor{}
+	descriptor.Freeze() // no-op
+}
+
+func TestRefDescriptorTruth(t *testing.T) {
+	descriptor := RefDescriptor{}
+
+	assert.Equal(t, starlark.Bool(true), descriptor.Truth())
+}
+
+func TestRefDescriptorHash(t *testing.T) {
+	hash, err := RefDescriptor{}.Hash()
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1976297941), hash)
+}
+
+// MARK: - Helpers
+
+// Creates a thread with a recognized Fruit schema, where references resolve to the given
+// schema target and value.
+func makeRefThread(
+	resolvedSchemaTarget string,
+	resolvedValue *starlark.Dict,
+	resolvedErr error) (*starlark.Thread, SchemaDescriptor) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	descriptor := SchemaDescriptor{
+		Identity: &SchemaIdentity{
+			File: target.FileTarget{Package: "fruit", Filename: "fruit.star"},
+			Name: "Fruit",
+		},
+		Fields: new(starlark.Dict),
+	}
+	manager.RegisterSchema(descriptor)
+	thread.SetLocal(RefResolverThreadKey, RefResolver(
+		func(thread *starlark.Thread, label string) (string, *starlark.Dict, error) {
+			return resolvedSchemaTarget, resolvedValue, resolvedErr
+		}))
+	return &thread, descriptor
+}
@@ -2,10 +2,15 @@ package native
 
 import (
 	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jathu/starfig/internal/target"
 	"github.com/jathu/starfig/internal/util"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 // MARK: - SchemaResult
@@ -14,16 +19,28 @@ type SchemaResult struct {
 	UUID             uuid.UUID
 	SchemaDescriptor SchemaDescriptor
 	Evaluated        *starlark.Dict
+	// The user supplied arguments, which are used to derive new instances.
+	Arguments *starlark.Dict `json:"-"`
+	// Where the instance was created, and the instance it was derived from.
+	Position syntax.Position `json:"-"`
+	Base     *SchemaResult   `json:"-"`
 }
 
 func (result SchemaResult) Evaluate(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) error {
-	contextManager := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
-	schemaName, found := contextManager.GetSchemaName(result.SchemaDescriptor)
-	if !found {
-		// This should ideally never happen because starlark-go ensures
-		// all the symbols are loaded and we populate the manager during
-		// schema creation and file loading.
-		return fmt.Errorf("Unable to find %s. You might be instantiating a schema in a .star file, which is invalid.", result.SchemaDescriptor.SKU())
+	schemaName := result.SchemaDescriptor.SKU()
+
+	contextManager, _ := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
+	if _, found := contextManager.GetDescriptor(schemaName); !found {
+		return fmt.Errorf("Unable to find %s. You might be instantiating a schema in a .star file, which is invalid.", schemaName)
+	}
+	// Instances are only built by STARFIG files, or by functions they and tests call.
+	if depth, found := toplevelDepth(thread); found {
+		filename := thread.CallFrame(depth).Pos.Filename()
+		if strings.HasSuffix(filename, ".star") && !strings.HasSuffix(filename, target.TestFileSuffix) {
+			return fmt.Errorf(
+				"Unable to instantiate %s in %s. Schema types can only be instantiated in STARFIG files.",
+				schemaName, filepath.Base(filename))
+		}
 	}
 
 	if args.Len() > 0 {
@@ -49,6 +66,9 @@ func (result SchemaResult) Evaluate(thread *starlark.Thread, args starlark.Tuple
 			return fmt.Errorf("Unknown keyword %s in %s.", name, schemaName)
 		}
 		fieldDescriptor := fieldDescriptorValue.(Descriptor)
+		if fieldDescriptor.ComputedFunction() != nil {
+			return fmt.Errorf("Cannot set computed field %s in %s.", name, schemaName)
+		}
 		evaluatedValue, err := fieldDescriptor.Evaluate(thread, value)
 		if err != nil {
 			return fmt.Errorf("Invalid field %s in %s: %s", name, schemaName, err)
@@ -56,6 +76,30 @@ func (result SchemaResult) Evaluate(thread *starlark.Thread, args starlark.Tuple
 		result.Evaluated.SetKey(starlark.String(name), evaluatedValue)
 	}
 
+	// Computed fields run once the user supplied and default values are filled in. They are
+	// computed in the order they are defined, so they can depend on earlier computed fields.
+	for _, tuple := range result.SchemaDescriptor.Fields.Items() {
+		fieldDescriptor := tuple.Index(1).(Descriptor)
+		computed := fieldDescriptor.ComputedFunction()
+		if computed == nil {
+			continue
+		}
+
+		fieldName := tuple.Index(0).(starlark.String)
+		args := starlark.Tuple{result}
+		kwargs := []starlark.Tuple{}
+		value, err := starlark.Call(thread, computed, args, kwargs)
+		if err != nil {
+			return fmt.Errorf(
+				"Unable to compute field %s in %s: %s", fieldName.GoString(), schemaName, err)
+		}
+		evaluatedValue, err := fieldDescriptor.Evaluate(thread, value)
+		if err != nil {
+			return fmt.Errorf("Invalid field %s in %s: %s", fieldName.GoString(), schemaName, err)
+		}
+		result.Evaluated.SetKey(fieldName, evaluatedValue)
+	}
+
 	return nil
 }
 
@@ -63,18 +107,123 @@ func (result SchemaResult) String() string {
 	return jsonify(result)
 }
 
+// The type of an instance is the identity of its schema, so type(x) in Starlark returns
+// i.e. //example/geography/metadata.star:Language
 func (result SchemaResult) Type() string {
-	return "SchemaResult"
+	return result.SchemaDescriptor.SKU()
 }
 
+// Instances are frozen as soon as they are built, so a loaded config cannot be mutated by
+// whichever file loads it.
 func (result SchemaResult) Freeze() {
-	// no-op for now
+	if result.Evaluated != nil {
+		result.Evaluated.Freeze()
+	}
+	if result.Arguments != nil {
+		result.Arguments.Freeze()
+	}
 }
 
 func (result SchemaResult) Truth() starlark.Bool {
 	return true
 }
 
+// Equal instances must have the same hash, so the hash only considers the schema and the
+// evaluated values.
 func (result SchemaResult) Hash() (uint32, error) {
-	return hashify(result)
+	hash := fnv.New32a()
+	hash.Write([]byte(result.SchemaDescriptor.SKU()))
+	if result.Evaluated != nil {
+		hash.Write([]byte(result.Evaluated.String()))
+	}
+	return hash.Sum32(), nil
+}
+
+func (result SchemaResult) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	other := y.(SchemaResult)
+	switch op {
+	case syntax.EQL:
+		return result.equal(other, depth)
+	case syntax.NEQ:
+		equal, err := result.equal(other, depth)
+		return !equal, err
+	default:
+		return false, fmt.Errorf("%s %s %s not implemented", result.Type(), op, other.Type())
+	}
+}
+
+func (result SchemaResult) equal(other SchemaResult, depth int) (bool, error) {
+	if result.SchemaDescriptor.SKU() != other.SchemaDescriptor.SKU() {
+		return false, nil
+	}
+	return starlark.EqualDepth(result.Evaluated, other.Evaluated, depth-1)
+}
+
+// MARK: - Fields
+
+// The methods of a dict an instance also has, so validations and constraints written for the
+// evaluated dict keep working, i.e. fruit.keys(). A field with the same name takes precedence.
+var dictMethods = []string{"get", "items", "keys", "values"}
+
+// Fields can be accessed as attributes, i.e. fruit.color, where nested objects are also
+// instances. Indexing, i.e. fruit["color"], returns the plain evaluated value.
+func (result SchemaResult) Attr(name string) (starlark.Value, error) {
+	value, found, err := result.Evaluated.Get(starlark.String(name))
+	if err != nil || !found {
+		for _, method := range dictMethods {
+			if method == name {
+				return result.Evaluated.Attr(name)
+			}
+		}
+		// starlark-go reports the missing attribute.
+		return nil, nil
+	}
+
+	fieldDescriptorValue, _, _ := result.SchemaDescriptor.Fields.Get(starlark.String(name))
+	objectDescriptor, ok := fieldDescriptorValue.(ObjectDescriptor)
+	if !ok {
+		return value, nil
+	}
+	nestedDescriptor, ok := objectDescriptor.WrappedDescriptor.(SchemaDescriptor)
+	nestedEvaluated, isDict := value.(*starlark.Dict)
+	if !ok || !isDict {
+		return value, nil
+	}
+	return SchemaResult{SchemaDescriptor: nestedDescriptor, Evaluated: nestedEvaluated}, nil
+}
+
+func (result SchemaResult) AttrNames() []string {
+	names := []string{}
+	for _, key := range result.SchemaDescriptor.Fields.Keys() {
+		names = append(names, key.(starlark.String).GoString())
+	}
+	return append(names, dictMethods...)
+}
+
+// An instance is also a read-only mapping of its evaluated fields, so it can be used like a
+// dict, i.e. len(fruit), "color" in fruit or for name in fruit.
+func (result SchemaResult) Get(key starlark.Value) (starlark.Value, bool, error) {
+	return result.Evaluated.Get(key)
+}
+
+func (result SchemaResult) Iterate() starlark.Iterator {
+	return result.Evaluated.Iterate()
+}
+
+func (result SchemaResult) Items() []starlark.Tuple {
+	return result.Evaluated.Items()
+}
+
+func (result SchemaResult) Len() int {
+	return result.Evaluated.Len()
+}
+
+func (result SchemaResult) SetField(name string, value starlark.Value) error {
+	return fmt.Errorf(
+		"Cannot set field %s because schema instances are immutable. Use derive to create a modified copy.", name)
+}
+
+func (result SchemaResult) SetKey(key starlark.Value, value starlark.Value) error {
+	return fmt.Errorf(
+		"Cannot set key %s because schema instances are immutable. Use derive to create a modified copy.", key)
 }
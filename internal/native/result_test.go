@@ -5,10 +5,10 @@ import (
 	"testing"
 
 	"github.com/google/uuid"
-	"github.com/jathu/starfig/internal/target"
 	"github.com/jathu/starfig/internal/tester"
 	"github.com/stretchr/testify/assert"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 // MARK: - SchemaResult
@@ -19,13 +19,8 @@ func TestSchemaResultEvaluate(t *testing.T) {
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 	fields := new(starlark.Dict)
 	fields.SetKey(starlark.String("ovo"), StringDescriptor{Required: true})
-	descriptor := SchemaDescriptor{UUID: uuid.New(), Fields: fields}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
 	schemaResult := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: descriptor,
@@ -45,33 +40,35 @@ func TestSchemaResultEvaluate(t *testing.T) {
 	assert.Equal(t, expected, schemaResult.Evaluated)
 }
 
-func TestSchemaResultEvaluateUnknownSchemaName(t *testing.T) {
+func TestSchemaResultEvaluateWithArguments(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 	schemaResult := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: descriptor,
 		Evaluated:        new(starlark.Dict),
 	}
-	err := schemaResult.Evaluate(&thread, starlark.Tuple{}, []starlark.Tuple{})
+	err := schemaResult.Evaluate(
+		&thread,
+		starlark.Tuple{starlark.MakeInt(416)},
+		[]starlark.Tuple{},
+	)
 
-	expected := fmt.Sprintf("Unable to find %s. You might be instantiating a schema in a .star file, which is invalid.", descriptor.SKU())
+	expected := fmt.Sprintf("Invalid positional arguments (416,) in //example/defs.star:Supreme.")
 	assert.ErrorContains(t, err, expected)
 }
 
-func TestSchemaResultEvaluateWithArguments(t *testing.T) {
+func TestSchemaResultEvaluateMissingRequiredField(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("ovo"), StringDescriptor{Required: true})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
 	schemaResult := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: descriptor,
@@ -79,27 +76,20 @@ func TestSchemaResultEvaluateWithArguments(t *testing.T) {
 	}
 	err := schemaResult.Evaluate(
 		&thread,
-		starlark.Tuple{starlark.MakeInt(416)},
+		starlark.Tuple{},
 		[]starlark.Tuple{},
 	)
 
-	expected := fmt.Sprintf("Invalid positional arguments (416,) in Supreme.")
-	assert.ErrorContains(t, err, expected)
+	assert.ErrorContains(t, err, "Missing required field ovo in //example/defs.star:Supreme.")
 }
 
-func TestSchemaResultEvaluateMissingRequiredField(t *testing.T) {
+func TestSchemaResultEvaluateUnknownKeyword(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 	fields := new(starlark.Dict)
-	fields.SetKey(starlark.String("ovo"), StringDescriptor{Required: true})
-	descriptor := SchemaDescriptor{UUID: uuid.New(), Fields: fields}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
 	schemaResult := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: descriptor,
@@ -108,24 +98,23 @@ func TestSchemaResultEvaluateMissingRequiredField(t *testing.T) {
 	err := schemaResult.Evaluate(
 		&thread,
 		starlark.Tuple{},
-		[]starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("mock"), starlark.MakeInt(416)},
+		},
 	)
 
-	assert.ErrorContains(t, err, "Missing required field ovo in Supreme.")
+	expected := fmt.Sprintf("Unknown keyword mock in //example/defs.star:Supreme.")
+	assert.ErrorContains(t, err, expected)
 }
 
-func TestSchemaResultEvaluateUnknownKeyword(t *testing.T) {
+func TestSchemaResultEvaluateInvalidField(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 	fields := new(starlark.Dict)
-	descriptor := SchemaDescriptor{UUID: uuid.New(), Fields: fields}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	fields.SetKey(starlark.String("ovo"), StringDescriptor{Required: true})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
 	schemaResult := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: descriptor,
@@ -135,27 +124,58 @@ func TestSchemaResultEvaluateUnknownKeyword(t *testing.T) {
 		&thread,
 		starlark.Tuple{},
 		[]starlark.Tuple{
-			{starlark.String("mock"), starlark.MakeInt(416)},
+			{starlark.String("ovo"), starlark.MakeInt(416)},
 		},
 	)
 
-	expected := fmt.Sprintf("Unknown keyword mock in Supreme.")
+	expected := fmt.Sprintf("Invalid field ovo in //example/defs.star:Supreme: Expected string type but got 416.")
 	assert.ErrorContains(t, err, expected)
 }
 
-func TestSchemaResultEvaluateInvalidField(t *testing.T) {
+func TestSchemaResultEvaluateComputedField(t *testing.T) {
 	manager := NewSchemaContextManager()
 	thread := starlark.Thread{}
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 	fields := new(starlark.Dict)
-	fields.SetKey(starlark.String("ovo"), StringDescriptor{Required: true})
-	descriptor := SchemaDescriptor{UUID: uuid.New(), Fields: fields}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
+	fields.SetKey(starlark.String("name"), StringDescriptor{Required: true})
+	fields.SetKey(starlark.String("domain"), StringDescriptor{DefaultValue: "starfig.dev"})
+	fields.SetKey(starlark.String("fqdn"), StringDescriptor{
+		Computed: starlark.NewBuiltin("fqdn", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			instance := args.Index(0).(SchemaResult)
+			name, _ := instance.Attr("name")
+			domain, _, _ := instance.Get(starlark.String("domain"))
+			return starlark.String(fmt.Sprintf("%s.%s",
+				name.(starlark.String).GoString(), domain.(starlark.String).GoString())), nil
+		}),
+	})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
+	schemaResult := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: descriptor,
+		Evaluated:        descriptor.Default().(*starlark.Dict),
+	}
+	err := schemaResult.Evaluate(
+		&thread,
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("name"), starlark.String("api")},
+		},
 	)
+
+	assert.Nil(t, err)
+	fqdn, _, _ := schemaResult.Evaluated.Get(starlark.String("fqdn"))
+	assert.Equal(t, starlark.String("api.starfig.dev"), fqdn)
+}
+
+func TestSchemaResultEvaluateSetComputedField(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("fqdn"), StringDescriptor{Computed: tester.MockBuiltin()})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
 	schemaResult := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: descriptor,
@@ -165,36 +185,78 @@ func TestSchemaResultEvaluateInvalidField(t *testing.T) {
 		&thread,
 		starlark.Tuple{},
 		[]starlark.Tuple{
-			{starlark.String("ovo"), starlark.MakeInt(416)},
+			{starlark.String("fqdn"), starlark.String("api.starfig.dev")},
 		},
 	)
 
-	expected := fmt.Sprintf("Invalid field ovo in Supreme: Expected string type but got 416.")
-	assert.ErrorContains(t, err, expected)
+	assert.ErrorContains(t, err, "Cannot set computed field fqdn in //example/defs.star:Supreme.")
+}
+
+func TestSchemaResultEvaluateInvalidComputedField(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	fields := new(starlark.Dict)
+	// The mock returns None, which is not a string.
+	fields.SetKey(starlark.String("fqdn"), StringDescriptor{Computed: tester.MockBuiltin()})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
+	schemaResult := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: descriptor,
+		Evaluated:        new(starlark.Dict),
+	}
+	err := schemaResult.Evaluate(&thread, starlark.Tuple{}, []starlark.Tuple{})
+
+	assert.ErrorContains(t, err, "Invalid field fqdn in //example/defs.star:Supreme: Expected string type but got None.")
+}
+
+func TestSchemaResultEvaluateComputedFieldError(t *testing.T) {
+	manager := NewSchemaContextManager()
+	thread := starlark.Thread{}
+	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("fqdn"), StringDescriptor{
+		Computed: tester.MockFailingBuiltin("yikes!"),
+	})
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	manager.RegisterSchema(descriptor)
+	schemaResult := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: descriptor,
+		Evaluated:        new(starlark.Dict),
+	}
+	err := schemaResult.Evaluate(&thread, starlark.Tuple{}, []starlark.Tuple{})
+
+	assert.ErrorContains(t, err, "Unable to compute field fqdn in //example/defs.star:Supreme: yikes!")
 }
 
 func TestSchemaResultString(t *testing.T) {
 	id := uuid.New()
-	childId := uuid.New()
 	descriptor := SchemaResult{
 		UUID:             id,
-		SchemaDescriptor: SchemaDescriptor{UUID: childId},
+		SchemaDescriptor: makeSchemaDescriptor("Supreme", nil),
 		Evaluated:        new(starlark.Dict),
 	}
-	expected := fmt.Sprintf(`{"Type":"SchemaResult","Descriptor":{"UUID":"%s","SchemaDescriptor":{"UUID":"%s","Fields":null,"Validations":null},"Evaluated":{}}}`, id, childId)
+	expected := fmt.Sprintf(`{"Type":"//example/defs.star:Supreme","Descriptor":{"UUID":"%s","SchemaDescriptor":{"Identity":"//example/defs.star:Supreme","Fields":null,"Validations":null,"Constraints":null},"Evaluated":{}}}`, id)
 
 	assert.Equal(t, expected, descriptor.String())
 }
 
 func TestSchemaResultType(t *testing.T) {
-	descriptor := SchemaResult{}
+	descriptor := SchemaResult{SchemaDescriptor: makeSchemaDescriptor("Supreme", nil)}
 
-	assert.Equal(t, "SchemaResult", descriptor.Type())
+	assert.Equal(t, "//example/defs.star:Supreme", descriptor.Type())
 }
 
 func TestSchemaResultFreeze(t *testing.T) {
-	descriptor := SchemaResult{}
-	descriptor.Freeze() // no-op
+	descriptor := SchemaResult{Evaluated: new(starlark.Dict), Arguments: new(starlark.Dict)}
+	descriptor.Freeze()
+
+	assert.ErrorContains(t,
+		descriptor.Evaluated.SetKey(starlark.String("ovo"), starlark.None), "frozen")
+	assert.ErrorContains(t,
+		descriptor.Arguments.SetKey(starlark.String("ovo"), starlark.None), "frozen")
 }
 
 func TestSchemaResultTruth(t *testing.T) {
@@ -207,5 +269,142 @@ func TestSchemaResultHash(t *testing.T) {
 	hash, err := SchemaResult{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(2090021955), hash)
+	assert.Equal(t, uint32(0x811c9dc5), hash)
+}
+
+func TestSchemaResultHashEqualInstances(t *testing.T) {
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	first := SchemaResult{UUID: uuid.New(), SchemaDescriptor: descriptor, Evaluated: makeEvaluated("yeezy")}
+	second := SchemaResult{UUID: uuid.New(), SchemaDescriptor: descriptor, Evaluated: makeEvaluated("yeezy")}
+	firstHash, err := first.Hash()
+	assert.Nil(t, err)
+	secondHash, err := second.Hash()
+	assert.Nil(t, err)
+
+	assert.Equal(t, firstHash, secondHash)
+}
+
+func TestSchemaResultCompareSameType(t *testing.T) {
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	first := SchemaResult{UUID: uuid.New(), SchemaDescriptor: descriptor, Evaluated: makeEvaluated("yeezy")}
+	second := SchemaResult{UUID: uuid.New(), SchemaDescriptor: descriptor, Evaluated: makeEvaluated("yeezy")}
+	third := SchemaResult{UUID: uuid.New(), SchemaDescriptor: descriptor, Evaluated: makeEvaluated("drizzy")}
+	other := SchemaResult{UUID: uuid.New(), SchemaDescriptor: makeSchemaDescriptor("Patagonia", nil), Evaluated: makeEvaluated("yeezy")}
+
+	equal, err := starlark.Equal(first, second)
+	assert.Nil(t, err)
+	assert.True(t, equal)
+
+	equal, err = starlark.Equal(first, third)
+	assert.Nil(t, err)
+	assert.False(t, equal)
+
+	equal, err = starlark.Equal(first, other)
+	assert.Nil(t, err)
+	assert.False(t, equal)
+
+	notEqual, err := starlark.Compare(syntax.NEQ, first, third)
+	assert.Nil(t, err)
+	assert.True(t, notEqual)
+
+	_, err = starlark.Compare(syntax.LT, first, second)
+	assert.ErrorContains(t, err, "//example/defs.star:Supreme < //example/defs.star:Supreme not implemented")
+}
+
+func TestSchemaResultAttr(t *testing.T) {
+	nestedDescriptor := makeSchemaDescriptor("Color", nil)
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("ovo"), StringDescriptor{})
+	fields.SetKey(starlark.String("nested"), ObjectDescriptor{WrappedDescriptor: nestedDescriptor})
+	nestedEvaluated := makeEvaluated("drizzy")
+	evaluated := makeEvaluated("yeezy")
+	evaluated.SetKey(starlark.String("nested"), nestedEvaluated)
+	result := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: makeSchemaDescriptor("Fruit", fields),
+		Evaluated:        evaluated,
+	}
+
+	value, err := result.Attr("ovo")
+	assert.Nil(t, err)
+	assert.Equal(t, starlark.String("yeezy"), value)
+
+	value, err = result.Attr("nested")
+	assert.Nil(t, err)
+	assert.Equal(t, SchemaResult{SchemaDescriptor: nestedDescriptor, Evaluated: nestedEvaluated}, value)
+
+	value, err = result.Attr("unknown")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	assert.Equal(t, []string{"ovo", "nested", "get", "items", "keys", "values"}, result.AttrNames())
+}
+
+func TestSchemaResultMapping(t *testing.T) {
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("ovo"), StringDescriptor{})
+	fields.SetKey(starlark.String("keys"), ListDescriptor{})
+	evaluated := makeEvaluated("yeezy")
+	evaluated.SetKey(starlark.String("keys"), starlark.NewList([]starlark.Value{}))
+	result := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: makeSchemaDescriptor("Fruit", fields),
+		Evaluated:        evaluated,
+	}
+	result.Freeze()
+
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "mapping.star", `
+length = len(fruit)
+names = [name for name in fruit]
+items = fruit.items()
+values = fruit.values()
+found = fruit.get("ovo")
+missing = fruit.get("unknown", "default")
+contains = "ovo" in fruit
+field = fruit.keys
+`, starlark.StringDict{"fruit": result})
+	assert.Nil(t, err)
+	assert.Equal(t, starlark.MakeInt(2), globals["length"])
+	assert.Equal(t, `["ovo", "keys"]`, globals["names"].String())
+	assert.Equal(t, `[("ovo", "yeezy"), ("keys", [])]`, globals["items"].String())
+	assert.Equal(t, `["yeezy", []]`, globals["values"].String())
+	assert.Equal(t, starlark.String("yeezy"), globals["found"])
+	assert.Equal(t, starlark.String("default"), globals["missing"])
+	assert.Equal(t, starlark.True, globals["contains"])
+	// A field named like a dict method takes precedence.
+	assert.Equal(t, "[]", globals["field"].String())
+	assert.Equal(t, evaluated.Items(), result.Items())
+}
+
+func TestSchemaResultGet(t *testing.T) {
+	result := SchemaResult{UUID: uuid.New(), Evaluated: makeEvaluated("yeezy")}
+	value, found, err := result.Get(starlark.String("ovo"))
+
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, starlark.String("yeezy"), value)
+}
+
+func TestSchemaResultSetField(t *testing.T) {
+	result := SchemaResult{UUID: uuid.New(), Evaluated: makeEvaluated("yeezy")}
+	err := result.SetField("ovo", starlark.String("drizzy"))
+
+	assert.ErrorContains(t, err,
+		"Cannot set field ovo because schema instances are immutable. Use derive to create a modified copy.")
+}
+
+func TestSchemaResultSetKey(t *testing.T) {
+	result := SchemaResult{UUID: uuid.New(), Evaluated: makeEvaluated("yeezy")}
+	err := result.SetKey(starlark.String("ovo"), starlark.String("drizzy"))
+
+	assert.ErrorContains(t, err,
+		"Cannot set key \"ovo\" because schema instances are immutable. Use derive to create a modified copy.")
+}
+
+// MARK: - Helpers
+
+func makeEvaluated(ovo string) *starlark.Dict {
+	evaluated := new(starlark.Dict)
+	evaluated.SetKey(starlark.String("ovo"), starlark.String(ovo))
+	return evaluated
 }
@@ -6,15 +6,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/jathu/starfig/internal/util"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 // MARK: - SchemaProvider
 
 func SchemaProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	provider := SchemaDescriptor{
-		UUID:        uuid.New(),
+		Identity:    schemaIdentity(thread),
+		origin:      callerPosition(thread),
 		Fields:      new(starlark.Dict),
 		Validations: []starlark.Callable{},
+		Constraints: []starlark.Callable{},
 	}
 
 	if args.Len() > 0 {
@@ -44,41 +47,116 @@ func SchemaProvider(thread *starlark.Thread, builtin *starlark.Builtin, args sta
 			if err != nil {
 				return starlark.None, err
 			}
+		case "constraints":
+			err := extractConstraints(&provider.Constraints, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in Schema().", kwargName)
 		}
 	}
 
 	contextManager := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
-	contextManager.QueueSeenDescriptor(provider)
+	err := contextManager.RegisterSchema(provider)
+	if err != nil {
+		return starlark.None, err
+	}
+	contextManager.pending[provider.Identity] = true
 
 	return createSchemaBuilder(provider)
 }
 
-func createSchemaBuilder(descriptor SchemaDescriptor) (*starlark.Builtin, error) {
-	builder := func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-		result := SchemaResult{
-			UUID:             uuid.New(),
-			SchemaDescriptor: descriptor,
-			Evaluated:        descriptor.Default().(*starlark.Dict),
-		}
-		err := result.Evaluate(thread, args, kwargs)
+func createSchemaBuilder(descriptor SchemaDescriptor) (*SchemaBuilder, error) {
+	return &SchemaBuilder{Descriptor: descriptor}, nil
+}
+
+// MARK: - SchemaBuilder
+
+// SchemaBuilder instantiates a schema, i.e. Fruit(name = "apple"). It is named by the identity
+// of the schema, which is only final once the module creating the schema has run.
+type SchemaBuilder struct {
+	Descriptor SchemaDescriptor
+}
+
+func (builder *SchemaBuilder) Name() string {
+	return builder.Descriptor.SKU()
+}
+
+func (builder *SchemaBuilder) String() string {
+	return fmt.Sprintf("<built-in function %s>", builder.Name())
+}
+
+func (builder *SchemaBuilder) Type() string {
+	return "builtin_function_or_method"
+}
+
+func (builder *SchemaBuilder) Freeze() {}
+
+func (builder *SchemaBuilder) Truth() starlark.Bool {
+	return true
+}
+
+// The name of a schema can change once its module has run, so the hash is its origin.
+func (builder *SchemaBuilder) Hash() (uint32, error) {
+	return starlark.String(builder.Descriptor.origin.String()).Hash()
+}
+
+func (builder *SchemaBuilder) CallInternal(
+	thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	descriptor := builder.Descriptor
+	arguments := new(starlark.Dict)
+	for _, kwarg := range kwargs {
+		arguments.SetKey(kwarg.Index(0), kwarg.Index(1))
+	}
+	result := SchemaResult{
+		UUID:             uuid.New(),
+		SchemaDescriptor: descriptor,
+		Evaluated:        descriptor.Default().(*starlark.Dict),
+		Arguments:        arguments,
+		Position:         callerPosition(thread),
+	}
+	err := result.Evaluate(thread, args, kwargs)
+	if err != nil {
 		return result, err
 	}
+	result.Freeze()
+	return result, nil
+}
 
-	return starlark.NewBuiltin(descriptor.SKU(), builder), nil
+// A schema passed as an argument, i.e. Object(Fruit), which is resolved by its name.
+func schemaArgument(value starlark.Value) (starlark.Callable, bool) {
+	switch value.(type) {
+	case *SchemaBuilder, *starlark.Builtin:
+		return value.(starlark.Callable), true
+	}
+	return nil, false
 }
 
 // MARK: - SchemaDescriptor
 
 type SchemaDescriptor struct {
-	UUID        uuid.UUID
+	// Shared by every copy of the descriptor, since it is resolved once the module has run.
+	Identity    *SchemaIdentity
 	Fields      *starlark.Dict
 	Validations []starlark.Callable
+	Constraints []starlark.Callable
+	Doc         starlark.String `json:",omitempty"`
+	// The Schema() call that created the schema.
+	origin syntax.Position
 }
 
+// The SKU of a schema is its identity, i.e. //example/geography/metadata.star:Language
 func (descriptor SchemaDescriptor) SKU() string {
-	return fmt.Sprintf("starfig::descriptor:schema:%s", descriptor.UUID)
+	if descriptor.Identity == nil {
+		return ""
+	}
+	return descriptor.Identity.Target()
 }
 
 func (descriptor SchemaDescriptor) Default() starlark.Value {
@@ -95,37 +173,94 @@ func (descriptor SchemaDescriptor) IsRequired() starlark.Bool {
 	return false
 }
 
+func (descriptor SchemaDescriptor) IsUnique() starlark.Bool {
+	return false
+}
+
+func (descriptor SchemaDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor SchemaDescriptor) ComputedFunction() starlark.Callable {
+	return nil
+}
+
 func (descriptor SchemaDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
-	contextManager := thread.Local(SchemaContextManagerThreadKey).(SchemaContextManager)
-	expectedSchemaName, ok := contextManager.GetSchemaName(descriptor)
-	if !ok {
-		return starlark.None, fmt.Errorf(
-			"Unable to find %s in schema evaluation.", descriptor.SKU())
-	}
-
 	providedValue, ok := value.(SchemaResult)
 	if !ok {
-		return starlark.None, fmt.Errorf("Expected %s type but got %s.", expectedSchemaName, value)
+		return starlark.None, fmt.Errorf("Expected %s type but got %s.", descriptor.SKU(), value)
 	}
 
-	if !contextManager.EqualDescriptor(descriptor, providedValue.SchemaDescriptor) {
-		providedSchemaName, ok := contextManager.GetSchemaName(providedValue.SchemaDescriptor)
-		if !ok {
-			return starlark.None, fmt.Errorf(
-				"Unable to find %s in schema evaluation.", providedValue.SchemaDescriptor.SKU())
-		}
+	// Loading a module again creates its schemas again, so schemas are the same when they
+	// have the same identity.
+	if descriptor.Identity != providedValue.SchemaDescriptor.Identity &&
+		descriptor.SKU() != providedValue.SchemaDescriptor.SKU() {
 		return starlark.None, fmt.Errorf(
-			"Expected %s but got %s.", expectedSchemaName, providedSchemaName)
+			"Expected %s but got %s.", descriptor.SKU(), providedValue.SchemaDescriptor.SKU())
 	}
 
-	args := starlark.Tuple{providedValue.Evaluated}
+	args := starlark.Tuple{providedValue}
 	kwargs := []starlark.Tuple{}
 	err := runValidations(thread, args, kwargs, descriptor.Validations)
 
 	return providedValue.Evaluated, err
 }
 
+// HasConstraints reports if the schema has constraints or unique fields, which span every
+// instance of the schema.
+func (descriptor SchemaDescriptor) HasConstraints() bool {
+	if len(descriptor.Constraints) > 0 {
+		return true
+	}
+	for _, tuple := range descriptor.Fields.Items() {
+		if tuple.Index(1).(Descriptor).IsUnique() {
+			return true
+		}
+	}
+	return false
+}
+
+// Constraints are invariants that span every instance of a schema in the universe, unlike
+// validations which only see a single instance. The instances are keyed by their build target.
+func (descriptor SchemaDescriptor) EvaluateConstraints(
+	thread *starlark.Thread, instances *starlark.Dict) error {
+	for _, tuple := range descriptor.Fields.Items() {
+		fieldName := tuple.Index(0).(starlark.String)
+		fieldDescriptor := tuple.Index(1).(Descriptor)
+		if !fieldDescriptor.IsUnique() {
+			continue
+		}
+
+		seen := new(starlark.Dict)
+		for _, instance := range instances.Items() {
+			instanceTarget := instance.Index(0).(starlark.String)
+			instanceValue, ok := instance.Index(1).(SchemaResult)
+			if !ok {
+				return fmt.Errorf("Expected %s to be a schema instance.", instanceTarget.GoString())
+			}
+			fieldValue, found, err := instanceValue.Evaluated.Get(fieldName)
+			if err != nil || !found {
+				continue
+			}
+			seenTarget, found, err := seen.Get(fieldValue)
+			if err != nil {
+				return err
+			} else if found {
+				return fmt.Errorf(
+					"Duplicate value %s for unique field %s in %s and %s.",
+					fieldValue, fieldName.GoString(),
+					seenTarget.(starlark.String).GoString(), instanceTarget.GoString())
+			}
+			seen.SetKey(fieldValue, instanceTarget)
+		}
+	}
+
+	args := starlark.Tuple{instances}
+	kwargs := []starlark.Tuple{}
+	return runValidations(thread, args, kwargs, descriptor.Constraints)
+}
+
 func (descriptor SchemaDescriptor) String() string {
 	return jsonify(descriptor)
 }
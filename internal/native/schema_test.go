@@ -1,11 +1,9 @@
 package native
 
 import (
-	"fmt"
 	"testing"
 
 	"github.com/google/uuid"
-	"github.com/jathu/starfig/internal/target"
 	"github.com/jathu/starfig/internal/tester"
 	"github.com/stretchr/testify/assert"
 	"go.starlark.net/starlark"
@@ -27,6 +25,9 @@ func TestSchemaProvider(t *testing.T) {
 		tester.MockBuiltin(),
 		tester.MockBuiltin(),
 	})
+	constraints := starlark.NewList([]starlark.Value{
+		tester.MockBuiltin(),
+	})
 	providerResult, err := SchemaProvider(
 		&thread,
 		tester.MockBuiltin(),
@@ -34,15 +35,19 @@ func TestSchemaProvider(t *testing.T) {
 		[]starlark.Tuple{
 			{starlark.String("fields"), fields},
 			{starlark.String("validations"), validations},
+			{starlark.String("constraints"), constraints},
+			{starlark.String("doc"), starlark.String("A fruit.")},
 		},
 	)
 
 	assert.Nil(t, err)
-	builder := providerResult.(*starlark.Builtin)
-	queuedDescriptor := manager.queue[maps.Keys(manager.queue)[0]]
-	assert.Equal(t, queuedDescriptor.SKU(), builder.Name())
-	assert.Equal(t, fields, queuedDescriptor.Fields)
-	tester.AssertSameValidations(t, validations, queuedDescriptor.Validations)
+	builder := providerResult.(*SchemaBuilder)
+	registeredDescriptor := manager.schemas[maps.Keys(manager.schemas)[0]]
+	assert.Equal(t, registeredDescriptor.SKU(), builder.Name())
+	assert.Equal(t, fields, registeredDescriptor.Fields)
+	assert.Equal(t, starlark.String("A fruit."), registeredDescriptor.Doc)
+	tester.AssertSameValidations(t, validations, registeredDescriptor.Validations)
+	tester.AssertSameValidations(t, constraints, registeredDescriptor.Constraints)
 }
 
 func TestSchemaProviderWithArguments(t *testing.T) {
@@ -96,6 +101,20 @@ func TestSchemaProviderWithInvalidValidationsElementType(t *testing.T) {
 	assert.ErrorContains(t, err, `Expected validation to be a functions, but got 416.`)
 }
 
+func TestSchemaProviderWithInvalidConstraintsType(t *testing.T) {
+	_, err := SchemaProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("constraints"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err,
+		`Expected constraints value to be a list of functions, but got 416.`)
+}
+
 func TestSchemaProviderWithUnknownKeyword(t *testing.T) {
 	_, err := SchemaProvider(
 		&starlark.Thread{},
@@ -127,7 +146,7 @@ func TestSchemaProviderWithInvalidFieldsValueType(t *testing.T) {
 // MARK: - createSchemaBuilder
 
 func TestCreateSchemaBuilder(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
 	result, err := createSchemaBuilder(descriptor)
 
 	assert.Nil(t, err)
@@ -137,10 +156,9 @@ func TestCreateSchemaBuilder(t *testing.T) {
 // MARK: - SchemaDescriptor
 
 func TestSchemaDescriptorSKU(t *testing.T) {
-	id := uuid.New()
-	descriptor := SchemaDescriptor{UUID: id}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
 
-	assert.Equal(t, fmt.Sprintf("starfig::descriptor:schema:%s", id), descriptor.SKU())
+	assert.Equal(t, "//example/defs.star:Supreme", descriptor.SKU())
 }
 
 func TestSchemaDescriptorDefault(t *testing.T) {
@@ -156,11 +174,17 @@ func TestSchemaDescriptorDefault(t *testing.T) {
 }
 
 func TestSchemaDescriptorIsRequired(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
 
 	assert.Equal(t, starlark.Bool(false), descriptor.IsRequired())
 }
 
+func TestSchemaDescriptorIsUnique(t *testing.T) {
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+
+	assert.Equal(t, starlark.Bool(false), descriptor.IsUnique())
+}
+
 func TestSchmeaDescriptorEvaluate(t *testing.T) {
 	thread := starlark.Thread{}
 
@@ -168,22 +192,17 @@ func TestSchmeaDescriptorEvaluate(t *testing.T) {
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 
 	expectedEvaluated := new(starlark.Dict)
-
-	descriptor := SchemaDescriptor{
-		UUID: uuid.New(),
-		Validations: []starlark.Callable{
-			tester.MockBuiltinWithCallback(func(args starlark.Tuple, kwargs []starlark.Tuple) {
-				assert.Equal(t, starlark.Tuple{expectedEvaluated}, args)
-				assert.ElementsMatch(t, []starlark.Tuple{}, kwargs)
-			}),
-		},
+	expectedEvaluated.SetKey(starlark.String("ovo"), starlark.String("yeezy"))
+
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	descriptor.Validations = []starlark.Callable{
+		tester.MockBuiltinWithCallback(func(args starlark.Tuple, kwargs []starlark.Tuple) {
+			assert.Equal(t, 1, args.Len())
+			assert.Equal(t, expectedEvaluated.Items(), args.Index(0).(starlark.IterableMapping).Items())
+			assert.ElementsMatch(t, []starlark.Tuple{}, kwargs)
+		}),
 	}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	manager.RegisterSchema(descriptor)
 
 	userValue := SchemaResult{
 		UUID:             uuid.New(),
@@ -195,52 +214,31 @@ func TestSchmeaDescriptorEvaluate(t *testing.T) {
 	assert.Equal(t, expectedEvaluated, result)
 }
 
-func TestSchmeaDescriptorEvaluateUnknownSchema(t *testing.T) {
-	thread := starlark.Thread{}
-
-	manager := NewSchemaContextManager()
-	thread.SetLocal(SchemaContextManagerThreadKey, manager)
-
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	_, err := descriptor.Evaluate(&thread, starlark.None)
-
-	expected := fmt.Sprintf("Unable to find %s in schema evaluation.", descriptor.SKU())
-	assert.ErrorContains(t, err, expected)
-}
-
 func TestSchmeaDescriptorEvaluateIncorrectPrimitiveType(t *testing.T) {
 	thread := starlark.Thread{}
 
 	manager := NewSchemaContextManager()
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(descriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(descriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	descriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(descriptor)
 	_, err := descriptor.Evaluate(&thread, starlark.MakeInt(416))
 
-	assert.ErrorContains(t, err, "Expected Supreme type but got 416.")
+	assert.ErrorContains(t, err, "Expected //example/defs.star:Supreme type but got 416.")
 }
 
-func TestSchmeaDescriptorEvaluateUnknownSchemaName(t *testing.T) {
+func TestSchmeaDescriptorEvaluateIncorrectSchemaType(t *testing.T) {
 	thread := starlark.Thread{}
 
 	manager := NewSchemaContextManager()
 	thread.SetLocal(SchemaContextManagerThreadKey, manager)
 
-	wantedDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(wantedDescriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(wantedDescriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	wantedDescriptor := makeSchemaDescriptor("Supreme", nil)
+	manager.RegisterSchema(wantedDescriptor)
+
+	otherDescriptor := makeSchemaDescriptor("Patagonia", nil)
+	manager.RegisterSchema(otherDescriptor)
 
-	otherDescriptor := SchemaDescriptor{UUID: uuid.New()}
 	userValue := SchemaResult{
 		UUID:             uuid.New(),
 		SchemaDescriptor: otherDescriptor,
@@ -248,70 +246,85 @@ func TestSchmeaDescriptorEvaluateUnknownSchemaName(t *testing.T) {
 	}
 	_, err := wantedDescriptor.Evaluate(&thread, userValue)
 
-	expected := fmt.Sprintf("Unable to find %s in schema evaluation.", otherDescriptor.SKU())
-	assert.ErrorContains(t, err, expected)
+	assert.ErrorContains(t, err, "Expected //example/defs.star:Supreme but got //example/defs.star:Patagonia.")
 }
 
-func TestSchmeaDescriptorEvaluateIncorrectSchemaType(t *testing.T) {
-	thread := starlark.Thread{}
+func TestSchemaDescriptorEvaluateConstraints(t *testing.T) {
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("port"), IntDescriptor{Unique: true})
+	fields.SetKey(starlark.String("team"), StringDescriptor{})
+	instances := new(starlark.Dict)
+	instances.SetKey(starlark.String("//service:web"), makeInstance(8080, "growth"))
+	instances.SetKey(starlark.String("//service:api"), makeInstance(8081, "growth"))
 
-	manager := NewSchemaContextManager()
-	thread.SetLocal(SchemaContextManagerThreadKey, manager)
+	descriptor := SchemaDescriptor{
+		Identity: makeSchemaDescriptor("Service", nil).Identity,
+		Fields:   fields,
+		Constraints: []starlark.Callable{
+			tester.MockBuiltinWithCallback(func(args starlark.Tuple, kwargs []starlark.Tuple) {
+				assert.Equal(t, starlark.Tuple{instances}, args)
+				assert.ElementsMatch(t, []starlark.Tuple{}, kwargs)
+			}),
+		},
+	}
+	err := descriptor.EvaluateConstraints(&starlark.Thread{}, instances)
 
-	wantedDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(wantedDescriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(wantedDescriptor.SKU()),
-		"Supreme",
-		target.FileTarget{},
-	)
+	assert.Nil(t, err)
+}
 
-	otherDescriptor := SchemaDescriptor{UUID: uuid.New()}
-	manager.QueueSeenDescriptor(otherDescriptor)
-	manager.UpdateRecognizedSchema(
-		tester.MockBuiltinWithName(otherDescriptor.SKU()),
-		"Patagonia",
-		target.FileTarget{},
-	)
+func TestSchemaDescriptorEvaluateConstraintsDuplicateUniqueField(t *testing.T) {
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("port"), IntDescriptor{Unique: true})
+	fields.SetKey(starlark.String("team"), StringDescriptor{})
+	instances := new(starlark.Dict)
+	instances.SetKey(starlark.String("//service:web"), makeInstance(8080, "growth"))
+	instances.SetKey(starlark.String("//service:api"), makeInstance(8080, "growth"))
 
-	userValue := SchemaResult{
-		UUID:             uuid.New(),
-		SchemaDescriptor: otherDescriptor,
-		Evaluated:        new(starlark.Dict),
-	}
-	_, err := wantedDescriptor.Evaluate(&thread, userValue)
+	descriptor := makeSchemaDescriptor("Supreme", fields)
+	err := descriptor.EvaluateConstraints(&starlark.Thread{}, instances)
 
-	assert.ErrorContains(t, err, "Expected Supreme but got Patagonia.")
+	assert.ErrorContains(t, err,
+		"Duplicate value 8080 for unique field port in //service:web and //service:api.")
+}
+
+func TestSchemaDescriptorEvaluateConstraintsError(t *testing.T) {
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("team"), StringDescriptor{Unique: false})
+	instances := new(starlark.Dict)
+	instances.SetKey(starlark.String("//service:web"), makeInstance(8080, "growth"))
+	instances.SetKey(starlark.String("//service:api"), makeInstance(8080, "growth"))
+
+	descriptor := makeSchemaDescriptor("Service", fields)
+	descriptor.Constraints = []starlark.Callable{tester.MockFailingFunction("yikes!")}
+	err := descriptor.EvaluateConstraints(&starlark.Thread{}, instances)
+
+	assert.ErrorContains(t, err, "yikes!")
 }
 
 func TestSchemaDescriptorString(t *testing.T) {
-	id := uuid.New()
 	fields := new(starlark.Dict)
 	fields.SetKey(starlark.String("ex-bool"), BoolDescriptor{})
 	fields.SetKey(starlark.String("ex-string"), StringDescriptor{DefaultValue: "hello"})
-	descriptor := SchemaDescriptor{
-		UUID:   id,
-		Fields: fields,
-	}
+	descriptor := makeSchemaDescriptor("Supreme", fields)
 
-	expected := fmt.Sprintf(`{"Type":"SchemaDescriptor","Descriptor":{"UUID":"%s","Fields":{},"Validations":null}}`, id)
+	expected := `{"Type":"SchemaDescriptor","Descriptor":{"Identity":"//example/defs.star:Supreme","Fields":{},"Validations":null,"Constraints":null}}`
 
 	assert.Equal(t, expected, descriptor.String())
 }
 
 func TestSchemaDescriptorType(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
 
 	assert.Equal(t, "SchemaDescriptor", descriptor.Type())
 }
 
 func TestSchemaDescriptorFreeze(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
 	descriptor.Freeze() // no-op
 }
 
 func TestSchemaDescriptorTruth(t *testing.T) {
-	descriptor := SchemaDescriptor{UUID: uuid.New()}
+	descriptor := makeSchemaDescriptor("Supreme", nil)
 
 	assert.Equal(t, starlark.Bool(true), descriptor.Truth())
 }
@@ -320,5 +333,14 @@ func TestSchemaDescriptorHash(t *testing.T) {
 	hash, err := SchemaDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(1590550606), hash)
+	assert.Equal(t, uint32(0x3659a1a5), hash)
+}
+
+// MARK: - Helpers
+
+func makeInstance(port int, team string) SchemaResult {
+	evaluated := new(starlark.Dict)
+	evaluated.SetKey(starlark.String("port"), starlark.MakeInt(port))
+	evaluated.SetKey(starlark.String("team"), starlark.String(team))
+	return SchemaResult{UUID: uuid.New(), Evaluated: evaluated}
 }
@@ -0,0 +1,222 @@
+package native
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+var BuildSettingsThreadKey string = "starfig-build-settings"
+
+const selectDefaultCondition string = "default"
+
+// BuildSettings are the settings a build was invoked with, i.e. --define env=prod. The
+// settings read during evaluation are recorded, so the output can note which were used.
+type BuildSettings struct {
+	values map[string]string
+	used   map[string]bool
+	// The positions of the outermost frame each setting was read at, i.e. within a statement
+	// of the STARFIG file being evaluated.
+	reads map[string][]syntax.Position
+}
+
+func NewBuildSettings(values map[string]string) BuildSettings {
+	settings := BuildSettings{
+		values: map[string]string{},
+		used:   map[string]bool{},
+		reads:  map[string][]syntax.Position{},
+	}
+	for key, value := range values {
+		settings.values[key] = value
+	}
+	return settings
+}
+
+func getBuildSettings(thread *starlark.Thread) BuildSettings {
+	settings, ok := thread.Local(BuildSettingsThreadKey).(BuildSettings)
+	if !ok {
+		return NewBuildSettings(map[string]string{})
+	}
+	return settings
+}
+
+func (settings BuildSettings) Get(key string) (string, bool) {
+	value, found := settings.values[key]
+	if found {
+		settings.used[key] = true
+	}
+	return value, found
+}
+
+// Read gets a setting for the code running in the thread, recording where it was read so the
+// settings used by each statement are known.
+func (settings BuildSettings) Read(thread *starlark.Thread, key string) (string, bool) {
+	value, found := settings.Get(key)
+	if found && thread.CallStackDepth() > 0 {
+		pos := thread.CallFrame(thread.CallStackDepth() - 1).Pos
+		settings.reads[key] = append(settings.reads[key], pos)
+	}
+	return value, found
+}
+
+// Used returns the settings that were read during evaluation.
+func (settings BuildSettings) Used() map[string]string {
+	used := map[string]string{}
+	for key := range settings.used {
+		used[key] = settings.values[key]
+	}
+	return used
+}
+
+// UsedBy returns the settings that were read while executing the statements, including
+// the files they load and the functions they call.
+func (settings BuildSettings) UsedBy(stmts []syntax.Stmt) map[string]string {
+	used := map[string]string{}
+	for key, positions := range settings.reads {
+		for _, pos := range positions {
+			if withinAny(pos, stmts) {
+				used[key] = settings.values[key]
+				break
+			}
+		}
+	}
+	return used
+}
+
+func withinAny(pos syntax.Position, stmts []syntax.Stmt) bool {
+	for _, stmt := range stmts {
+		start, end := stmt.Span()
+		if pos.Filename() == start.Filename() && !isBefore(pos, start) && !isBefore(end, pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatSettings formats the settings sorted by key, i.e. env=prod,region=eu.
+func FormatSettings(settings map[string]string) string {
+	keys := []string{}
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	components := []string{}
+	for _, key := range keys {
+		components = append(components, fmt.Sprintf("%s=%s", key, settings[key]))
+	}
+	return strings.Join(components, ",")
+}
+
+// MARK: - SettingsProvider
+
+func SettingsProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if args.Len() > 0 || len(kwargs) > 0 {
+		return starlark.None, fmt.Errorf("settings does not take any arguments.")
+	}
+
+	settings := getBuildSettings(thread)
+	keys := []string{}
+	for key := range settings.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := new(starlark.Dict)
+	for _, key := range keys {
+		value, _ := settings.Read(thread, key)
+		result.SetKey(starlark.String(key), starlark.String(value))
+	}
+	result.Freeze()
+	return result, nil
+}
+
+// MARK: - SelectProvider
+
+// Select picks a value based on the build settings. A condition is a setting, i.e. "env:prod",
+// or multiple settings joined by commas, i.e. "env:prod,region:eu", which must all match. The
+// most specific matching condition wins, otherwise the "default" value is used.
+func SelectProvider(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if args.Len() != 1 || len(kwargs) > 0 {
+		return starlark.None, fmt.Errorf(
+			"select requires a single dictionary of conditions. i.e. select({\"env:prod\": 10, \"default\": 1}).")
+	}
+
+	conditions, ok := args[0].(*starlark.Dict)
+	if !ok {
+		return starlark.None, fmt.Errorf(
+			"Expected select conditions to be a dictionary, but got %s.", args[0])
+	}
+
+	settings := getBuildSettings(thread)
+	var defaultValue starlark.Value
+	var selectedValue starlark.Value
+	selectedCondition := ""
+	selectedSpecificity := 0
+	ambiguousCondition := ""
+
+	for _, tuple := range conditions.Items() {
+		condition, ok := tuple.Index(0).(starlark.String)
+		if !ok {
+			return starlark.None, fmt.Errorf(
+				"Expected select condition to be a string, but got %s.", tuple.Index(0))
+		}
+
+		if condition.GoString() == selectDefaultCondition {
+			defaultValue = tuple.Index(1)
+			continue
+		}
+
+		matches, specificity, err := matchCondition(thread, settings, condition.GoString())
+		if err != nil {
+			return starlark.None, err
+		} else if !matches || specificity < selectedSpecificity {
+			continue
+		} else if specificity == selectedSpecificity {
+			ambiguousCondition = condition.GoString()
+			continue
+		}
+
+		selectedValue = tuple.Index(1)
+		selectedCondition = condition.GoString()
+		selectedSpecificity = specificity
+		ambiguousCondition = ""
+	}
+
+	if ambiguousCondition != "" {
+		return starlark.None, fmt.Errorf(
+			"Ambiguous select, both %s and %s match.", selectedCondition, ambiguousCondition)
+	} else if selectedValue != nil {
+		return selectedValue, nil
+	} else if defaultValue != nil {
+		return defaultValue, nil
+	}
+
+	return starlark.None, fmt.Errorf(
+		"No select condition matches the settings %s and there is no default.",
+		FormatSettings(settings.values))
+}
+
+// Every setting in the condition is read, even if an earlier one doesn't match, since the
+// selected value depends on all of them.
+func matchCondition(
+	thread *starlark.Thread, settings BuildSettings, condition string) (bool, int, error) {
+	matches := true
+	specificity := 0
+	for _, component := range strings.Split(condition, ",") {
+		key, expected, found := strings.Cut(strings.TrimSpace(component), ":")
+		if !found || key == "" {
+			return false, 0, fmt.Errorf(
+				"Invalid select condition %s. i.e. \"env:prod\" or \"default\".", condition)
+		}
+		value, found := settings.Read(thread, key)
+		if !found || value != expected {
+			matches = false
+		}
+		specificity += 1
+	}
+	return matches, specificity, nil
+}
@@ -0,0 +1,161 @@
+package native
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/tester"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+// MARK: - BuildSettings
+
+func TestBuildSettingsUsed(t *testing.T) {
+	settings := NewBuildSettings(map[string]string{"env": "prod", "region": "eu"})
+	value, found := settings.Get("env")
+	assert.True(t, found)
+	assert.Equal(t, "prod", value)
+	_, found = settings.Get("team")
+	assert.False(t, found)
+
+	assert.Equal(t, map[string]string{"env": "prod"}, settings.Used())
+}
+
+func TestFormatSettings(t *testing.T) {
+	formatted := FormatSettings(map[string]string{"region": "eu", "env": "prod"})
+
+	assert.Equal(t, "env=prod,region=eu", formatted)
+}
+
+// MARK: - SettingsProvider
+
+func TestSettingsProvider(t *testing.T) {
+	settings := NewBuildSettings(map[string]string{"env": "prod"})
+	thread := starlark.Thread{}
+	thread.SetLocal(BuildSettingsThreadKey, settings)
+	value, err := SettingsProvider(&thread, tester.MockBuiltin(), starlark.Tuple{}, []starlark.Tuple{})
+
+	assert.Nil(t, err)
+	expected := new(starlark.Dict)
+	expected.SetKey(starlark.String("env"), starlark.String("prod"))
+	expected.Freeze()
+	assert.Equal(t, expected, value)
+	assert.Equal(t, map[string]string{"env": "prod"}, settings.Used())
+}
+
+func TestSettingsProviderWithArguments(t *testing.T) {
+	_, err := SettingsProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{starlark.MakeInt(416)},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "settings does not take any arguments.")
+}
+
+// MARK: - SelectProvider
+
+func TestSelectProvider(t *testing.T) {
+	value, err := callSelect(
+		map[string]string{"env": "prod"},
+		makeConditions("env:dev", 1, "env:prod", 10, "default", 0),
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, starlark.MakeInt(10), value)
+}
+
+func TestSelectProviderDefault(t *testing.T) {
+	value, err := callSelect(
+		map[string]string{},
+		makeConditions("env:prod", 10, "default", 1),
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, starlark.MakeInt(1), value)
+}
+
+func TestSelectProviderMostSpecific(t *testing.T) {
+	value, err := callSelect(
+		map[string]string{"env": "prod", "region": "eu"},
+		makeConditions("env:prod", 10, "env:prod,region:eu", 20, "default", 1),
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, starlark.MakeInt(20), value)
+}
+
+func TestSelectProviderAmbiguous(t *testing.T) {
+	_, err := callSelect(
+		map[string]string{"env": "prod", "region": "eu"},
+		makeConditions("env:prod", 10, "region:eu", 20),
+	)
+
+	assert.ErrorContains(t, err, "Ambiguous select, both env:prod and region:eu match.")
+}
+
+func TestSelectProviderNoMatch(t *testing.T) {
+	_, err := callSelect(
+		map[string]string{"env": "dev"},
+		makeConditions("env:prod", 10),
+	)
+
+	assert.ErrorContains(t, err, "No select condition matches the settings env=dev and there is no default.")
+}
+
+func TestSelectProviderInvalidCondition(t *testing.T) {
+	_, err := callSelect(
+		map[string]string{},
+		makeConditions("prod", 10),
+	)
+
+	assert.ErrorContains(t, err, "Invalid select condition prod. i.e. \"env:prod\" or \"default\".")
+}
+
+func TestSelectProviderNonStringCondition(t *testing.T) {
+	conditions := new(starlark.Dict)
+	conditions.SetKey(starlark.MakeInt(416), starlark.MakeInt(10))
+	_, err := callSelect(map[string]string{}, conditions)
+
+	assert.ErrorContains(t, err, "Expected select condition to be a string, but got 416.")
+}
+
+func TestSelectProviderNonDictionary(t *testing.T) {
+	_, err := SelectProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{starlark.MakeInt(416)},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err, "Expected select conditions to be a dictionary, but got 416.")
+}
+
+func TestSelectProviderNoArguments(t *testing.T) {
+	_, err := SelectProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{},
+	)
+
+	assert.ErrorContains(t, err,
+		"select requires a single dictionary of conditions. i.e. select({\"env:prod\": 10, \"default\": 1}).")
+}
+
+// MARK: - Helpers
+
+func callSelect(values map[string]string, conditions *starlark.Dict) (starlark.Value, error) {
+	thread := starlark.Thread{}
+	thread.SetLocal(BuildSettingsThreadKey, NewBuildSettings(values))
+	return SelectProvider(&thread, tester.MockBuiltin(), starlark.Tuple{conditions}, []starlark.Tuple{})
+}
+
+func makeConditions(pairs ...interface{}) *starlark.Dict {
+	conditions := new(starlark.Dict)
+	for i := 0; i < len(pairs); i += 2 {
+		conditions.SetKey(starlark.String(pairs[i].(string)), starlark.MakeInt(pairs[i+1].(int)))
+	}
+	return conditions
+}
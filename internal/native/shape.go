@@ -0,0 +1,74 @@
+package native
+
+import (
+	"fmt"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// SchemaShape is the structure of a schema as it appears in the output. Schemas are identified
+// by their target, so shapes can be compared between revisions.
+type SchemaShape struct {
+	// The target of the schema. i.e. //example/geography/metadata.star:Language
+	Name   string       `json:"name"`
+	Fields []FieldShape `json:"fields"`
+}
+
+type FieldShape struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	// The target of the schema of an Object or Ref field.
+	Schema string `json:"schema,omitempty"`
+	// A Ref field is inlined as the referenced instance instead of its label.
+	Inline bool `json:"inline,omitempty"`
+	// The shape of the items of a List field.
+	Item *FieldShape `json:"item,omitempty"`
+	// A schema nested in an Object or Ref field, i.e. Object(Schema(...)), is named by the
+	// field rather than a global, so it is compared by its fields.
+	Nested bool         `json:"nested,omitempty"`
+	Fields []FieldShape `json:"fields,omitempty"`
+}
+
+func NewSchemaShape(descriptor SchemaDescriptor) SchemaShape {
+	return SchemaShape{Name: descriptor.SKU(), Fields: newFieldShapes(descriptor)}
+}
+
+func newFieldShapes(descriptor SchemaDescriptor) []FieldShape {
+	fields := []FieldShape{}
+	for _, tuple := range descriptor.Fields.Items() {
+		fieldName, _ := starlark.AsString(tuple.Index(0))
+		path := fmt.Sprintf("%s.%s", descriptor.SKU(), fieldName)
+		fields = append(fields, newFieldShape(fieldName, path, tuple.Index(1).(Descriptor)))
+	}
+	return fields
+}
+
+// The shape of a field, where the path is the identity a schema nested in the field has.
+func newFieldShape(name string, path string, descriptor Descriptor) FieldShape {
+	field := FieldShape{
+		Name:     name,
+		Type:     strings.TrimSuffix(descriptor.Type(), "Descriptor"),
+		Required: bool(descriptor.IsRequired()),
+	}
+
+	switch typed := descriptor.(type) {
+	case SchemaDescriptor:
+		field.Type = "Object"
+		field.Schema = typed.SKU()
+	case ObjectDescriptor:
+		field.Schema = typed.WrappedDescriptor.SKU()
+	case RefDescriptor:
+		field.Schema = typed.WrappedDescriptor.SKU()
+		field.Inline = bool(typed.Inline)
+	case ListDescriptor:
+		item := newFieldShape("", path, typed.WrappedDescriptor)
+		field.Item = &item
+	}
+	if nested, ok := NestedSchema(descriptor); ok && field.Item == nil && nested.SKU() == path {
+		field.Nested = true
+		field.Fields = newFieldShapes(nested)
+	}
+	return field
+}
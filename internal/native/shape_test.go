@@ -0,0 +1,33 @@
+package native
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestNewSchemaShape(t *testing.T) {
+	team := makeSchemaDescriptor("Team", starlark.NewDict(0))
+	fields := starlark.NewDict(4)
+	fields.SetKey(starlark.String("name"), StringDescriptor{UUID: uuid.New(), Required: true})
+	fields.SetKey(starlark.String("team"), RefDescriptor{UUID: uuid.New(), WrappedDescriptor: team, Inline: true})
+	fields.SetKey(starlark.String("owners"), ListDescriptor{
+		UUID:              uuid.New(),
+		WrappedDescriptor: RefDescriptor{UUID: uuid.New(), WrappedDescriptor: team},
+	})
+	fields.SetKey(starlark.String("lead"), ObjectDescriptor{UUID: uuid.New(), WrappedDescriptor: team})
+	descriptor := makeSchemaDescriptor("Service", fields)
+
+	shape := NewSchemaShape(descriptor)
+	assert.Equal(t, SchemaShape{
+		Name: "//example/defs.star:Service",
+		Fields: []FieldShape{
+			{Name: "name", Type: "String", Required: true},
+			{Name: "team", Type: "Ref", Schema: "//example/defs.star:Team", Inline: true},
+			{Name: "owners", Type: "List", Item: &FieldShape{Type: "Ref", Schema: "//example/defs.star:Team"}},
+			{Name: "lead", Type: "Object", Schema: "//example/defs.star:Team"},
+		},
+	}, shape)
+}
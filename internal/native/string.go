@@ -15,6 +15,7 @@ func StringProvider(thread *starlark.Thread, builtin *starlark.Builtin, args sta
 		UUID:         uuid.New(),
 		DefaultValue: "",
 		Required:     false,
+		Unique:       false,
 		Validations:  []starlark.Callable{},
 	}
 
@@ -22,9 +23,11 @@ func StringProvider(thread *starlark.Thread, builtin *starlark.Builtin, args sta
 		return starlark.None, fmt.Errorf("Invalid positional arguments %s in String().", args)
 	}
 
+	hasDefault := false
 	for kwargName, kwargValue := range util.KwargsToMap(kwargs) {
 		switch kwargName {
 		case "default":
+			hasDefault = true
 			defaultValue, ok := kwargValue.(starlark.String)
 			if !ok {
 				return starlark.None, fmt.Errorf(
@@ -38,16 +41,40 @@ func StringProvider(thread *starlark.Thread, builtin *starlark.Builtin, args sta
 					"Expected required value to be bool, but got %s.", kwargValue)
 			}
 			provider.Required = requiredValue
+		case "unique":
+			uniqueValue, ok := kwargValue.(starlark.Bool)
+			if !ok {
+				return starlark.None, fmt.Errorf(
+					"Expected unique value to be bool, but got %s.", kwargValue)
+			}
+			provider.Unique = uniqueValue
+		case "computed":
+			err := extractComputed(&provider.Computed, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		case "validations":
 			err := extractValidations(&provider.Validations, kwargValue)
 			if err != nil {
 				return starlark.None, err
 			}
+		case "doc":
+			err := extractDoc(&provider.Doc, kwargValue)
+			if err != nil {
+				return starlark.None, err
+			}
 		default:
 			return starlark.None, fmt.Errorf("Unknown keyword %s in String().", kwargName)
 		}
 	}
 
+	if provider.Required && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot be required in String().")
+	}
+	if hasDefault && provider.Computed != nil {
+		return starlark.None, fmt.Errorf("A computed field cannot have a default in String().")
+	}
+
 	return provider, nil
 }
 
@@ -57,7 +84,10 @@ type StringDescriptor struct {
 	UUID         uuid.UUID
 	DefaultValue starlark.String
 	Required     starlark.Bool
+	Unique       starlark.Bool
 	Validations  []starlark.Callable
+	Computed     starlark.Callable
+	Doc          starlark.String `json:",omitempty"`
 }
 
 func (descriptor StringDescriptor) SKU() string {
@@ -72,6 +102,18 @@ func (descriptor StringDescriptor) IsRequired() starlark.Bool {
 	return descriptor.Required
 }
 
+func (descriptor StringDescriptor) IsUnique() starlark.Bool {
+	return descriptor.Unique
+}
+
+func (descriptor StringDescriptor) Documentation() starlark.String {
+	return descriptor.Doc
+}
+
+func (descriptor StringDescriptor) ComputedFunction() starlark.Callable {
+	return descriptor.Computed
+}
+
 func (descriptor StringDescriptor) Evaluate(
 	thread *starlark.Thread, value starlark.Value) (starlark.Value, error) {
 	stringValue, ok := value.(starlark.String)
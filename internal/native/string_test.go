@@ -26,6 +26,7 @@ func TestStringProvider(t *testing.T) {
 		[]starlark.Tuple{
 			{starlark.String("default"), starlark.String("hello")},
 			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("unique"), starlark.Bool(true)},
 			{starlark.String("validations"), validations},
 		},
 	)
@@ -36,6 +37,7 @@ func TestStringProvider(t *testing.T) {
 
 	assert.Equal(t, starlark.String("hello"), provider.Default())
 	assert.Equal(t, starlark.Bool(true), provider.IsRequired())
+	assert.Equal(t, starlark.Bool(true), provider.IsUnique())
 	tester.AssertSameValidations(t, validations, provider.Validations)
 }
 
@@ -76,6 +78,104 @@ func TestStringProviderWithInvalidRequiredType(t *testing.T) {
 	assert.ErrorContains(t, err, `Expected required value to be bool, but got 416.`)
 }
 
+func TestStringProviderWithInvalidUniqueType(t *testing.T) {
+	_, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("unique"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected unique value to be bool, but got 416.`)
+}
+
+func TestStringProviderWithComputed(t *testing.T) {
+	computed := tester.MockBuiltin()
+	value, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), computed},
+		},
+	)
+
+	assert.Nil(t, err)
+	provider := value.(StringDescriptor)
+	assert.Same(t, computed, provider.ComputedFunction())
+}
+
+func TestStringProviderWithInvalidComputedType(t *testing.T) {
+	_, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("computed"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected computed value to be a function, but got 416.`)
+}
+
+func TestStringProviderWithDoc(t *testing.T) {
+	value, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("doc"), starlark.String("The name of the fruit.")},
+		},
+	)
+
+	assert.Nil(t, err)
+	provider := value.(StringDescriptor)
+	assert.Equal(t, starlark.String("The name of the fruit."), provider.Doc)
+}
+
+func TestStringProviderWithInvalidDocType(t *testing.T) {
+	_, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("doc"), starlark.MakeInt(416)},
+		},
+	)
+
+	assert.ErrorContains(t, err, `Expected doc value to be a string, but got 416.`)
+}
+
+func TestStringProviderWithRequiredComputed(t *testing.T) {
+	_, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("required"), starlark.Bool(true)},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot be required in String().`)
+}
+
+func TestStringProviderWithDefaultComputed(t *testing.T) {
+	_, err := StringProvider(
+		&starlark.Thread{},
+		tester.MockBuiltin(),
+		starlark.Tuple{},
+		[]starlark.Tuple{
+			{starlark.String("default"), starlark.String("apple")},
+			{starlark.String("computed"), tester.MockBuiltin()},
+		},
+	)
+
+	assert.ErrorContains(t, err, `A computed field cannot have a default in String().`)
+}
+
 func TestStringProviderWithInvalidValidationsType(t *testing.T) {
 	_, err := StringProvider(
 		&starlark.Thread{},
@@ -137,6 +237,12 @@ func TestStringDescriptorIsRequired(t *testing.T) {
 	assert.Equal(t, starlark.Bool(true), descriptor.IsRequired())
 }
 
+func TestStringDescriptorIsUnique(t *testing.T) {
+	descriptor := StringDescriptor{Unique: true}
+
+	assert.Equal(t, starlark.Bool(true), descriptor.IsUnique())
+}
+
 func TestStringDescriptorEvaluate(t *testing.T) {
 	descriptor := StringDescriptor{
 		Validations: []starlark.Callable{
@@ -190,7 +296,7 @@ func TestStringDescriptorString(t *testing.T) {
 		DefaultValue: starlark.String("hello"),
 		Required:     true,
 	}
-	expected := fmt.Sprintf(`{"Type":"StringDescriptor","Descriptor":{"UUID":"%s","DefaultValue":"hello","Required":true,"Validations":null}}`, id)
+	expected := fmt.Sprintf(`{"Type":"StringDescriptor","Descriptor":{"UUID":"%s","DefaultValue":"hello","Required":true,"Unique":false,"Validations":null,"Computed":null}}`, id)
 
 	assert.Equal(t, expected, descriptor.String())
 }
@@ -216,5 +322,5 @@ func TestStringDescriptorHash(t *testing.T) {
 	hash, err := StringDescriptor{}.Hash()
 
 	assert.Nil(t, err)
-	assert.Equal(t, uint32(3208191298), hash)
+	assert.Equal(t, uint32(3563712560), hash)
 }
@@ -0,0 +1,156 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jathu/starfig/internal/native"
+	"go.starlark.net/starlark"
+)
+
+const (
+	LabelOutput string = "label"
+	JSONOutput  string = "json"
+	GraphOutput string = "graph"
+)
+
+var functionArgCounts = map[string]int{
+	"deps":   1,
+	"rdeps":  2,
+	"schema": 1,
+}
+
+var functionPattern = regexp.MustCompile(`^([a-z]+)\((.*)\)$`)
+
+// Expression is a query, i.e. deps(//pkg:target). A plain target pattern, i.e. //pkg/...,
+// has no function and the pattern as its only argument.
+type Expression struct {
+	Function string
+	Args     []string
+}
+
+func ParseExpression(rawExpression string) (Expression, error) {
+	raw := strings.TrimSpace(rawExpression)
+	if raw == "" {
+		return Expression{}, fmt.Errorf("Query is empty. i.e. starfig query 'deps(//pkg:target)'.")
+	}
+
+	match := functionPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return Expression{Function: "", Args: []string{raw}}, nil
+	}
+
+	function := match[1]
+	argCount, found := functionArgCounts[function]
+	if !found {
+		return Expression{}, fmt.Errorf(
+			"Unknown query function %s. Expected deps, rdeps or schema.", function)
+	}
+
+	args := []string{}
+	for _, arg := range strings.Split(match[2], ",") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+	if len(args) != argCount {
+		return Expression{}, fmt.Errorf(
+			"%s expects %d arguments, but got %s.", function, argCount, raw)
+	}
+	return Expression{Function: function, Args: args}, nil
+}
+
+func ValidateOutput(output string) error {
+	switch output {
+	case LabelOutput, JSONOutput, GraphOutput:
+		return nil
+	default:
+		return fmt.Errorf(
+			"Unknown output %s. Expected %s, %s or %s.", output, LabelOutput, JSONOutput, GraphOutput)
+	}
+}
+
+// FormatLabels formats the labels as lines, a JSON list or a Graphviz DOT graph of the
+// dependencies between the labels.
+func FormatLabels(labels []string, output string, graph *native.DependencyGraph) (string, error) {
+	switch output {
+	case JSONOutput:
+		data, err := json.MarshalIndent(labels, "", "  ")
+		return string(data), err
+	case GraphOutput:
+		return formatGraph(labels, graph), nil
+	default:
+		return strings.Join(labels, "\n"), nil
+	}
+}
+
+func formatGraph(labels []string, graph *native.DependencyGraph) string {
+	included := map[string]bool{}
+	for _, label := range labels {
+		included[label] = true
+	}
+
+	lines := []string{"digraph starfig {"}
+	for _, label := range labels {
+		lines = append(lines, fmt.Sprintf("  %q;", label))
+	}
+	for _, label := range labels {
+		for _, dependency := range graph.Edges(label) {
+			if included[dependency] {
+				lines = append(lines, fmt.Sprintf("  %q -> %q;", label, dependency))
+			}
+		}
+	}
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n")
+}
+
+// MARK: - Schema
+
+type SchemaField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+type SchemaInfo struct {
+	Target string        `json:"target"`
+	Schema string        `json:"schema"`
+	Fields []SchemaField `json:"fields"`
+}
+
+func NewSchemaInfo(target string, schemaTarget string, descriptor native.SchemaDescriptor) SchemaInfo {
+	info := SchemaInfo{Target: target, Schema: schemaTarget, Fields: []SchemaField{}}
+	for _, tuple := range descriptor.Fields.Items() {
+		name, _ := starlark.AsString(tuple.Index(0))
+		fieldDescriptor := tuple.Index(1).(native.Descriptor)
+		info.Fields = append(info.Fields, SchemaField{
+			Name:     name,
+			Type:     strings.TrimSuffix(fieldDescriptor.Type(), "Descriptor"),
+			Required: bool(fieldDescriptor.IsRequired()),
+		})
+	}
+	return info
+}
+
+func FormatSchema(info SchemaInfo, output string) (string, error) {
+	switch output {
+	case JSONOutput:
+		data, err := json.MarshalIndent(info, "", "  ")
+		return string(data), err
+	case GraphOutput:
+		return "", fmt.Errorf("schema() can't be output as a graph. Use --output label or json.")
+	default:
+		lines := []string{fmt.Sprintf("%s: %s", info.Target, info.Schema)}
+		for _, field := range info.Fields {
+			required := ""
+			if field.Required {
+				required = " (required)"
+			}
+			lines = append(lines, fmt.Sprintf("  %s: %s%s", field.Name, field.Type, required))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
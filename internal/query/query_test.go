@@ -0,0 +1,86 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/jathu/starfig/internal/native"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestParseExpression(t *testing.T) {
+	expression, err := ParseExpression("//pkg/...")
+	assert.Nil(t, err)
+	assert.Equal(t, Expression{Function: "", Args: []string{"//pkg/..."}}, expression)
+
+	expression, err = ParseExpression(" deps(//pkg:target) ")
+	assert.Nil(t, err)
+	assert.Equal(t, Expression{Function: "deps", Args: []string{"//pkg:target"}}, expression)
+
+	expression, err = ParseExpression("rdeps(//..., //pkg/defs.star)")
+	assert.Nil(t, err)
+	assert.Equal(t, Expression{Function: "rdeps", Args: []string{"//...", "//pkg/defs.star"}}, expression)
+}
+
+func TestParseExpressionInvalid(t *testing.T) {
+	_, err := ParseExpression("")
+	assert.ErrorContains(t, err, "Query is empty.")
+
+	_, err = ParseExpression("kinds(//pkg:target)")
+	assert.ErrorContains(t, err, "Unknown query function kinds. Expected deps, rdeps or schema.")
+
+	_, err = ParseExpression("rdeps(//pkg/defs.star)")
+	assert.ErrorContains(t, err, "rdeps expects 2 arguments, but got rdeps(//pkg/defs.star).")
+
+	_, err = ParseExpression("deps()")
+	assert.ErrorContains(t, err, "deps expects 1 arguments, but got deps().")
+}
+
+func TestValidateOutput(t *testing.T) {
+	assert.Nil(t, ValidateOutput("label"))
+	assert.Nil(t, ValidateOutput("json"))
+	assert.Nil(t, ValidateOutput("graph"))
+	assert.ErrorContains(t, ValidateOutput("xml"), "Unknown output xml. Expected label, json or graph.")
+}
+
+func TestFormatLabels(t *testing.T) {
+	graph := native.NewDependencyGraph()
+	graph.AddEdge("//a:x", "//a/STARFIG")
+	graph.AddEdge("//a/STARFIG", "//b/defs.star")
+	labels := []string{"//a/STARFIG", "//a:x"}
+
+	output, err := FormatLabels(labels, LabelOutput, graph)
+	assert.Nil(t, err)
+	assert.Equal(t, "//a/STARFIG\n//a:x", output)
+
+	output, err = FormatLabels(labels, JSONOutput, graph)
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  \"//a/STARFIG\",\n  \"//a:x\"\n]", output)
+
+	// Only the edges between the labels are included.
+	output, err = FormatLabels(labels, GraphOutput, graph)
+	assert.Nil(t, err)
+	assert.Equal(t, `digraph starfig {
+  "//a/STARFIG";
+  "//a:x";
+  "//a:x" -> "//a/STARFIG";
+}`, output)
+}
+
+func TestFormatSchema(t *testing.T) {
+	fields := new(starlark.Dict)
+	fields.SetKey(starlark.String("name"), native.StringDescriptor{Required: true})
+	fields.SetKey(starlark.String("count"), native.IntDescriptor{})
+	info := NewSchemaInfo("//pkg:target", "//pkg/defs.star:Thing", native.SchemaDescriptor{Fields: fields})
+
+	output, err := FormatSchema(info, LabelOutput)
+	assert.Nil(t, err)
+	assert.Equal(t, "//pkg:target: //pkg/defs.star:Thing\n  name: String (required)\n  count: Int", output)
+
+	output, err = FormatSchema(info, JSONOutput)
+	assert.Nil(t, err)
+	assert.Contains(t, output, `"schema": "//pkg/defs.star:Thing"`)
+
+	_, err = FormatSchema(info, GraphOutput)
+	assert.ErrorContains(t, err, "schema() can't be output as a graph.")
+}
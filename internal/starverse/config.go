@@ -0,0 +1,258 @@
+package starverse
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+const DefaultOutputFormat string = "json"
+
+// A MatrixAxis is a build setting with every value it should be built with.
+// i.e. env = [dev, prod]
+type MatrixAxis struct {
+	Key    string
+	Values []string
+}
+
+// Config is the universe-wide configuration declared in the STARVERSE file.
+type Config struct {
+	// The format of the build output. This is json or the name of a generator.
+	OutputFormat string
+	// The directory to write each built target to, instead of printing the output.
+	OutputDir string
+	// Gitignore style patterns of paths to skip when searching for packages, in addition to
+	// the .starfigignore file.
+	Ignore []string
+	// Code generators receive the json output on stdin and print the generated output. They
+	// are registered by name with the command to run. i.e. {"yaml": ["yq", "-P"]}
+	Generators map[string][]string
+	// Default build settings, which can be overridden with --define.
+	Settings map[string]string
+	// Build matrix axes, which can be overridden with --matrix.
+	Matrix []MatrixAxis
+	// The minimum version of starfig required to build the universe.
+	MinStarfigVersion string
+	// External universes declared with universe().
+	Universes []UniverseConfig
+	// Overrides whether a kind of schema change is breaking or compatible for schema check.
+	// i.e. {"made-optional": "breaking"}
+	SchemaPolicy map[string]string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		OutputFormat: DefaultOutputFormat,
+		OutputDir:    "",
+		Ignore:       []string{},
+		Generators:   map[string][]string{},
+		Settings:     map[string]string{},
+		Matrix:       []MatrixAxis{},
+		Universes:    []UniverseConfig{},
+		SchemaPolicy: map[string]string{},
+	}
+}
+
+// LoadConfig evaluates the STARVERSE file in the directory. The file is Starlark, where
+// each global variable is a setting. Variables starting with an underscore are private
+// helpers and are ignored.
+func LoadConfig(starverseDir string) (Config, error) {
+	config := DefaultConfig()
+	path := filepath.Join(starverseDir, StarverseFilename)
+
+	thread := &starlark.Thread{Name: "LoadConfig"}
+	predeclared := starlark.StringDict{
+		"universe": starlark.NewBuiltin("universe", func(
+			thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			universe, err := parseUniverse(args, kwargs)
+			if err != nil {
+				return starlark.None, err
+			}
+			config.Universes = append(config.Universes, universe)
+			return starlark.None, nil
+		}),
+	}
+	globals, err := starlark.ExecFile(thread, path, nil, predeclared)
+	if err != nil {
+		return config, fmt.Errorf("Unable to evaluate %s: %s", path, err)
+	}
+
+	names := globals.Keys()
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		err := config.set(name, globals[name])
+		if err != nil {
+			return config, fmt.Errorf("Invalid %s: %s", path, err)
+		}
+	}
+
+	return config, config.validate()
+}
+
+func (config *Config) set(name string, value starlark.Value) error {
+	var err error
+	switch name {
+	case "output_format":
+		config.OutputFormat, err = toString(name, value)
+	case "output_dir":
+		config.OutputDir, err = toString(name, value)
+	case "ignore":
+		config.Ignore, err = toStringList(name, value)
+	case "generators":
+		config.Generators = map[string][]string{}
+		err = forEachItem(name, value, func(key string, value starlark.Value) error {
+			command, err := toStringList(fmt.Sprintf("%s[%s]", name, key), value)
+			if err == nil && len(command) == 0 {
+				err = fmt.Errorf("Expected %s[%s] to be a command, but got an empty list.", name, key)
+			}
+			config.Generators[key] = command
+			return err
+		})
+	case "settings":
+		config.Settings = map[string]string{}
+		err = forEachItem(name, value, func(key string, value starlark.Value) error {
+			setting, err := toString(fmt.Sprintf("%s[%s]", name, key), value)
+			config.Settings[key] = setting
+			return err
+		})
+	case "matrix":
+		config.Matrix = []MatrixAxis{}
+		err = forEachItem(name, value, func(key string, value starlark.Value) error {
+			values, err := toStringList(fmt.Sprintf("%s[%s]", name, key), value)
+			config.Matrix = append(config.Matrix, MatrixAxis{Key: key, Values: values})
+			return err
+		})
+	case "min_starfig_version":
+		config.MinStarfigVersion, err = toString(name, value)
+	case "schema_policy":
+		config.SchemaPolicy = map[string]string{}
+		err = forEachItem(name, value, func(key string, value starlark.Value) error {
+			classification, err := toString(fmt.Sprintf("%s[%s]", name, key), value)
+			config.SchemaPolicy[key] = classification
+			return err
+		})
+	default:
+		err = fmt.Errorf("Unknown setting %s.", name)
+	}
+	return err
+}
+
+func (config Config) validate() error {
+	if config.OutputFormat != DefaultOutputFormat {
+		_, found := config.Generators[config.OutputFormat]
+		if !found {
+			return fmt.Errorf(
+				"Unknown output_format %s. It must be %s or the name of a generator.",
+				config.OutputFormat, DefaultOutputFormat)
+		}
+	}
+	names := map[string]bool{}
+	for _, universe := range config.Universes {
+		if names[universe.Name] {
+			return fmt.Errorf("Universe @%s is declared more than once.", universe.Name)
+		}
+		names[universe.Name] = true
+	}
+	if config.MinStarfigVersion != "" {
+		_, err := parseVersion(config.MinStarfigVersion)
+		if err != nil {
+			return fmt.Errorf(
+				"Expected min_starfig_version to be a version like 1.2.3, but got %s.",
+				config.MinStarfigVersion)
+		}
+	}
+	return nil
+}
+
+// CheckVersion ensures the starfig version is at least the minimum version of the universe.
+// Development builds are always allowed.
+func (config Config) CheckVersion(starfigVersion string) error {
+	if config.MinStarfigVersion == "" || starfigVersion == "dev" {
+		return nil
+	}
+
+	minimum, _ := parseVersion(config.MinStarfigVersion)
+	current, err := parseVersion(starfigVersion)
+	if err != nil {
+		return fmt.Errorf("Unable to compare the starfig version %s.", starfigVersion)
+	}
+	for i := range minimum {
+		if current[i] > minimum[i] {
+			return nil
+		} else if current[i] < minimum[i] {
+			return fmt.Errorf(
+				"This universe requires starfig %s or newer, but the current version is %s.",
+				config.MinStarfigVersion, starfigVersion)
+		}
+	}
+	return nil
+}
+
+// MARK: - Helpers
+
+func parseVersion(version string) ([3]int, error) {
+	result := [3]int{}
+	components := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(components) != 3 {
+		return result, fmt.Errorf("Invalid version %s.", version)
+	}
+	for i, component := range components {
+		number, err := strconv.Atoi(component)
+		if err != nil || number < 0 {
+			return result, fmt.Errorf("Invalid version %s.", version)
+		}
+		result[i] = number
+	}
+	return result, nil
+}
+
+func toString(name string, value starlark.Value) (string, error) {
+	str, ok := value.(starlark.String)
+	if !ok {
+		return "", fmt.Errorf("Expected %s to be a string, but got %s.", name, value)
+	}
+	return str.GoString(), nil
+}
+
+func toStringList(name string, value starlark.Value) ([]string, error) {
+	list, ok := value.(*starlark.List)
+	if !ok {
+		return []string{}, fmt.Errorf("Expected %s to be a list of strings, but got %s.", name, value)
+	}
+	result := []string{}
+	for i := 0; i < list.Len(); i++ {
+		str, ok := list.Index(i).(starlark.String)
+		if !ok {
+			return []string{}, fmt.Errorf(
+				"Expected %s to be a list of strings, but got %s.", name, value)
+		}
+		result = append(result, str.GoString())
+	}
+	return result, nil
+}
+
+func forEachItem(
+	name string, value starlark.Value, callback func(key string, value starlark.Value) error) error {
+	dict, ok := value.(*starlark.Dict)
+	if !ok {
+		return fmt.Errorf("Expected %s to be a dictionary, but got %s.", name, value)
+	}
+	for _, tuple := range dict.Items() {
+		key, ok := tuple.Index(0).(starlark.String)
+		if !ok {
+			return fmt.Errorf("Expected the keys of %s to be strings, but got %s.", name, tuple.Index(0))
+		}
+		err := callback(key.GoString(), tuple.Index(1))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
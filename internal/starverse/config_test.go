@@ -0,0 +1,128 @@
+package starverse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigEmpty(t *testing.T) {
+	config, err := LoadConfig(writeStarverse(t, ""))
+
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultConfig(), config)
+}
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig(writeStarverse(t, `
+_environments = ["dev", "prod"]
+
+output_format = "yaml"
+output_dir = "out"
+ignore = ["node_modules/"]
+generators = {"yaml": ["yq", "-P"]}
+settings = {"env": "dev"}
+matrix = {"env": _environments, "region": ["us", "eu"]}
+min_starfig_version = "0.2.0"
+schema_policy = {"made-optional": "breaking"}
+
+universe(name = "platform", path = "../platform")
+`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Config{
+		OutputFormat: "yaml",
+		OutputDir:    "out",
+		Ignore:       []string{"node_modules/"},
+		Generators:   map[string][]string{"yaml": {"yq", "-P"}},
+		Settings:     map[string]string{"env": "dev"},
+		Matrix: []MatrixAxis{
+			{Key: "env", Values: []string{"dev", "prod"}},
+			{Key: "region", Values: []string{"us", "eu"}},
+		},
+		MinStarfigVersion: "0.2.0",
+		Universes:         []UniverseConfig{{Name: "platform", Path: "../platform"}},
+		SchemaPolicy:      map[string]string{"made-optional": "breaking"},
+	}, config)
+}
+
+func TestLoadConfigUnknownSetting(t *testing.T) {
+	_, err := LoadConfig(writeStarverse(t, `output = "json"`))
+
+	assert.ErrorContains(t, err, "Unknown setting output.")
+}
+
+func TestLoadConfigSyntaxError(t *testing.T) {
+	_, err := LoadConfig(writeStarverse(t, `output_format = `))
+
+	assert.ErrorContains(t, err, "Unable to evaluate")
+}
+
+func TestLoadConfigInvalidTypes(t *testing.T) {
+	for _, testCase := range []struct {
+		source   string
+		expected string
+	}{
+		{`output_format = 416`, "Expected output_format to be a string, but got 416."},
+		{`output_dir = ["out"]`, `Expected output_dir to be a string, but got ["out"].`},
+		{`ignore = "node_modules"`, `Expected ignore to be a list of strings, but got "node_modules".`},
+		{`generators = ["yq"]`, `Expected generators to be a dictionary, but got ["yq"].`},
+		{`generators = {"yaml": "yq"}`, `Expected generators[yaml] to be a list of strings, but got "yq".`},
+		{`generators = {"yaml": []}`, "Expected generators[yaml] to be a command, but got an empty list."},
+		{`settings = {"env": 416}`, "Expected settings[env] to be a string, but got 416."},
+		{`settings = {416: "prod"}`, "Expected the keys of settings to be strings, but got 416."},
+		{`schema_policy = {"made-optional": True}`, "Expected schema_policy[made-optional] to be a string, but got True."},
+		{`matrix = {"env": [416]}`, "Expected matrix[env] to be a list of strings, but got [416]."},
+		{`universe("platform")`, "universe only takes keyword arguments."},
+		{`universe(name = "platform", path = 416)`, "Expected path to be a string, but got 416."},
+		{`universe(name = "platform", url = "")`, "Unknown keyword url in universe()."},
+		{`universe(name = "@platform", path = "p")`, `Expected universe name to only contain letters, numbers, _ and -, but got "@platform".`},
+		{`universe(name = "platform")`, "Universe @platform requires either a path or an archive."},
+		{`universe(name = "platform", path = "p", archive = "p.tgz")`, "Universe @platform requires either a path or an archive."},
+		{`universe(name = "platform", archive = "p.tgz")`, "Universe @platform requires the sha256 of its archive."},
+		{`universe(name = "platform", path = "p", sha256 = "abc")`, "Universe @platform can only have a sha256 and strip_prefix with an archive."},
+		{"universe(name = \"platform\", path = \"p\")\nuniverse(name = \"platform\", path = \"q\")", "Universe @platform is declared more than once."},
+	} {
+		_, err := LoadConfig(writeStarverse(t, testCase.source))
+		assert.ErrorContains(t, err, testCase.expected)
+	}
+}
+
+func TestLoadConfigUnknownOutputFormat(t *testing.T) {
+	_, err := LoadConfig(writeStarverse(t, `output_format = "yaml"`))
+
+	assert.ErrorContains(t, err,
+		"Unknown output_format yaml. It must be json or the name of a generator.")
+}
+
+func TestLoadConfigInvalidMinStarfigVersion(t *testing.T) {
+	_, err := LoadConfig(writeStarverse(t, `min_starfig_version = "latest"`))
+
+	assert.ErrorContains(t, err,
+		"Expected min_starfig_version to be a version like 1.2.3, but got latest.")
+}
+
+func TestConfigCheckVersion(t *testing.T) {
+	config := Config{MinStarfigVersion: "0.2.0"}
+
+	assert.Nil(t, config.CheckVersion("0.2.0"))
+	assert.Nil(t, config.CheckVersion("v0.10.1"))
+	assert.Nil(t, config.CheckVersion("1.0.0"))
+	assert.Nil(t, config.CheckVersion("dev"))
+	assert.ErrorContains(t, config.CheckVersion("0.1.9"),
+		"This universe requires starfig 0.2.0 or newer, but the current version is 0.1.9.")
+	assert.ErrorContains(t, config.CheckVersion("nightly"),
+		"Unable to compare the starfig version nightly.")
+	assert.Nil(t, Config{}.CheckVersion("0.0.1"))
+}
+
+// MARK: - Helpers
+
+func writeStarverse(t *testing.T, source string) string {
+	starverseDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(starverseDir, StarverseFilename), []byte(source), 0644)
+	assert.Nil(t, err)
+	return starverseDir
+}
@@ -0,0 +1,155 @@
+package starverse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const LockFilename string = "STARVERSE.lock"
+
+// Lock records the content of each external universe, so every build uses exactly the same
+// schemas. It is written with `starfig deps update`.
+type Lock struct {
+	Universes map[string]LockedUniverse `json:"universes"`
+}
+
+type LockedUniverse struct {
+	// The path or archive the universe was resolved from.
+	Source string `json:"source"`
+	// The hash of every .star and STARFIG file in the universe.
+	SHA256 string `json:"sha256"`
+}
+
+// NewLock hashes each resolved universe.
+func NewLock(universes []UniverseConfig, resolved map[string]string) (Lock, error) {
+	lock := Lock{Universes: map[string]LockedUniverse{}}
+	for _, universe := range universes {
+		hash, err := HashUniverse(resolved[universe.Name])
+		if err != nil {
+			return lock, fmt.Errorf("Unable to hash universe @%s: %s", universe.Name, err)
+		}
+		lock.Universes[universe.Name] = LockedUniverse{Source: universe.source(), SHA256: hash}
+	}
+	return lock, nil
+}
+
+// ReadLock reads the lock file of the universe, which may not exist.
+func ReadLock(starverseDir string) (Lock, bool, error) {
+	lock := Lock{Universes: map[string]LockedUniverse{}}
+	content, err := os.ReadFile(filepath.Join(starverseDir, LockFilename))
+	if os.IsNotExist(err) {
+		return lock, false, nil
+	} else if err != nil {
+		return lock, false, err
+	}
+
+	err = json.Unmarshal(content, &lock)
+	if err != nil {
+		return lock, true, fmt.Errorf("Invalid %s: %s", LockFilename, err)
+	}
+	if lock.Universes == nil {
+		lock.Universes = map[string]LockedUniverse{}
+	}
+	return lock, true, nil
+}
+
+func (lock Lock) Write(starverseDir string) error {
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(starverseDir, LockFilename), append(content, '\n'), 0644)
+}
+
+// CheckLock ensures the resolved universes match the lock file. A universe without any
+// external universes doesn't need a lock file.
+func CheckLock(starverseDir string, universes []UniverseConfig, resolved map[string]string) error {
+	lock, found, err := ReadLock(starverseDir)
+	if err != nil {
+		return err
+	} else if !found && len(universes) == 0 {
+		return nil
+	} else if !found {
+		return fmt.Errorf("Missing %s. Run `starfig deps update` to create it.", LockFilename)
+	}
+
+	current, err := NewLock(universes, resolved)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range lock.Universes {
+		names = append(names, name)
+	}
+	for name := range current.Universes {
+		if _, found := lock.Universes[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		locked, isLocked := lock.Universes[name]
+		actual, isDeclared := current.Universes[name]
+		if !isDeclared {
+			return fmt.Errorf(
+				"Universe @%s is in %s but not declared in STARVERSE. Run `starfig deps update` to update it.",
+				name, LockFilename)
+		} else if !isLocked {
+			return fmt.Errorf(
+				"Universe @%s is not in %s. Run `starfig deps update` to update it.", name, LockFilename)
+		} else if locked != actual {
+			return fmt.Errorf(
+				"Universe @%s does not match %s, expected %s from %s but got %s from %s. Run `starfig deps update` if this is expected.",
+				name, LockFilename, locked.SHA256, locked.Source, actual.SHA256, actual.Source)
+		}
+	}
+	return nil
+}
+
+// HashUniverse hashes the path and content of every .star and STARFIG file in the directory.
+func HashUniverse(dir string) (string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".star") || entry.Name() == "STARFIG") {
+			relativePath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, filepath.ToSlash(relativePath))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		fileHash, err := HashFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s %s\n", fileHash, path)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (universe UniverseConfig) source() string {
+	if universe.Path != "" {
+		return universe.Path
+	}
+	return fmt.Sprintf("%s@sha256:%s", universe.Archive, universe.SHA256)
+}
@@ -0,0 +1,102 @@
+package starverse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashUniverse(t *testing.T) {
+	dir := makeExternalUniverse(t)
+	hash, err := HashUniverse(dir)
+	assert.Nil(t, err)
+
+	// Files other than .star and STARFIG files are not hashed.
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Platform"), 0644))
+	sameHash, err := HashUniverse(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, hash, sameHash)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "schemas", "team.star"), []byte("Team = Schema()"), 0644))
+	changedHash, err := HashUniverse(dir)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, changedHash)
+}
+
+func TestLockWriteAndRead(t *testing.T) {
+	starverseDir := t.TempDir()
+	lock := Lock{Universes: map[string]LockedUniverse{
+		"platform": {Source: "../platform", SHA256: "abc"},
+	}}
+	assert.Nil(t, lock.Write(starverseDir))
+
+	readLock, found, err := ReadLock(starverseDir)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, lock, readLock)
+}
+
+func TestReadLockMissing(t *testing.T) {
+	_, found, err := ReadLock(t.TempDir())
+
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestCheckLock(t *testing.T) {
+	starverseDir := t.TempDir()
+	universes := []UniverseConfig{{Name: "platform", Path: "platform"}}
+	resolved := map[string]string{"platform": makeExternalUniverse(t)}
+	lock, err := NewLock(universes, resolved)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.Write(starverseDir))
+
+	assert.Nil(t, CheckLock(starverseDir, universes, resolved))
+
+	assert.Nil(t, os.WriteFile(
+		filepath.Join(resolved["platform"], "schemas", "team.star"), []byte("Team = Schema()"), 0644))
+	assert.ErrorContains(t, CheckLock(starverseDir, universes, resolved),
+		"Universe @platform does not match STARVERSE.lock")
+}
+
+func TestCheckLockMissing(t *testing.T) {
+	starverseDir := t.TempDir()
+	universes := []UniverseConfig{{Name: "platform", Path: "platform"}}
+	resolved := map[string]string{"platform": makeExternalUniverse(t)}
+
+	assert.Nil(t, CheckLock(starverseDir, []UniverseConfig{}, map[string]string{}))
+	assert.ErrorContains(t, CheckLock(starverseDir, universes, resolved),
+		"Missing STARVERSE.lock. Run `starfig deps update` to create it.")
+}
+
+func TestCheckLockUndeclaredAndUnlocked(t *testing.T) {
+	starverseDir := t.TempDir()
+	lock := Lock{Universes: map[string]LockedUniverse{"alerts": {Source: "alerts", SHA256: "abc"}}}
+	assert.Nil(t, lock.Write(starverseDir))
+
+	assert.ErrorContains(t, CheckLock(starverseDir, []UniverseConfig{}, map[string]string{}),
+		"Universe @alerts is in STARVERSE.lock but not declared in STARVERSE.")
+
+	universes := []UniverseConfig{
+		{Name: "alerts", Path: "alerts"},
+		{Name: "platform", Path: "platform"},
+	}
+	resolved := map[string]string{"alerts": makeExternalUniverse(t), "platform": makeExternalUniverse(t)}
+	lock, err := NewLock(universes[:1], resolved)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.Write(starverseDir))
+	assert.ErrorContains(t, CheckLock(starverseDir, universes, resolved),
+		"Universe @platform is not in STARVERSE.lock.")
+}
+
+// MARK: - Helpers
+
+func makeExternalUniverse(t *testing.T) string {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "schemas"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, StarverseFilename), []byte{}, 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "schemas", "team.star"), []byte("Team = Schema(fields = {})"), 0644))
+	return dir
+}
@@ -0,0 +1,251 @@
+package starverse
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jathu/starfig/internal/util"
+	"go.starlark.net/starlark"
+)
+
+const UniversesThreadKey string = "starfig-universes"
+
+var universeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// An external universe, which is loaded with @name//pkg/file.star. It is either a directory,
+// i.e. a sibling checkout or a vendored directory, or a local tarball with its checksum.
+type UniverseConfig struct {
+	Name string
+	// A directory, relative to the root of the universe.
+	Path string
+	// A .tar.gz, .tgz or .tar archive, relative to the root of the universe.
+	Archive string
+	SHA256  string
+	// A directory prefix to remove from the archive, i.e. platform-1.2.0
+	StripPrefix string
+}
+
+func parseUniverse(args starlark.Tuple, kwargs []starlark.Tuple) (UniverseConfig, error) {
+	universe := UniverseConfig{}
+	if args.Len() > 0 {
+		return universe, fmt.Errorf(
+			"universe only takes keyword arguments. i.e. universe(name = \"platform\", path = \"../platform\").")
+	}
+
+	for _, kwarg := range kwargs {
+		name := kwarg.Index(0).(starlark.String).GoString()
+		value, err := toString(name, kwarg.Index(1))
+		if err != nil {
+			return universe, err
+		}
+		switch name {
+		case "name":
+			universe.Name = value
+		case "path":
+			universe.Path = value
+		case "archive":
+			universe.Archive = value
+		case "sha256":
+			universe.SHA256 = strings.ToLower(value)
+		case "strip_prefix":
+			universe.StripPrefix = value
+		default:
+			return universe, fmt.Errorf("Unknown keyword %s in universe().", name)
+		}
+	}
+
+	if !universeNamePattern.MatchString(universe.Name) {
+		return universe, fmt.Errorf(
+			"Expected universe name to only contain letters, numbers, _ and -, but got \"%s\".", universe.Name)
+	} else if (universe.Path == "") == (universe.Archive == "") {
+		return universe, fmt.Errorf("Universe @%s requires either a path or an archive.", universe.Name)
+	} else if universe.Archive != "" && universe.SHA256 == "" {
+		return universe, fmt.Errorf("Universe @%s requires the sha256 of its archive.", universe.Name)
+	} else if universe.Archive == "" && (universe.SHA256 != "" || universe.StripPrefix != "") {
+		return universe, fmt.Errorf(
+			"Universe @%s can only have a sha256 and strip_prefix with an archive.", universe.Name)
+	}
+	return universe, nil
+}
+
+// ResolveUniverses returns the root directory of each external universe by name. Archives are
+// verified against their checksum and extracted into the user cache, so resolution never
+// needs the network.
+func ResolveUniverses(starverseDir string, universes []UniverseConfig) (map[string]string, error) {
+	return resolveUniverses(universes, func(rawPath string) string {
+		return resolvePath(starverseDir, rawPath)
+	})
+}
+
+// ResolveCheckoutUniverses resolves the universes of a revision checked out to another
+// directory, i.e. a git worktree at checkoutRoot. The checkout only has the files of its
+// repository, so a path or archive outside of it, i.e. a sibling checkout at ../platform, is
+// resolved against the original universe instead.
+func ResolveCheckoutUniverses(
+	universes []UniverseConfig, checkoutRoot string, checkoutDir string, originalDir string) (map[string]string, error) {
+	return resolveUniverses(universes, func(rawPath string) string {
+		resolved := resolvePath(checkoutDir, rawPath)
+		if !isWithin(resolved, checkoutRoot) {
+			return resolvePath(originalDir, rawPath)
+		}
+		return resolved
+	})
+}
+
+func resolveUniverses(universes []UniverseConfig, resolve func(rawPath string) string) (map[string]string, error) {
+	resolved := map[string]string{}
+	for _, universe := range universes {
+		var dir string
+		var err error
+		if universe.Path != "" {
+			dir = resolve(universe.Path)
+			if !util.PathExists(dir) {
+				err = fmt.Errorf("The path %s does not exist.", dir)
+			}
+		} else {
+			dir, err = extractArchive(resolve(universe.Archive), universe)
+		}
+		if err != nil {
+			return resolved, fmt.Errorf("Unable to resolve universe @%s: %s", universe.Name, err)
+		}
+		resolved[universe.Name] = dir
+	}
+	return resolved, nil
+}
+
+func isWithin(path string, dir string) bool {
+	relativePath, err := filepath.Rel(dir, path)
+	relativePath = filepath.ToSlash(relativePath)
+	return err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, "../")
+}
+
+func resolvePath(starverseDir string, rawPath string) string {
+	if filepath.IsAbs(rawPath) {
+		return filepath.Clean(rawPath)
+	}
+	return filepath.Join(starverseDir, rawPath)
+}
+
+// HashFile returns the hex encoded sha256 of a file.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func extractArchive(archivePath string, universe UniverseConfig) (string, error) {
+	checksum, err := HashFile(archivePath)
+	if err != nil {
+		return "", err
+	} else if checksum != universe.SHA256 {
+		return "", fmt.Errorf("Checksum mismatch for %s, expected %s but got %s.",
+			universe.Archive, universe.SHA256, checksum)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	extractedDir := filepath.Join(cacheDir, "starfig", "universes", checksum)
+	rootDir := filepath.Join(extractedDir, filepath.FromSlash(universe.StripPrefix))
+	if util.PathExists(extractedDir) {
+		return rootDir, nil
+	}
+
+	// Extract into a temporary directory first, so an interrupted extraction is never used.
+	err = os.MkdirAll(filepath.Dir(extractedDir), 0755)
+	if err != nil {
+		return "", err
+	}
+	tempDir, err := os.MkdirTemp(filepath.Dir(extractedDir), "extract-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+
+	err = untar(archivePath, tempDir)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tempDir, extractedDir)
+	if err != nil && !util.PathExists(extractedDir) {
+		return "", err
+	}
+	return rootDir, nil
+}
+
+func untar(archivePath string, destination string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(archivePath, ".gz") || strings.HasSuffix(archivePath, ".tgz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("The archive entry %s is outside the archive.", header.Name)
+		}
+		target := filepath.Join(destination, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			err = writeArchiveFile(target, tarReader)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeArchiveFile(target string, reader io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	return err
+}
@@ -0,0 +1,141 @@
+package starverse
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveUniversesPath(t *testing.T) {
+	starverseDir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(starverseDir, "third_party", "platform"), 0755))
+	universes, err := ResolveUniverses(starverseDir, []UniverseConfig{
+		{Name: "platform", Path: "third_party/platform"},
+		{Name: "absolute", Path: starverseDir},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"platform": filepath.Join(starverseDir, "third_party", "platform"),
+		"absolute": starverseDir,
+	}, universes)
+}
+
+func TestResolveCheckoutUniverses(t *testing.T) {
+	// The universe is at repo/app, next to a sibling checkout of platform, and the revision is
+	// checked out to a worktree of repo.
+	originalDir := filepath.Join(t.TempDir(), "repo", "app")
+	checkoutRoot := t.TempDir()
+	checkoutDir := filepath.Join(checkoutRoot, "app")
+	platformDir := filepath.Join(filepath.Dir(filepath.Dir(originalDir)), "platform")
+	assert.Nil(t, os.MkdirAll(platformDir, 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(checkoutDir, "third_party", "vendored"), 0755))
+
+	universes, err := ResolveCheckoutUniverses([]UniverseConfig{
+		{Name: "platform", Path: "../../platform"},
+		{Name: "vendored", Path: "third_party/vendored"},
+		{Name: "absolute", Path: platformDir},
+	}, checkoutRoot, checkoutDir, originalDir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"platform": platformDir,
+		"vendored": filepath.Join(checkoutDir, "third_party", "vendored"),
+		"absolute": platformDir,
+	}, universes)
+}
+
+func TestResolveUniversesMissingPath(t *testing.T) {
+	starverseDir := t.TempDir()
+	_, err := ResolveUniverses(starverseDir, []UniverseConfig{{Name: "platform", Path: "platform"}})
+
+	assert.ErrorContains(t, err, "Unable to resolve universe @platform: The path")
+}
+
+func TestResolveUniversesArchive(t *testing.T) {
+	setCacheDir(t)
+	starverseDir := t.TempDir()
+	archivePath := writeArchive(t, starverseDir, map[string]string{
+		"platform-1.0.0/STARVERSE":            "",
+		"platform-1.0.0/schemas/service.star": "Service = Schema()",
+	})
+	checksum, err := HashFile(archivePath)
+	assert.Nil(t, err)
+
+	universe := UniverseConfig{
+		Name:        "platform",
+		Archive:     "platform.tar.gz",
+		SHA256:      checksum,
+		StripPrefix: "platform-1.0.0",
+	}
+	universes, err := ResolveUniverses(starverseDir, []UniverseConfig{universe})
+	assert.Nil(t, err)
+	content, err := os.ReadFile(filepath.Join(universes["platform"], "schemas", "service.star"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Service = Schema()", string(content))
+
+	// The extracted archive is reused.
+	cachedUniverses, err := ResolveUniverses(starverseDir, []UniverseConfig{universe})
+	assert.Nil(t, err)
+	assert.Equal(t, universes, cachedUniverses)
+}
+
+func TestResolveUniversesArchiveChecksumMismatch(t *testing.T) {
+	setCacheDir(t)
+	starverseDir := t.TempDir()
+	writeArchive(t, starverseDir, map[string]string{"STARVERSE": ""})
+	_, err := ResolveUniverses(starverseDir, []UniverseConfig{
+		{Name: "platform", Archive: "platform.tar.gz", SHA256: "abc"},
+	})
+
+	assert.ErrorContains(t, err,
+		"Unable to resolve universe @platform: Checksum mismatch for platform.tar.gz, expected abc but got")
+}
+
+func TestResolveUniversesArchiveOutsideEntry(t *testing.T) {
+	setCacheDir(t)
+	starverseDir := t.TempDir()
+	archivePath := writeArchive(t, starverseDir, map[string]string{"../escape.star": ""})
+	checksum, err := HashFile(archivePath)
+	assert.Nil(t, err)
+	_, err = ResolveUniverses(starverseDir, []UniverseConfig{
+		{Name: "platform", Archive: "platform.tar.gz", SHA256: checksum},
+	})
+
+	assert.ErrorContains(t, err, "The archive entry ../escape.star is outside the archive.")
+}
+
+// MARK: - Helpers
+
+func setCacheDir(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	t.Setenv("HOME", cacheDir)
+}
+
+func writeArchive(t *testing.T, dir string, files map[string]string) string {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		assert.Nil(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tarWriter.Close())
+	assert.Nil(t, gzipWriter.Close())
+
+	archivePath := filepath.Join(dir, "platform.tar.gz")
+	assert.Nil(t, os.WriteFile(archivePath, buffer.Bytes(), 0644))
+	return archivePath
+}
@@ -3,7 +3,9 @@ package target
 import (
 	"fmt"
 	"io/fs"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/jathu/starfig/internal/util"
@@ -26,73 +28,246 @@ func (target BuildTarget) Path() string {
 	return filepath.Join(target.StarverseDir, target.Package, StarfigFilename)
 }
 
+// StarfigFile is the STARFIG file the build target is defined in.
+func (target BuildTarget) StarfigFile() FileTarget {
+	return FileTarget{
+		StarverseDir: target.StarverseDir,
+		Package:      target.Package,
+		Filename:     StarfigFilename,
+	}
+}
+
+// ParseBuildTarget parses an absolute target pattern:
+//   - //pkg:name is a single target
+//   - //pkg:..., //pkg:all and //pkg are every target in the package
+//   - //pkg/... is every target in the package and its subpackages
+//   - //pkg/*:name matches packages with a glob, where * is a single directory and ** is any
+//
+// The ignore patterns only apply to the directories found when searching for packages, so an
+// ignored package can still be built directly.
 func ParseBuildTarget(
-	starverseDir string, rawTargetInput string) ([]BuildTarget, error) {
+	starverseDir string, rawTargetInput string, ignore Ignore) ([]BuildTarget, error) {
 	if !strings.HasPrefix(rawTargetInput, "//") {
 		return []BuildTarget{}, fmt.Errorf(
 			`"%s" is invalid because a target must start with //.`, rawTargetInput)
 	}
 
-	rawTargets := []string{}
-	if strings.HasSuffix(rawTargetInput, "...") {
-		searchDir := filepath.Join(starverseDir, rawTargetInput[:len(rawTargetInput)-3])
-		packages, err := findStarfigPackages(starverseDir, searchDir)
+	rawTarget := rawTargetInput[2:]
+	packagePattern, targetName := rawTarget, "..."
+	if strings.Count(rawTarget, ":") > 1 {
+		return []BuildTarget{}, fmt.Errorf("Invalid target %s.", rawTarget)
+	} else if strings.Contains(rawTarget, ":") {
+		colonIndex := strings.Index(rawTarget, ":")
+		packagePattern, targetName = rawTarget[:colonIndex], rawTarget[colonIndex+1:]
+	}
+	for _, segment := range strings.Split(packagePattern, "/") {
+		if segment == ".." {
+			return []BuildTarget{}, fmt.Errorf(
+				`"%s" is invalid because it is outside the universe.`, rawTargetInput)
+		}
+	}
+	if targetName == "" || strings.Contains(targetName, "/") {
+		return []BuildTarget{}, fmt.Errorf("Invalid target %s.", rawTarget)
+	} else if targetName == "all" {
+		targetName = "..."
+	}
+
+	packages := []string{}
+	if packagePattern == "..." || strings.HasSuffix(packagePattern, "/...") {
+		searchDir := filepath.Join(starverseDir, strings.TrimSuffix(packagePattern, "..."))
+		found, err := findStarfigPackages(starverseDir, searchDir, ignore)
 		if err != nil {
 			return []BuildTarget{}, err
 		}
-		rawTargets = append(rawTargets, packages...)
+		packages = append(packages, found...)
+	} else if strings.ContainsAny(packagePattern, "*?[") {
+		packageRegex, err := regexp.Compile(fmt.Sprintf("^%s$", globToRegex(packagePattern)))
+		if err != nil {
+			return []BuildTarget{}, fmt.Errorf("Invalid target %s.", rawTarget)
+		}
+		found, err := findStarfigPackages(starverseDir, starverseDir, ignore)
+		if err != nil {
+			return []BuildTarget{}, err
+		}
+		for _, pkg := range found {
+			if packageRegex.MatchString(pkg) {
+				packages = append(packages, pkg)
+			}
+		}
 	} else {
-		rawTargets = append(rawTargets, rawTargetInput)
+		packages = append(packages, strings.TrimSuffix(packagePattern, "/"))
 	}
 
 	targets := []BuildTarget{}
-	for _, rawTarget := range rawTargets {
-		rawTarget = rawTarget[2:]
-		var colon_index, colon_count int
-		for i, letter_ord := range rawTarget {
-			if string(letter_ord) == ":" {
-				colon_index = i
-				colon_count += 1
-			}
+	for _, pkg := range packages {
+		target := BuildTarget{starverseDir, pkg, targetName}
+		if util.PathExists(target.Path()) == false {
+			return []BuildTarget{}, fmt.Errorf(
+				"%s file for %s does not exist.", StarfigFilename, target.Target())
 		}
+		targets = append(targets, target)
+	}
 
-		if colon_count != 1 {
-			return []BuildTarget{}, fmt.Errorf("Invalid target %s.", rawTarget)
+	return targets, nil
+}
+
+// BuildTargetPatterns are the targets matched by a list of patterns, where a pattern starting
+// with a dash excludes the targets it matches from the patterns before it. Patterns apply in
+// order, so a later pattern can include an excluded target again. i.e. //... -//web/... //web:api
+type BuildTargetPatterns struct {
+	Targets []BuildTarget
+	// The targets excluded by the patterns after each target, by the index of the target.
+	Excluded [][]BuildTarget
+}
+
+// IsExcluded reports if a target matched by the target at the index was excluded by a later
+// pattern, either directly or by its whole package.
+func (patterns BuildTargetPatterns) IsExcluded(index int, target BuildTarget) bool {
+	for _, excluded := range patterns.Excluded[index] {
+		if excludes(excluded, target) {
+			return true
 		}
+	}
+	return false
+}
 
-		target := BuildTarget{starverseDir, "", ""}
-		target.Package = rawTarget[:colon_index]
-		target.TargetName = rawTarget[colon_index+1:]
+func excludes(excluded BuildTarget, target BuildTarget) bool {
+	return excluded.Package == target.Package &&
+		(excluded.TargetName == "..." || excluded.TargetName == target.TargetName)
+}
 
-		if util.PathExists(target.Path()) == false {
-			return []BuildTarget{}, fmt.Errorf(
-				"%s file for %s does not exist.", StarfigFilename, target.Target())
+type PatternError struct {
+	Pattern string
+	Err     error
+}
+
+func (err PatternError) Error() string {
+	return err.Err.Error()
+}
+
+// ParseBuildTargets parses the patterns in order. Patterns that don't start with // are
+// relative to the package of the working directory. i.e. :name or sub/... Invalid patterns
+// are skipped and returned as errors, so the remaining patterns can still be built.
+func ParseBuildTargets(
+	starverseDir string, workingDir string, rawPatterns []string, ignore Ignore) (BuildTargetPatterns, []PatternError) {
+	patterns := BuildTargetPatterns{Targets: []BuildTarget{}, Excluded: [][]BuildTarget{}}
+	errs := []PatternError{}
+
+	for _, rawPattern := range rawPatterns {
+		exclude := strings.HasPrefix(rawPattern, "-")
+		absolutePattern, err := absoluteTargetPattern(
+			starverseDir, workingDir, strings.TrimPrefix(rawPattern, "-"))
+		if err != nil {
+			errs = append(errs, PatternError{Pattern: rawPattern, Err: err})
+			continue
+		}
+		targets, err := ParseBuildTarget(starverseDir, absolutePattern, ignore)
+		if err != nil {
+			errs = append(errs, PatternError{Pattern: rawPattern, Err: err})
+			continue
 		}
 
-		targets = append(targets, target)
+		if exclude {
+			// Targets excluded as a whole are dropped, and the others are filtered once they are
+			// evaluated.
+			kept := BuildTargetPatterns{Targets: []BuildTarget{}, Excluded: [][]BuildTarget{}}
+			for i, target := range patterns.Targets {
+				whole := false
+				for _, excluded := range targets {
+					whole = whole || excludes(excluded, target)
+				}
+				if !whole {
+					excluded := append(append([]BuildTarget{}, patterns.Excluded[i]...), targets...)
+					kept.Targets = append(kept.Targets, target)
+					kept.Excluded = append(kept.Excluded, excluded)
+				}
+			}
+			patterns = kept
+		} else {
+			for _, target := range targets {
+				patterns.Targets = append(patterns.Targets, target)
+				patterns.Excluded = append(patterns.Excluded, []BuildTarget{})
+			}
+		}
 	}
 
-	return targets, nil
+	return patterns, errs
 }
 
-func findStarfigPackages(starverseDir string, searchRoot string) ([]string, error) {
-	logrus.Debugf("searching for packages in %s", searchRoot)
+func absoluteTargetPattern(starverseDir string, workingDir string, rawPattern string) (string, error) {
+	if strings.HasPrefix(rawPattern, "//") {
+		return rawPattern, nil
+	}
+
+	workingPackage, err := filepath.Rel(starverseDir, workingDir)
+	if err != nil || workingPackage == ".." || strings.HasPrefix(workingPackage, "../") {
+		return "", fmt.Errorf(
+			`"%s" is invalid because the working directory is outside the universe.`, rawPattern)
+	}
+	workingPackage = filepath.ToSlash(workingPackage)
+	if workingPackage == "." {
+		workingPackage = ""
+	}
+
+	if strings.HasPrefix(rawPattern, ":") {
+		return fmt.Sprintf("//%s%s", workingPackage, rawPattern), nil
+	}
+	joined := path.Join(workingPackage, rawPattern)
+	if joined == ".." || strings.HasPrefix(joined, "../") {
+		return "", fmt.Errorf(`"%s" is invalid because it is outside the universe.`, rawPattern)
+	}
+	return fmt.Sprintf("//%s", joined), nil
+}
+
+// Returns the packages within the search root, relative to the universe root.
+func findStarfigPackages(starverseDir string, searchRoot string, ignore Ignore) ([]string, error) {
+	files, err := findFiles(starverseDir, searchRoot, ignore, func(name string) bool {
+		return name == StarfigFilename
+	})
 
 	packages := []string{}
+	for _, file := range files {
+		pkg := path.Dir(file)
+		if pkg == "." {
+			pkg = ""
+		}
+		logrus.Debugf("found package: %s", pkg)
+		packages = append(packages, pkg)
+	}
+	return packages, err
+}
+
+// Returns the files within the search root with a matching name, relative to the universe root.
+func findFiles(
+	starverseDir string, searchRoot string, ignore Ignore, match func(name string) bool) ([]string, error) {
+	logrus.Debugf("searching for files in %s", searchRoot)
+
+	files := []string{}
 	err := filepath.WalkDir(searchRoot, func(path string, entry fs.DirEntry, e error) error {
 		if e != nil {
 			return e
 		}
-		if !entry.IsDir() && entry.Name() == StarfigFilename {
-			rawTarget, err := filepath.Rel(starverseDir, filepath.Dir(path))
-			if err != nil {
-				return err
+
+		relativePath, err := filepath.Rel(starverseDir, path)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		// Ignored directories are pruned rather than walked, which keeps large trees like
+		// node_modules from slowing down the search.
+		if path != searchRoot && ignore.Match(relativePath, entry.IsDir()) {
+			logrus.Debugf("ignoring %s", relativePath)
+			if entry.IsDir() {
+				return filepath.SkipDir
 			}
-			logrus.Debugf("found package: %s", rawTarget)
-			packages = append(packages, fmt.Sprintf("//%s:...", rawTarget))
+			return nil
+		}
+
+		if !entry.IsDir() && match(entry.Name()) {
+			files = append(files, relativePath)
 		}
 		return nil
 	})
 
-	return packages, err
+	return files, err
 }
@@ -0,0 +1,154 @@
+package target
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBuildTarget(t *testing.T) {
+	starverseDir := makeUniverse(t, "web", "services/api", "services/auth", "experimental/services/beta")
+
+	for _, testCase := range []struct {
+		pattern  string
+		expected []BuildTarget
+	}{
+		{"//web:config", []BuildTarget{{starverseDir, "web", "config"}}},
+		{"//web:...", []BuildTarget{{starverseDir, "web", "..."}}},
+		{"//web:all", []BuildTarget{{starverseDir, "web", "..."}}},
+		{"//web", []BuildTarget{{starverseDir, "web", "..."}}},
+		{"//services/...", []BuildTarget{
+			{starverseDir, "services/api", "..."},
+			{starverseDir, "services/auth", "..."},
+		}},
+		{"//services/*:config", []BuildTarget{
+			{starverseDir, "services/api", "config"},
+			{starverseDir, "services/auth", "config"},
+		}},
+		{"//**/services/b*", []BuildTarget{
+			{starverseDir, "experimental/services/beta", "..."},
+		}},
+	} {
+		targets, err := ParseBuildTarget(starverseDir, testCase.pattern, Ignore{})
+		assert.Nil(t, err, testCase.pattern)
+		assert.Equal(t, testCase.expected, targets, testCase.pattern)
+	}
+}
+
+func TestParseBuildTargetErrors(t *testing.T) {
+	starverseDir := makeUniverse(t, "web")
+
+	for _, testCase := range []struct {
+		pattern  string
+		expected string
+	}{
+		{"web:config", `"web:config" is invalid because a target must start with //.`},
+		{"//web:config:extra", "Invalid target web:config:extra."},
+		{"//web:", "Invalid target web:."},
+		{"//mobile:config", "STARFIG file for //mobile:config does not exist."},
+		{"//web/../..:config", `"//web/../..:config" is invalid because it is outside the universe.`},
+	} {
+		_, err := ParseBuildTarget(starverseDir, testCase.pattern, Ignore{})
+		assert.ErrorContains(t, err, testCase.expected, testCase.pattern)
+	}
+}
+
+func TestParseBuildTargets(t *testing.T) {
+	starverseDir := makeUniverse(t, "web", "services/api", "experimental/beta")
+	patterns, errs := ParseBuildTargets(
+		starverseDir,
+		starverseDir,
+		[]string{"//...", "-//experimental/...", "-//web:secret"},
+		Ignore{},
+	)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, []BuildTarget{
+		{starverseDir, "services/api", "..."},
+		{starverseDir, "web", "..."},
+	}, patterns.Targets)
+	assert.False(t, patterns.IsExcluded(0, BuildTarget{starverseDir, "services/api", "config"}))
+	assert.True(t, patterns.IsExcluded(1, BuildTarget{starverseDir, "web", "secret"}))
+	assert.False(t, patterns.IsExcluded(1, BuildTarget{starverseDir, "web", "config"}))
+}
+
+func TestParseBuildTargetsIncludeAfterExclude(t *testing.T) {
+	starverseDir := makeUniverse(t, "a", "a/c")
+	patterns, errs := ParseBuildTargets(
+		starverseDir,
+		starverseDir,
+		[]string{"-//a/...", "//a:b"},
+		Ignore{},
+	)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, []BuildTarget{{starverseDir, "a", "b"}}, patterns.Targets)
+	assert.False(t, patterns.IsExcluded(0, BuildTarget{starverseDir, "a", "b"}))
+
+	patterns, errs = ParseBuildTargets(
+		starverseDir,
+		starverseDir,
+		[]string{"//a/...", "-//a:b", "//a:b"},
+		Ignore{},
+	)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, []BuildTarget{
+		{starverseDir, "a", "..."},
+		{starverseDir, "a/c", "..."},
+		{starverseDir, "a", "b"},
+	}, patterns.Targets)
+	assert.True(t, patterns.IsExcluded(0, BuildTarget{starverseDir, "a", "b"}))
+	assert.False(t, patterns.IsExcluded(0, BuildTarget{starverseDir, "a", "d"}))
+	assert.False(t, patterns.IsExcluded(2, BuildTarget{starverseDir, "a", "b"}))
+}
+
+func TestParseBuildTargetsRelative(t *testing.T) {
+	starverseDir := makeUniverse(t, "services", "services/api")
+	patterns, errs := ParseBuildTargets(
+		starverseDir,
+		filepath.Join(starverseDir, "services"),
+		[]string{":config", "api", "api:all"},
+		Ignore{},
+	)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, []BuildTarget{
+		{starverseDir, "services", "config"},
+		{starverseDir, "services/api", "..."},
+		{starverseDir, "services/api", "..."},
+	}, patterns.Targets)
+}
+
+func TestParseBuildTargetsOutsideUniverse(t *testing.T) {
+	starverseDir := makeUniverse(t, "web")
+	_, errs := ParseBuildTargets(starverseDir, t.TempDir(), []string{":config", "//web"}, Ignore{})
+
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, ":config", errs[0].Pattern)
+	assert.ErrorContains(t, errs[0],
+		`":config" is invalid because the working directory is outside the universe.`)
+}
+
+func TestParseBuildTargetsRelativeOutsideUniverse(t *testing.T) {
+	starverseDir := makeUniverse(t, "web")
+	_, errs := ParseBuildTargets(
+		starverseDir, filepath.Join(starverseDir, "web"), []string{"../..", "../../x:config"}, Ignore{})
+
+	assert.Equal(t, 2, len(errs))
+	assert.ErrorContains(t, errs[0], `"../.." is invalid because it is outside the universe.`)
+	assert.ErrorContains(t, errs[1], `"../../x:config" is invalid because it is outside the universe.`)
+}
+
+// MARK: - Helpers
+
+func makeUniverse(t *testing.T, packages ...string) string {
+	starverseDir := t.TempDir()
+	for _, pkg := range packages {
+		assert.Nil(t, os.MkdirAll(filepath.Join(starverseDir, pkg), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(starverseDir, pkg, StarfigFilename), []byte{}, 0644))
+	}
+	return starverseDir
+}
@@ -2,8 +2,11 @@ package target
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/jathu/starfig/internal/util"
@@ -13,6 +16,8 @@ type FileTarget struct {
 	StarverseDir string
 	Package      string
 	Filename     string
+	// The name of the external universe the file is in, or empty for the current universe.
+	Repository string
 }
 
 func (target FileTarget) String() string {
@@ -28,7 +33,11 @@ func (target FileTarget) Exists() bool {
 }
 
 func (target FileTarget) Target() string {
-	return fmt.Sprintf("//%s", filepath.Join(target.Package, target.Filename))
+	label := fmt.Sprintf("//%s", filepath.Join(target.Package, target.Filename))
+	if target.Repository != "" {
+		return fmt.Sprintf("@%s%s", target.Repository, label)
+	}
+	return label
 }
 
 func (target FileTarget) IsStarFile() bool {
@@ -39,16 +48,218 @@ func (target FileTarget) IsStarFigFile() bool {
 	return target.Filename == StarfigFilename
 }
 
-func ParseFileTarget(starverseDir string, rawLabel string) (FileTarget, error) {
-	target := FileTarget{starverseDir, "", ""}
+var repositoryPattern = regexp.MustCompile(`^@([A-Za-z0-9_\-]+)//`)
+
+// ParseFileTarget parses an absolute label, i.e. //pkg/file.star, or a label relative to the
+// current package, i.e. :file.star or :../other/file.star. Labels that escape the root of the
+// universe are invalid. A label in an external universe, i.e. @platform//pkg/file.star, only
+// has its repository set, since the location of the universe is declared in STARVERSE.
+func ParseFileTarget(starverseDir string, currentPackage string, rawLabel string) (FileTarget, error) {
+	target := FileTarget{starverseDir, "", "", ""}
 
-	if strings.HasPrefix(rawLabel, "//") {
-		rawLabel = rawLabel[2:]
-		target.Package = path.Dir(rawLabel)
-		target.Filename = filepath.Base(rawLabel)
+	var label string
+	if match := repositoryPattern.FindStringSubmatch(rawLabel); match != nil {
+		target.StarverseDir = ""
+		target.Repository = match[1]
+		label = path.Clean(rawLabel[len(match[0]):])
+	} else if strings.HasPrefix(rawLabel, "//") {
+		label = path.Clean(rawLabel[2:])
+	} else if strings.HasPrefix(rawLabel, ":") {
+		label = path.Join(currentPackage, rawLabel[1:])
 	} else {
-		return target, fmt.Errorf("Load source %s is invalid because it must be absolute.", rawLabel)
+		return target, fmt.Errorf(
+			"Load source %s is invalid because it must be absolute, i.e. //pkg/file.star, or relative to the package, i.e. :file.star.", rawLabel)
+	}
+
+	if label == ".." || strings.HasPrefix(label, "../") || path.IsAbs(label) {
+		return target, fmt.Errorf(
+			"Load source %s is invalid because it is outside the universe.", rawLabel)
 	}
 
+	target.Package = path.Dir(label)
+	target.Filename = path.Base(label)
+	return target, nil
+}
+
+// ParseLoadTarget parses the label of a load statement in the loading file. Labels are
+// resolved within the universe of the loading file, and labels in an external universe are
+// located with the root directory of each universe by name.
+func ParseLoadTarget(loadingFile FileTarget, universes map[string]string, rawLabel string) (FileTarget, error) {
+	target, err := ParseFileTarget(loadingFile.StarverseDir, loadingFile.Package, rawLabel)
+	if err != nil {
+		return target, err
+	}
+	if target.Repository == "" {
+		target.Repository = loadingFile.Repository
+		return target, nil
+	}
+
+	dir, found := universes[target.Repository]
+	if !found {
+		return target, fmt.Errorf(
+			"Unknown universe @%s in %s. External universes must be declared in STARVERSE.",
+			target.Repository, rawLabel)
+	}
+	target.StarverseDir = dir
 	return target, nil
 }
+
+// FindStarFiles finds every .star file in the universe, excluding test files and ignored paths.
+func FindStarFiles(starverseDir string, ignore Ignore) ([]FileTarget, error) {
+	files, err := findFiles(starverseDir, starverseDir, ignore, func(name string) bool {
+		return filepath.Ext(name) == ".star" && !IsTestFile(name)
+	})
+	if err != nil {
+		return []FileTarget{}, err
+	}
+
+	targets := []FileTarget{}
+	for _, file := range files {
+		targets = append(targets, FileTarget{
+			StarverseDir: starverseDir,
+			Package:      path.Dir(file),
+			Filename:     path.Base(file),
+		})
+	}
+	return targets, nil
+}
+
+// MARK: - File Target Patterns
+
+// A kind of file matched by file target patterns.
+type fileKind struct {
+	name  string
+	match func(name string) bool
+	// Why a single file target doesn't match.
+	mismatch string
+}
+
+var testFiles = fileKind{
+	name:     "test",
+	match:    IsTestFile,
+	mismatch: fmt.Sprintf("is not a test file. Test files end with %s.", TestFileSuffix),
+}
+
+var sourceFiles = fileKind{
+	name:     "source",
+	match:    IsSourceFile,
+	mismatch: fmt.Sprintf("is not a .star or %s file.", StarfigFilename),
+}
+
+// IsSourceFile reports if the file is written in Starlark, i.e. a .star, test or STARFIG file.
+func IsSourceFile(name string) bool {
+	return filepath.Ext(name) == ".star" || name == StarfigFilename
+}
+
+// ParseSourceTargets finds the .star, test and STARFIG files matched by the patterns, in the
+// same form as test target patterns. i.e. //pkg/... or //pkg:defs.star
+func ParseSourceTargets(
+	starverseDir string, workingDir string, rawPatterns []string, ignore Ignore) ([]FileTarget, []PatternError) {
+	return parseFileTargets(starverseDir, workingDir, rawPatterns, ignore, sourceFiles)
+}
+
+func parseFileTargets(
+	starverseDir string,
+	workingDir string,
+	rawPatterns []string,
+	ignore Ignore,
+	kind fileKind,
+) ([]FileTarget, []PatternError) {
+	targets := []FileTarget{}
+	errs := []PatternError{}
+
+	for _, rawPattern := range rawPatterns {
+		exclude := strings.HasPrefix(rawPattern, "-")
+		absolutePattern, err := absoluteTargetPattern(
+			starverseDir, workingDir, strings.TrimPrefix(rawPattern, "-"))
+		if err != nil {
+			errs = append(errs, PatternError{Pattern: rawPattern, Err: err})
+			continue
+		}
+		matched, err := parseFileTarget(starverseDir, absolutePattern, ignore, kind)
+		if err != nil {
+			errs = append(errs, PatternError{Pattern: rawPattern, Err: err})
+			continue
+		}
+
+		if exclude {
+			excluded := map[string]bool{}
+			for _, target := range matched {
+				excluded[target.Target()] = true
+			}
+			kept := []FileTarget{}
+			for _, target := range targets {
+				if !excluded[target.Target()] {
+					kept = append(kept, target)
+				}
+			}
+			targets = kept
+		} else {
+			targets = append(targets, matched...)
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].Target() < targets[j].Target()
+	})
+	return targets, errs
+}
+
+func parseFileTarget(
+	starverseDir string, rawTargetInput string, ignore Ignore, kind fileKind) ([]FileTarget, error) {
+	rawTarget := strings.TrimPrefix(rawTargetInput, "//")
+	packagePattern, name := rawTarget, "..."
+	if strings.Count(rawTarget, ":") > 1 {
+		return []FileTarget{}, fmt.Errorf("Invalid %s target %s.", kind.name, rawTarget)
+	} else if strings.Contains(rawTarget, ":") {
+		colonIndex := strings.Index(rawTarget, ":")
+		packagePattern, name = rawTarget[:colonIndex], rawTarget[colonIndex+1:]
+	}
+	if name == "all" {
+		name = "..."
+	}
+
+	files := []string{}
+	if packagePattern == "..." || strings.HasSuffix(packagePattern, "/...") {
+		searchDir := filepath.Join(starverseDir, strings.TrimSuffix(packagePattern, "..."))
+		found, err := findFiles(starverseDir, searchDir, ignore, kind.match)
+		if err != nil {
+			return []FileTarget{}, err
+		}
+		files = append(files, found...)
+	} else {
+		packagePattern = strings.TrimSuffix(packagePattern, "/")
+		packageDir := filepath.Join(starverseDir, packagePattern)
+		if !util.PathExists(packageDir) {
+			return []FileTarget{}, fmt.Errorf("Package //%s does not exist.", packagePattern)
+		}
+
+		if name == "..." {
+			entries, err := os.ReadDir(packageDir)
+			if err != nil {
+				return []FileTarget{}, err
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() && kind.match(entry.Name()) {
+					files = append(files, path.Join(packagePattern, entry.Name()))
+				}
+			}
+		} else if !kind.match(name) {
+			return []FileTarget{}, fmt.Errorf("%s %s", rawTargetInput, kind.mismatch)
+		} else if !util.PathExists(filepath.Join(packageDir, name)) {
+			return []FileTarget{}, fmt.Errorf("%s does not exist.", rawTargetInput)
+		} else {
+			files = append(files, path.Join(packagePattern, name))
+		}
+	}
+
+	targets := []FileTarget{}
+	for _, file := range files {
+		targets = append(targets, FileTarget{
+			StarverseDir: starverseDir,
+			Package:      path.Dir(file),
+			Filename:     path.Base(file),
+		})
+	}
+	return targets, nil
+}
@@ -0,0 +1,115 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFileTarget(t *testing.T) {
+	for _, testCase := range []struct {
+		currentPackage string
+		label          string
+		expected       FileTarget
+	}{
+		{"web", "//geography/metadata.star", FileTarget{"/u", "geography", "metadata.star", ""}},
+		{"web", "//geography/../shared/defs.star", FileTarget{"/u", "shared", "defs.star", ""}},
+		{"web", "//defs.star", FileTarget{"/u", ".", "defs.star", ""}},
+		{"geography", ":metadata.star", FileTarget{"/u", "geography", "metadata.star", ""}},
+		{"geography", ":country/STARFIG", FileTarget{"/u", "geography/country", "STARFIG", ""}},
+		{"geography/country", ":../metadata.star", FileTarget{"/u", "geography", "metadata.star", ""}},
+		{"", ":defs.star", FileTarget{"/u", ".", "defs.star", ""}},
+		{"web", "@platform//schemas/service.star", FileTarget{"", "schemas", "service.star", "platform"}},
+	} {
+		fileTarget, err := ParseFileTarget("/u", testCase.currentPackage, testCase.label)
+		assert.Nil(t, err, testCase.label)
+		assert.Equal(t, testCase.expected, fileTarget, testCase.label)
+	}
+}
+
+func TestParseFileTargetInvalid(t *testing.T) {
+	_, err := ParseFileTarget("/u", "web", "metadata.star")
+
+	assert.ErrorContains(t, err,
+		"Load source metadata.star is invalid because it must be absolute, i.e. //pkg/file.star, or relative to the package, i.e. :file.star.")
+}
+
+func TestParseFileTargetOutsideUniverse(t *testing.T) {
+	for _, label := range []string{":../../defs.star", "//../defs.star", "//..", "@platform//../defs.star"} {
+		_, err := ParseFileTarget("/u", "web", label)
+		assert.ErrorContains(t, err,
+			"Load source "+label+" is invalid because it is outside the universe.", label)
+	}
+}
+
+func TestParseLoadTarget(t *testing.T) {
+	universes := map[string]string{"platform": "/platform", "shared": "/shared"}
+	loadingFile := FileTarget{"/platform", "schemas", "service.star", "platform"}
+	for _, testCase := range []struct {
+		label    string
+		expected FileTarget
+	}{
+		{":team.star", FileTarget{"/platform", "schemas", "team.star", "platform"}},
+		{"//team/defs.star", FileTarget{"/platform", "team", "defs.star", "platform"}},
+		{"@shared//defs.star", FileTarget{"/shared", ".", "defs.star", "shared"}},
+	} {
+		fileTarget, err := ParseLoadTarget(loadingFile, universes, testCase.label)
+		assert.Nil(t, err, testCase.label)
+		assert.Equal(t, testCase.expected, fileTarget, testCase.label)
+	}
+}
+
+func TestParseLoadTargetUnknownUniverse(t *testing.T) {
+	_, err := ParseLoadTarget(FileTarget{"/u", "web", "STARFIG", ""}, map[string]string{}, "@platform//defs.star")
+
+	assert.Equal(t, "Unknown universe @platform in @platform//defs.star. External universes must be declared in STARVERSE.",
+		err.Error())
+}
+
+func TestFileTargetTarget(t *testing.T) {
+	assert.Equal(t, "//schemas/service.star", FileTarget{"/u", "schemas", "service.star", ""}.Target())
+	assert.Equal(t, "@platform//schemas/service.star",
+		FileTarget{"/p", "schemas", "service.star", "platform"}.Target())
+}
+
+func TestFindStarFiles(t *testing.T) {
+	starverseDir := makeTestUniverse(t, []string{
+		"STARFIG",
+		"schemas/defs.star",
+		"schemas/defs_test.star",
+		"schemas/nested/nested.star",
+		"vendor/vendored.star",
+	})
+
+	ignore, err := NewIgnore([]string{"vendor/"})
+	assert.Nil(t, err)
+	targets, err := FindStarFiles(starverseDir, ignore)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//schemas/defs.star", "//schemas/nested/nested.star"}, fileTargetLabels(targets))
+}
+
+func TestParseSourceTargets(t *testing.T) {
+	starverseDir := makeTestUniverse(t, []string{
+		"STARFIG",
+		"schemas/defs.star",
+		"schemas/defs_test.star",
+		"schemas/README.md",
+		"team/STARFIG",
+	})
+
+	targets, errs := ParseSourceTargets(starverseDir, starverseDir, []string{"//..."}, Ignore{})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{
+		"//STARFIG",
+		"//schemas/defs.star",
+		"//schemas/defs_test.star",
+		"//team/STARFIG",
+	}, fileTargetLabels(targets))
+
+	targets, errs = ParseSourceTargets(starverseDir, starverseDir, []string{"//team:STARFIG"}, Ignore{})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"//team/STARFIG"}, fileTargetLabels(targets))
+
+	_, errs = ParseSourceTargets(starverseDir, starverseDir, []string{"//schemas:README.md"}, Ignore{})
+	assert.ErrorContains(t, errs[0], "//schemas:README.md is not a .star or STARFIG file.")
+}
@@ -0,0 +1,151 @@
+package target
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const IgnoreFilename string = ".starfigignore"
+
+// Ignore matches paths relative to the universe root against gitignore style patterns. Like
+// gitignore, the last matching pattern wins, so a negated pattern can re-include a path.
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// LoadIgnore combines the patterns of the .starfigignore file in the universe root, if it
+// exists, followed by the given patterns. i.e. the ignore list in STARVERSE.
+func LoadIgnore(starverseDir string, patterns []string) (Ignore, error) {
+	lines := []string{}
+	path := filepath.Join(starverseDir, IgnoreFilename)
+	file, err := os.Open(path)
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return Ignore{}, err
+		}
+	} else if !os.IsNotExist(err) {
+		return Ignore{}, err
+	}
+
+	return NewIgnore(append(lines, patterns...))
+}
+
+func NewIgnore(lines []string) (Ignore, error) {
+	ignore := Ignore{patterns: []ignorePattern{}}
+	for _, line := range lines {
+		pattern, ok, err := compileIgnorePattern(line)
+		if err != nil {
+			return ignore, err
+		} else if ok {
+			ignore.patterns = append(ignore.patterns, pattern)
+		}
+	}
+	return ignore, nil
+}
+
+// Match reports if the slash separated path, relative to the universe root, is ignored.
+func (ignore Ignore) Match(path string, isDir bool) bool {
+	ignored := false
+	for _, pattern := range ignore.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if pattern.regex.MatchString(path) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
+
+func compileIgnorePattern(line string) (ignorePattern, bool, error) {
+	pattern := ignorePattern{}
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern, false, nil
+	}
+
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// A pattern with a slash, other than a trailing one, is relative to the root. Otherwise
+	// it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern, false, nil
+	}
+
+	expression := globToRegex(line)
+	if anchored {
+		expression = fmt.Sprintf("^%s$", expression)
+	} else {
+		expression = fmt.Sprintf("^(?:.*/)?%s$", expression)
+	}
+
+	regex, err := regexp.Compile(expression)
+	if err != nil {
+		return pattern, false, fmt.Errorf("Invalid ignore pattern %s.", line)
+	}
+	pattern.regex = regex
+	return pattern, true, nil
+}
+
+func globToRegex(glob string) string {
+	var builder strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/') {
+				builder.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/') {
+				builder.WriteString(".*")
+				i += 1
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		case '[':
+			end := strings.Index(glob[i:], "]")
+			if end <= 1 {
+				builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString(fmt.Sprintf("[%s]", class))
+			i += end
+		case '\\':
+			if i+1 < len(glob) {
+				i += 1
+			}
+			builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,82 @@
+package target
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoreMatch(t *testing.T) {
+	ignore, err := NewIgnore([]string{
+		"# comment",
+		"",
+		"node_modules/",
+		"/vendor",
+		"experimental/**/fixtures",
+		"*.tmp",
+		"build/**",
+		"!build/keep",
+		"data?/[abc]*",
+	})
+	assert.Nil(t, err)
+
+	for _, testCase := range []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false},
+		{"vendor", true, true},
+		{"web/vendor", true, false},
+		{"experimental/fixtures", true, true},
+		{"experimental/team/a/fixtures", true, true},
+		{"fixtures", true, false},
+		{"web/config.tmp", false, true},
+		{"build/out", true, true},
+		{"build/keep", true, false},
+		{"build", true, false},
+		{"data1/apple", true, true},
+		{"data1/durian", true, false},
+		{"comment", true, false},
+		{"web", true, false},
+	} {
+		assert.Equal(t, testCase.expected, ignore.Match(testCase.path, testCase.isDir), testCase.path)
+	}
+}
+
+func TestLoadIgnore(t *testing.T) {
+	starverseDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(starverseDir, IgnoreFilename), []byte("node_modules/\n"), 0644)
+	assert.Nil(t, err)
+	ignore, err := LoadIgnore(starverseDir, []string{"/fixtures"})
+
+	assert.Nil(t, err)
+	assert.True(t, ignore.Match("web/node_modules", true))
+	assert.True(t, ignore.Match("fixtures", true))
+	assert.False(t, ignore.Match("web", true))
+}
+
+func TestLoadIgnoreWithoutFile(t *testing.T) {
+	ignore, err := LoadIgnore(t.TempDir(), []string{})
+
+	assert.Nil(t, err)
+	assert.False(t, ignore.Match("web", true))
+}
+
+func TestParseBuildTargetSpreadIgnore(t *testing.T) {
+	starverseDir := makeUniverse(t, "web", "web/node_modules/lib", "fixtures")
+	ignore, err := NewIgnore([]string{"node_modules/", "/fixtures"})
+	assert.Nil(t, err)
+
+	targets, err := ParseBuildTarget(starverseDir, "//...", ignore)
+	assert.Nil(t, err)
+	assert.Equal(t, []BuildTarget{{starverseDir, "web", "..."}}, targets)
+
+	targets, err = ParseBuildTarget(starverseDir, "//fixtures/...", ignore)
+	assert.Nil(t, err)
+	assert.Equal(t, []BuildTarget{{starverseDir, "fixtures", "..."}}, targets)
+}
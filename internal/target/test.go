@@ -0,0 +1,21 @@
+package target
+
+import "strings"
+
+const TestFileSuffix string = "_test.star"
+
+func IsTestFile(name string) bool {
+	return strings.HasSuffix(name, TestFileSuffix)
+}
+
+// ParseTestTargets finds the test files matched by the patterns, in the same form as build
+// target patterns:
+//   - //pkg/... is every test file in the package and its subpackages
+//   - //pkg, //pkg:all and //pkg:... are every test file in the package
+//   - //pkg:defs_test.star is a single test file
+//
+// Patterns starting with a dash exclude the test files they match.
+func ParseTestTargets(
+	starverseDir string, workingDir string, rawPatterns []string, ignore Ignore) ([]FileTarget, []PatternError) {
+	return parseFileTargets(starverseDir, workingDir, rawPatterns, ignore, testFiles)
+}
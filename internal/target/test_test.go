@@ -0,0 +1,78 @@
+package target
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTestTargets(t *testing.T) {
+	starverseDir := makeTestUniverse(t, []string{
+		"STARFIG",
+		"schemas/defs.star",
+		"schemas/defs_test.star",
+		"schemas/more_test.star",
+		"schemas/nested/nested_test.star",
+		"team/STARFIG",
+		"team/team_test.star",
+	})
+
+	targets, errs := ParseTestTargets(starverseDir, starverseDir, []string{"//..."}, Ignore{})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{
+		"//schemas/defs_test.star",
+		"//schemas/more_test.star",
+		"//schemas/nested/nested_test.star",
+		"//team/team_test.star",
+	}, fileTargetLabels(targets))
+
+	targets, errs = ParseTestTargets(starverseDir, starverseDir, []string{"//schemas"}, Ignore{})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"//schemas/defs_test.star", "//schemas/more_test.star"}, fileTargetLabels(targets))
+
+	targets, errs = ParseTestTargets(
+		starverseDir, filepath.Join(starverseDir, "schemas"), []string{":defs_test.star"}, Ignore{})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"//schemas/defs_test.star"}, fileTargetLabels(targets))
+
+	targets, errs = ParseTestTargets(
+		starverseDir, starverseDir, []string{"//...", "-//schemas/..."}, Ignore{})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"//team/team_test.star"}, fileTargetLabels(targets))
+}
+
+func TestParseTestTargetsInvalid(t *testing.T) {
+	starverseDir := makeTestUniverse(t, []string{"schemas/defs.star"})
+
+	_, errs := ParseTestTargets(starverseDir, starverseDir, []string{"//schemas:defs.star"}, Ignore{})
+	assert.ErrorContains(t, errs[0],
+		"//schemas:defs.star is not a test file. Test files end with _test.star.")
+
+	_, errs = ParseTestTargets(starverseDir, starverseDir, []string{"//schemas:missing_test.star"}, Ignore{})
+	assert.ErrorContains(t, errs[0], "//schemas:missing_test.star does not exist.")
+
+	_, errs = ParseTestTargets(starverseDir, starverseDir, []string{"//missing"}, Ignore{})
+	assert.ErrorContains(t, errs[0], "Package //missing does not exist.")
+}
+
+// MARK: - Helpers
+
+func fileTargetLabels(targets []FileTarget) []string {
+	labels := []string{}
+	for _, target := range targets {
+		labels = append(labels, target.Target())
+	}
+	return labels
+}
+
+func makeTestUniverse(t *testing.T, files []string) string {
+	starverseDir := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(starverseDir, file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte{}, 0644))
+	}
+	return starverseDir
+}
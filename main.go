@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jathu/starfig/internal/command"
 	"github.com/jathu/starfig/internal/logging"
@@ -23,6 +24,7 @@ var starfigVersion string = "dev"
 
 func main() {
 	logging.SetupLogger()
+	command.StarfigVersion = starfigVersion
 	logrus.Debug("starting starfig")
 
 	var rootCmd = &cobra.Command{
@@ -37,17 +39,177 @@ func main() {
 	}
 
 	var buildKeepGoing bool
+	var buildDefines []string
+	var buildMatrix []string
+	var buildChangedFiles string
+	var buildCheckGolden string
+	var buildUpdateGolden bool
+	var buildWithMetadata bool
 	buildCmd := cobra.Command{
 		Use:   "build [targets...]",
 		Short: "Build config targets.",
-		Long:  `Build config targets within the universe. The argument takes a list of build targets. The argument also allows building a whole package by using the spread operator. i.e. //... //example/...`,
+		Long:  `Build config targets within the universe. The argument takes a list of build targets. The argument also allows building a whole package by using the spread operator. i.e. //... //example/... Patterns starting with a dash exclude targets and must come after --. i.e. -- //... -//experimental/...`,
 		Run: func(cmd *cobra.Command, args []string) {
-			safeExit(command.Build(args, buildKeepGoing))
+			safeExit(command.Build(args, command.BuildOptions{
+				KeepGoing:    buildKeepGoing,
+				Defines:      buildDefines,
+				Matrix:       buildMatrix,
+				ChangedFiles: buildChangedFiles,
+				CheckGolden:  buildCheckGolden,
+				UpdateGolden: buildUpdateGolden,
+				WithMetadata: buildWithMetadata,
+			}))
 		},
 	}
 	buildCmd.Flags().BoolVar(&buildKeepGoing, "keep-going", false, "Continue to build as many targets as possible even if there are errors.")
+	buildCmd.Flags().StringArrayVar(&buildDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	buildCmd.Flags().StringArrayVar(&buildMatrix, "matrix", []string{}, "Build every target for each value of a build setting. i.e. --matrix env=dev,prod. Can be repeated to build every combination.")
+	buildCmd.Flags().StringVar(&buildChangedFiles, "changed-files", "", "Only build the targets depending on the files listed in the file, one per line, or - to read from stdin. Builds //... when no targets are given.")
+	buildCmd.Flags().StringVar(&buildCheckGolden, "check-golden", "", "Compare the output of each target with its golden file in the directory, and print a diff of every mismatch.")
+	buildCmd.Flags().BoolVar(&buildUpdateGolden, "update-golden", false, "Write the output of each target to its golden file in the --check-golden directory.")
+	buildCmd.Flags().BoolVar(&buildWithMetadata, "with-metadata", false, "Wrap each target in an envelope with its schema, source file and line, a hash of its inputs, the starfig version and the build settings.")
 	rootCmd.AddCommand(&buildCmd)
 
+	var diffBase string
+	var diffDefines []string
+	diffCmd := cobra.Command{
+		Use:   "diff --base=<rev> [targets...]",
+		Short: "Show how the configs changed since a git revision.",
+		Long:  `Evaluate the targets in the working tree and at a git revision, and print the targets that were added or removed and every field that changed. The revision is checked out to a temporary git worktree. Without targets, //... is compared.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.Diff(args, command.DiffOptions{
+				Base:    diffBase,
+				Defines: diffDefines,
+			}))
+		},
+	}
+	diffCmd.Flags().StringVar(&diffBase, "base", "", "The git revision to compare with. i.e. main or HEAD~1.")
+	diffCmd.Flags().StringArrayVar(&diffDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	rootCmd.AddCommand(&diffCmd)
+
+	var testDefines []string
+	testCmd := cobra.Command{
+		Use:   "test [targets...]",
+		Short: "Run the tests in *_test.star files.",
+		Long:  `Run the test_* functions of the *_test.star files matching the targets. i.e. //... //example/... //example:defs_test.star`,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.Test(args, command.TestOptions{Defines: testDefines}))
+		},
+	}
+	testCmd.Flags().StringArrayVar(&testDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	rootCmd.AddCommand(&testCmd)
+
+	var fmtCheck bool
+	fmtCmd := cobra.Command{
+		Use:   "fmt [targets...]",
+		Short: "Format .star and STARFIG files.",
+		Long:  `Format the .star and STARFIG files matching the targets in place: indentation, trailing commas, sorted load symbols and quotes. The targets are the same patterns as test, i.e. //... //example/... //example:defs.star. Without targets, //... is formatted.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.Fmt(args, command.FmtOptions{Check: fmtCheck}))
+		},
+	}
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "Print a diff of every file that isn't formatted and fail, instead of formatting them.")
+	rootCmd.AddCommand(&fmtCmd)
+
+	var lintOutput string
+	lintCmd := cobra.Command{
+		Use:   "lint [targets...]",
+		Short: "Check .star and STARFIG files for common mistakes.",
+		Long:  `Check the .star and STARFIG files matching the targets for mistakes the evaluator doesn't catch, i.e. unused loads, required fields with defaults and validations that never return None. The targets are the same patterns as test, i.e. //... //example/... //example:defs.star. Without targets, //... is checked. A finding is suppressed with a # starfig:disable=SF001 comment.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.Lint(args, command.LintOptions{Output: lintOutput}))
+		},
+	}
+	lintCmd.Flags().StringVar(&lintOutput, "output", "text", "The output format: text or json.")
+	rootCmd.AddCommand(&lintCmd)
+
+	var lspDefines []string
+	lspCmd := cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server for editors.",
+		Long:  `Run a Language Server Protocol server over stdin and stdout, for editors like VS Code and Neovim. Open files are evaluated as they are edited and their errors are reported as diagnostics. It supports go to definition of load labels and names, completion of the fields of a schema instantiation, and hover of schemas and fields.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.Lsp(command.LspOptions{Defines: lspDefines}))
+		},
+	}
+	lspCmd.Flags().StringArrayVar(&lspDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	rootCmd.AddCommand(&lspCmd)
+
+	var queryOutput string
+	var queryDefines []string
+	var queryKeepGoing bool
+	queryCmd := cobra.Command{
+		Use:   "query [expression]",
+		Short: "Query the targets and their dependencies.",
+		Long:  `Query the targets and their dependencies within the universe. The expression is a target pattern, i.e. //example/..., or a function: deps(//pkg:target) lists everything the target depends on, rdeps(//..., //pkg/defs.star) lists the targets in the pattern that depend on the file or target, and schema(//pkg:target) shows the schema of the target.`,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.Query(strings.Join(args, " "), command.QueryOptions{
+				Output:    queryOutput,
+				Defines:   queryDefines,
+				KeepGoing: queryKeepGoing,
+			}))
+		},
+	}
+	queryCmd.Flags().StringVar(&queryOutput, "output", "label", "The output format: label, json or graph (Graphviz DOT).")
+	queryCmd.Flags().StringArrayVar(&queryDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	queryCmd.Flags().BoolVar(&queryKeepGoing, "keep-going", false, "Skip targets that fail to evaluate instead of failing the query.")
+	rootCmd.AddCommand(&queryCmd)
+
+	var schemaAgainst string
+	var schemaDefines []string
+	schemaCmd := cobra.Command{
+		Use:   "schema",
+		Short: "Check schemas for breaking changes.",
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(cmd.Help())
+		},
+	}
+	schemaCheckCmd := cobra.Command{
+		Use:   "check --against=<snapshot or rev>",
+		Short: "Compare the schemas with a snapshot or a git revision, and fail on breaking changes.",
+		Long:  `Compare every schema in the universe with a snapshot file from schema snapshot, or with a git revision, i.e. main. Each change is classified as breaking or compatible using the schema_policy of STARVERSE, and the command fails if any change is breaking.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.SchemaCheck(command.SchemaOptions{
+				Against: schemaAgainst,
+				Defines: schemaDefines,
+			}))
+		},
+	}
+	schemaCheckCmd.Flags().StringVar(&schemaAgainst, "against", "", "The schema snapshot file or git revision to compare with. i.e. schemas.json or main.")
+	schemaCheckCmd.Flags().StringArrayVar(&schemaDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	schemaCmd.AddCommand(&schemaCheckCmd)
+	schemaSnapshotCmd := cobra.Command{
+		Use:   "snapshot",
+		Short: "Print the shape of every schema as JSON, to be used with schema check --against.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.SchemaSnapshot(command.SchemaOptions{Defines: schemaDefines}))
+		},
+	}
+	schemaSnapshotCmd.Flags().StringArrayVar(&schemaDefines, "define", []string{}, "Set a build setting used by select() and settings(). i.e. --define env=prod. Can be repeated.")
+	schemaCmd.AddCommand(&schemaSnapshotCmd)
+	rootCmd.AddCommand(&schemaCmd)
+
+	depsCmd := cobra.Command{
+		Use:   "deps",
+		Short: "Manage external universes.",
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(cmd.Help())
+		},
+	}
+	depsCmd.AddCommand(&cobra.Command{
+		Use:   "update",
+		Short: "Update STARVERSE.lock with the current content of the external universes.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			safeExit(command.DepsUpdate())
+		},
+	})
+	rootCmd.AddCommand(&depsCmd)
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Print the starfig version.",